@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"gin-subscription/internal/database"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type historyQuery struct {
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=1000"`
+	Cursor string `form:"cursor"`
+}
+
+// getSubscriptionHistory returns audit log of subscription
+//
+//	@Summary		get audit log of subscription
+//	@Description	changes of subscription with actor and request id, newest first. Changes contain fields before and after change, log of deleted and purged subscription is kept
+//	@Tags			Subscription
+//	@Produce		json
+//	@Param			id		path	int		true	"Subscription id"
+//	@Param			limit	query	int		false	"page size, 50 by default, 1000 max"
+//	@Param			cursor	query	string	false	"next_cursor from previous page"
+//	@Success		200	{object}	database.AuditPage
+//	@Failure		404
+//	@Router			/api/v1/subscription/{id}/history [get]
+func (app *application) getSubscriptionHistory(c *gin.Context) {
+	slog.Info("Method getSubscriptionHistory in controller", "id", c.Param("id"))
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		return
+	}
+
+	var query historyQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := app.models.Subscriptions.History(id, database.ListOptions{Limit: query.Limit, Cursor: query.Cursor})
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		if errors.Is(err, database.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+			return
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive subscription history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// getSubscriptionAt responds with subscription as it was at given time. ETag isn't returned,
+// past state can't be used for conditional requests.
+func (app *application) getSubscriptionAt(c *gin.Context, id int, at time.Time) {
+	sub, err := app.models.Subscriptions.GetAt(id, at)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive subscription"})
+		return
+	}
+	if sub == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sub)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gin-subscription/internal/database"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+type batchRequest struct {
+	Mode       string           `json:"mode" binding:"omitempty,oneof=atomic partial"`
+	Operations []batchOperation `json:"operations" binding:"required,min=1"`
+}
+
+type batchOperation struct {
+	Op           string                 `json:"op"`
+	Id           int                    `json:"id"`
+	Version      int                    `json:"version"`
+	Subscription *database.Subscription `json:"subscription"`
+}
+
+type batchItemResult struct {
+	Index        int                    `json:"index"`
+	Status       int                    `json:"status"`
+	Subscription *database.Subscription `json:"subscription,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Mode      string            `json:"mode"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Results   []batchItemResult `json:"results"`
+}
+
+// validate checks operation with the same rules as single subscription endpoints
+func (op batchOperation) validate() error {
+	switch database.BatchOp(op.Op) {
+	case database.BatchCreate, database.BatchUpdate:
+		if op.Op == string(database.BatchUpdate) && op.Id <= 0 {
+			return errors.New("invalid subscription ID")
+		}
+		if op.Subscription == nil {
+			return errors.New("subscription is required")
+		}
+		return binding.Validator.ValidateStruct(op.Subscription)
+	case database.BatchDelete:
+		if op.Id <= 0 {
+			return errors.New("invalid subscription ID")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown operation %q, expected create, update or delete", op.Op)
+}
+
+func batchItemStatus(op database.BatchOp, err error) (int, string) {
+	switch {
+	case err == nil && op == database.BatchCreate:
+		return http.StatusCreated, ""
+	case err == nil && op == database.BatchDelete:
+		return http.StatusNoContent, ""
+	case err == nil:
+		return http.StatusOK, ""
+	case errors.Is(err, database.ErrRecordNotFound):
+		return http.StatusNotFound, "Subscription not found"
+	case errors.Is(err, database.ErrEditConflict):
+		return http.StatusConflict, "Subscription was modified"
+	case errors.Is(err, database.ErrCancelledEndDate):
+		return http.StatusConflict, err.Error()
+	case isServiceRefError(err):
+		return http.StatusUnprocessableEntity, err.Error()
+	case errors.Is(err, database.ErrBatchRolledBack):
+		return http.StatusFailedDependency, "Rolled back because other operation failed"
+	}
+
+	slog.Error("ERROR in batch operation", "op", op, "error", err)
+	return http.StatusInternalServerError, "Failed to apply operation"
+}
+
+// batchSubscription applies several create, update and delete operations at once
+//
+//	@Summary		applies batch of subscription changes
+//	@Description	operations are applied in single transaction, in 'atomic' mode (default) any failed operation rolls back whole batch
+//	@Description	in 'partial' mode failed operations are skipped and others are applied
+//	@Description	every operation is {"op": "create|update|delete", "id": 1, "version": 1, "subscription": {...}}, version is optional
+//	@Description	responds 200 when all operations succeeded, 207 when some operations failed in partial mode and 422 when atomic batch was rolled back
+//	@Description	503 is returned when batch isn't applied within 30 seconds, then none of operations is applied
+//	@Tags			Subscription
+//	@Accept			json
+//	@Produce		json
+//	@Param			Idempotency-Key	header		string			false	"unique key to safely retry request"
+//	@Param			batch			body		batchRequest	true	"Batch of operations"
+//	@Success		200				{object}	batchResponse
+//	@Success		207				{object}	batchResponse
+//	@Failure		422				{object}	batchResponse
+//	@Failure		503
+//	@Router			/api/v1/subscription/batch [post]
+func (app *application) batchSubscription(c *gin.Context) {
+	var req batchRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Operations) > database.MaxBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Too many operations, at most %d are accepted", database.MaxBatchSize)})
+		return
+	}
+
+	if req.Mode == "" {
+		req.Mode = "atomic"
+	}
+	atomic := req.Mode == "atomic"
+
+	slog.Info("Method batchSubscription in controller", "mode", req.Mode, "operations", len(req.Operations))
+
+	resp := batchResponse{Mode: req.Mode, Results: make([]batchItemResult, len(req.Operations))}
+
+	// invalid operations are reported without touching database, valid ones are passed to model
+	var ops []database.BatchOperation
+	var opIndexes []int
+	invalid := false
+
+	for i, op := range req.Operations {
+		resp.Results[i].Index = i
+
+		if err := op.validate(); err != nil {
+			resp.Results[i].Status = http.StatusBadRequest
+			resp.Results[i].Error = err.Error()
+			invalid = true
+			continue
+		}
+
+		ops = append(ops, database.BatchOperation{
+			Op:           database.BatchOp(op.Op),
+			Id:           op.Id,
+			Version:      op.Version,
+			Subscription: op.Subscription,
+		})
+		opIndexes = append(opIndexes, i)
+	}
+
+	if atomic && invalid {
+		for _, i := range opIndexes {
+			resp.Results[i].Status, resp.Results[i].Error = batchItemStatus("", database.ErrBatchRolledBack)
+		}
+		ops = nil
+	}
+
+	if len(ops) > 0 {
+		results, err := app.subscriptions(c).Batch(c.Request.Context(), ops, atomic)
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			slog.ErrorContext(c.Request.Context(), "ERROR in batchSubscription", "request_id", c.GetString(requestIdKey), "operations", len(ops), "error", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Batch wasn't applied in time, no operations were applied"})
+			return
+		}
+		if err != nil {
+			fmt.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply batch"})
+			return
+		}
+
+		for j, res := range results {
+			i := opIndexes[j]
+			resp.Results[i].Status, resp.Results[i].Error = batchItemStatus(ops[j].Op, res.Err)
+			resp.Results[i].Subscription = res.Subscription
+		}
+	}
+
+	for _, res := range resp.Results {
+		if res.Error == "" {
+			resp.Succeeded++
+		} else {
+			resp.Failed++
+		}
+	}
+
+	status := http.StatusOK
+	if resp.Failed > 0 && atomic {
+		status = http.StatusUnprocessableEntity
+	} else if resp.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+
+	c.JSON(status, resp)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"gin-subscription/internal/database"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type cancelInput struct {
+	Mode    database.CancelMode   `json:"mode" binding:"omitempty,oneof=immediate end_of_cycle" example:"end_of_cycle"`
+	Reason  database.CancelReason `json:"reason" binding:"required,oneof=too_expensive not_using switched_service missing_features technical_issues customer_service other" example:"too_expensive"`
+	Comment string                `json:"comment" binding:"max=1000" example:"Found cheaper plan"`
+}
+
+func cancelStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, database.ErrRecordNotFound):
+		return http.StatusNotFound, "Subscription not found"
+	case errors.Is(err, database.ErrAlreadyCancelled), errors.Is(err, database.ErrSubscriptionEnded), errors.Is(err, database.ErrNotStarted),
+		errors.Is(err, database.ErrNotCancelled), errors.Is(err, database.ErrCancellationEffective):
+		return http.StatusConflict, err.Error()
+	}
+
+	fmt.Println(err)
+	return http.StatusInternalServerError, "Failed to change subscription"
+}
+
+// cancelSubscription ends subscription and records reason of cancellation
+//
+//	@Summary		cancels subscription
+//	@Description	'immediate' mode ends subscription today, 'end_of_cycle' (default) on the last day of current billing period, end date of subscription is set accordingly
+//	@Description	reason is one of too_expensive, not_using, switched_service, missing_features, technical_issues, customer_service and other, comment is free text
+//	@Description	409 is returned when subscription is already cancelled, has ended or is cancelled immediately before its start
+//	@Tags			Subscription
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int			true	"Subscription id"
+//	@Param			cancel	body		cancelInput	true	"Cancellation"
+//	@Success		200		{object}	database.Subscription
+//	@Failure		409
+//	@Router			/api/v1/subscription/{id}/cancel [post]
+func (app *application) cancelSubscription(c *gin.Context) {
+	slog.Info("Method cancelSubscription in controller", "id", c.Param("id"))
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		return
+	}
+
+	var input cancelInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Mode == "" {
+		input.Mode = database.CancelEndOfCycle
+	}
+
+	sub, err := app.subscriptions(c).Cancel(id, database.Cancellation{Mode: input.Mode, Reason: input.Reason, Comment: input.Comment})
+	if err != nil {
+		status, message := cancelStatus(err)
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	c.Header("ETag", subscriptionETag(sub))
+	c.JSON(http.StatusOK, sub)
+}
+
+// reactivateSubscription withdraws cancellation of subscription
+//
+//	@Summary		reactivates cancelled subscription
+//	@Description	cancellation may be withdrawn until its effective date inclusive, end date of subscription before cancellation is restored
+//	@Description	409 is returned when subscription isn't cancelled or cancellation is already effective
+//	@Tags			Subscription
+//	@Produce		json
+//	@Param			id	path		int	true	"Subscription id"
+//	@Success		200	{object}	database.Subscription
+//	@Failure		409
+//	@Router			/api/v1/subscription/{id}/reactivate [post]
+func (app *application) reactivateSubscription(c *gin.Context) {
+	slog.Info("Method reactivateSubscription in controller", "id", c.Param("id"))
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		return
+	}
+
+	sub, err := app.subscriptions(c).Reactivate(id)
+	if err != nil {
+		status, message := cancelStatus(err)
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	c.Header("ETag", subscriptionETag(sub))
+	c.JSON(http.StatusOK, sub)
+}
@@ -1,17 +1,200 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"gin-subscription/internal/database"
+	"io"
 	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	jsonpatch "github.com/evanphx/json-patch/v5"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
+// listQuery is sorting and pagination params of subscription list
+type listQuery struct {
+	Limit     int    `form:"limit" binding:"omitempty,min=1,max=1000"`
+	Offset    int    `form:"offset" binding:"omitempty,min=0"`
+	Cursor    string `form:"cursor"`
+	WithTotal bool   `form:"with_total"`
+	Sort      string `form:"sort"`
+	// IncludeDeleted lists deleted subscriptions as well
+	IncludeDeleted bool `form:"include_deleted"`
+}
+
+// queryInt returns optional integer query param
+func queryInt(c *gin.Context, name string) (*int, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q, integer expected", name, v)
+	}
+
+	return &n, nil
+}
+
+// queryAmount returns optional decimal query param in minor units, amounts are compared regardless of currency
+// as if currency had 2 decimal places like RUB
+func queryAmount(c *gin.Context, name string) (*int64, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+
+	amount, err := database.ParseAmount(v, database.BaseCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q, decimal number expected", name, v)
+	}
+
+	return &amount, nil
+}
+
+// queryDate returns optional query param in "mm-yyyy" or "dd-mm-yyyy" format, month means its last day with endOfMonth
+func queryDate(c *gin.Context, name string, endOfMonth bool) (*time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+
+	t, err := database.ParseDate(v, endOfMonth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q, mm-yyyy or dd-mm-yyyy expected", name, v)
+	}
+
+	return &t, nil
+}
+
+// queryTimestamp returns optional time query param in RFC 3339 format, day in dd-mm-yyyy format means end of that day in UTC
+func queryTimestamp(c *gin.Context, name string) (*time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return &t, nil
+	}
+
+	day, err := time.Parse("02-01-2006", v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q, RFC 3339 timestamp or dd-mm-yyyy expected", name, v)
+	}
+	t := day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+
+	return &t, nil
+}
+
+// subscriptionFilter builds filter from query params
+func subscriptionFilter(c *gin.Context) (*database.Filter, error) {
+	filter := database.NewFilter()
+
+	if u := c.Query("user_id"); u != "" {
+		var userIds []any
+		for _, part := range strings.Split(u, ",") {
+			userId, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return nil, fmt.Errorf("invalid user_id %q, integer expected", part)
+			}
+			userIds = append(userIds, userId)
+		}
+
+		if len(userIds) == 1 {
+			filter.Eq("user_id", userIds[0])
+		} else {
+			filter.In("user_id", userIds...)
+		}
+	}
+
+	if s := c.Query("service_name"); s != "" {
+		filter.Eq("service_name", s)
+	}
+
+	if s := c.Query("service_id"); s != "" {
+		serviceId, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid service_id %q, integer expected", s)
+		}
+		filter.Eq("service_id", serviceId)
+	}
+
+	if q := c.Query("search"); q != "" {
+		filter.Contains("service_name", q)
+	}
+
+	priceMin, err := queryAmount(c, "price_min")
+	if err != nil {
+		return nil, err
+	}
+	priceMax, err := queryAmount(c, "price_max")
+	if err != nil {
+		return nil, err
+	}
+	if priceMin != nil && priceMax != nil && *priceMin > *priceMax {
+		return nil, fmt.Errorf("price_min is greater than price_max")
+	}
+	if priceMin != nil {
+		filter.Gte("price", *priceMin)
+	}
+	if priceMax != nil {
+		filter.Lte("price", *priceMax)
+	}
+
+	for _, r := range []struct {
+		param string
+		field string
+		op    database.Operator
+	}{
+		{"start_from", "start_date", database.OpGte},
+		{"start_to", "start_date", database.OpLte},
+		{"end_from", "end_date", database.OpGte},
+		{"end_to", "end_date", database.OpLte},
+	} {
+		t, err := queryDate(c, r.param, r.op == database.OpLte)
+		if err != nil {
+			return nil, err
+		}
+		if t != nil {
+			filter.Where(r.field, r.op, *t)
+		}
+	}
+
+	activeFrom, err := queryDate(c, "active_at", false)
+	if err != nil {
+		return nil, err
+	}
+	if activeFrom != nil {
+		activeTo, _ := queryDate(c, "active_at", true)
+		filter.Lte("start_date", *activeTo).Or(
+			database.NewFilter().Gte("end_date", *activeFrom),
+			database.NewFilter().IsNull("end_date"),
+		)
+	}
+
+	if h := c.Query("has_end_date"); h != "" {
+		hasEndDate, err := strconv.ParseBool(h)
+		if err != nil {
+			return nil, fmt.Errorf("invalid has_end_date %q, boolean expected", h)
+		}
+		if hasEndDate {
+			filter.NotNull("end_date")
+		} else {
+			filter.IsNull("end_date")
+		}
+	}
+
+	return filter, nil
+}
+
 // createSubscription creates new subscription
 //
 //	@Summary		creates new subscription
@@ -19,8 +202,13 @@ import (
 //	@Tags			Subscription
 //	@Accept			json
 //	@Produce		json
+//	@Param			Idempotency-Key	header		string					false	"unique key to safely retry request"
 //	@Param			subscription	body		database.Subscription	true	"Name of subscription provider"
 //	@Success		201				{object}	database.Subscription
+//	@Header			201				{string}	ETag	"subscription version and its status"
+//	@Header			201				{string}	Location	"URL of created subscription"
+//	@Failure		409
+//	@Failure		422
 //	@Router			/api/v1/subscription [post]
 func (app *application) createSubscription(c *gin.Context) {
 	var subscription database.Subscription
@@ -30,26 +218,35 @@ func (app *application) createSubscription(c *gin.Context) {
 		return
 	}
 
-	err := app.models.Subscriptions.Insert(&subscription)
+	err := app.subscriptions(c).Insert(&subscription)
 
 	if err != nil {
+		if serviceRefError(c, err) {
+			return
+		}
 		fmt.Println(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create subscription"})
 		return
 	}
 
+	c.Header("ETag", subscriptionETag(&subscription))
+	c.Header("Location", fmt.Sprintf("/api/v1/subscription/%d", subscription.Id))
 	c.JSON(http.StatusCreated, subscription)
 }
 
 // getSubscription returns single subscription
 //
 //	@Summary		returns single subscription
-//	@Description	returns single subscription
+//	@Description	returns single subscription, with 'as_of' subscription is reconstructed from audit log as it was at that time
 //	@Tags			Subscription
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		int	true	"Subscription id"
-//	@Success		200	{object}	database.Subscription
+//	@Param			id				path		int		true	"Subscription id"
+//	@Param			as_of			query		string	false	"RFC 3339 timestamp or dd-mm-yyyy for end of day"	example(2026-03-01T00:00:00Z)
+//	@Param			If-None-Match	header		string	false	"ETag of cached subscription"
+//	@Success		200				{object}	database.Subscription
+//	@Header			200				{string}	ETag	"subscription version and its status"
+//	@Success		304
 //	@Router			/api/v1/subscription/{id} [get]
 func (app *application) getSubscription(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
@@ -58,6 +255,17 @@ func (app *application) getSubscription(c *gin.Context) {
 		return
 	}
 
+	asOf, err := queryTimestamp(c, "as_of")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if asOf != nil {
+		app.getSubscriptionAt(c, id, *asOf)
+		return
+	}
+
 	sub, err := app.models.Subscriptions.Get(id)
 	fmt.Println(err)
 	if sub == nil {
@@ -70,19 +278,30 @@ func (app *application) getSubscription(c *gin.Context) {
 		return
 	}
 
+	etag := subscriptionETag(sub)
+	c.Header("ETag", etag)
+
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, etag, true) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
 	c.JSON(http.StatusOK, sub)
 }
 
 // updateSubscription updates an existing subscription
 //
 //	@Summary		updates existing subscription
-//	@Description	updates existing subscription
+//	@Description	updates existing subscription, end date of cancelled subscription can't be changed until it is reactivated
 //	@Tags			Subscription
 //	@Accept			json
 //	@Produce		json
 //	@Param			id				path		int						true	"Subscription id"
+//	@Param			If-Match		header		string					false	"ETag of subscription"
 //	@Param			subscription	body		database.Subscription	true	"Subscription"
 //	@Success		200				{object}	database.Subscription
+//	@Failure		409
+//	@Failure		412
 //	@Router			/api/v1/subscription/{id} [put]
 func (app *application) updateSubscription(c *gin.Context) {
 	slog.Info("Method updateSubscription in controller", "id", c.Param("id"))
@@ -106,6 +325,10 @@ func (app *application) updateSubscription(c *gin.Context) {
 		return
 	}
 
+	if !checkIfMatch(c, existingSub) {
+		return
+	}
+
 	updatedSub := &database.Subscription{}
 
 	if err := c.ShouldBindJSON(updatedSub); err != nil {
@@ -114,25 +337,182 @@ func (app *application) updateSubscription(c *gin.Context) {
 	}
 
 	updatedSub.Id = id
+	updatedSub.Version = existingSub.Version
 
-	if err := app.models.Subscriptions.Update(updatedSub); err != nil {
+	if err := app.subscriptions(c).Update(updatedSub); err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+			return
+		}
+		if errors.Is(err, database.ErrEditConflict) {
+			editConflict(c)
+			return
+		}
+		if errors.Is(err, database.ErrCancelledEndDate) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if serviceRefError(c, err) {
+			return
+		}
 		fmt.Println(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update subscription"})
 		return
 	}
 
+	c.Header("ETag", subscriptionETag(updatedSub))
+	c.JSON(http.StatusOK, updatedSub)
+}
+
+// patchSubscription partially updates an existing subscription
+//
+//	@Summary		partially updates existing subscription
+//	@Description	accepts JSON Merge Patch (RFC 7396) with Content-Type application/merge-patch+json or application/json
+//	@Description	and JSON Patch (RFC 6902) with Content-Type application/json-patch+json
+//	@Description	patched subscription is validated with the same rules as on create
+//	@Description	end date of cancelled subscription can't be changed until it is reactivated
+//	@Tags			Subscription
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int		true	"Subscription id"
+//	@Param			If-Match	header		string	false	"ETag of subscription"
+//	@Param			patch		body		object	true	"Merge patch or JSON patch"
+//	@Success		200			{object}	database.Subscription
+//	@Failure		409
+//	@Failure		412
+//	@Router			/api/v1/subscription/{id} [patch]
+func (app *application) patchSubscription(c *gin.Context) {
+	slog.Info("Method patchSubscription in controller", "id", c.Param("id"), "content_type", c.ContentType())
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		return
+	}
+
+	existingSub, err := app.models.Subscriptions.Get(id)
+
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive subscription"})
+		return
+	}
+
+	if existingSub == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+		return
+	}
+
+	if !checkIfMatch(c, existingSub) {
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	original, err := json.Marshal(existingSub)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to patch subscription"})
+		return
+	}
+
+	var patched []byte
+	switch c.ContentType() {
+	case "application/json-patch+json":
+		patch, err := jsonpatch.DecodePatch(body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		patched, err = patch.Apply(original)
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	case "application/merge-patch+json", "application/json", "":
+		patched, err = jsonpatch.MergePatch(original, body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Unsupported patch content type"})
+		return
+	}
+
+	updatedSub := &database.Subscription{}
+
+	if err := json.Unmarshal(patched, updatedSub); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := binding.Validator.ValidateStruct(updatedSub); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updatedSub.Id = id
+	updatedSub.Version = existingSub.Version
+	// renamed subscription is linked to service with new name
+	if updatedSub.ServiceName != existingSub.ServiceName && updatedSub.ServiceId == existingSub.ServiceId {
+		updatedSub.ServiceId = 0
+	}
+	// subscription moved to other plan gets its service and, unless price is patched as well, its price
+	if updatedSub.PlanId != existingSub.PlanId && updatedSub.PlanId != 0 {
+		if updatedSub.ServiceId == existingSub.ServiceId && updatedSub.ServiceName == existingSub.ServiceName {
+			updatedSub.ServiceId, updatedSub.ServiceName = 0, ""
+		}
+		if updatedSub.Price == existingSub.Price && updatedSub.Currency == existingSub.Currency {
+			updatedSub.Price = 0
+		}
+	}
+
+	if err := app.subscriptions(c).UpdateFields(updatedSub, database.ChangedFields(existingSub, updatedSub)); err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+			return
+		}
+		if errors.Is(err, database.ErrEditConflict) {
+			editConflict(c)
+			return
+		}
+		if errors.Is(err, database.ErrCancelledEndDate) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if serviceRefError(c, err) {
+			return
+		}
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update subscription"})
+		return
+	}
+
+	c.Header("ETag", subscriptionETag(updatedSub))
 	c.JSON(http.StatusOK, updatedSub)
 }
 
 // deleteSubscription deletes an existing subscription
 //
 //	@Summary		deletes existing subscription
-//	@Description	deletes existing subscription
+//	@Description	subscription is marked as deleted, it isn't returned anymore but stays in reports for the time it was active until it is purged
 //	@Tags			Subscription
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path	int	true	"Subscription id"
+//	@Param			id			path	int		true	"Subscription id"
+//	@Param			If-Match	header	string	false	"ETag of subscription"
 //	@Success		204
+//	@Failure		412
 //	@Router			/api/v1/subscription/{id} [delete]
 func (app *application) deleteSubscription(c *gin.Context) {
 	slog.Info("Method deleteSubscription in controller", "id", c.Param("id"))
@@ -140,6 +520,7 @@ func (app *application) deleteSubscription(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		return
 	}
 
 	existingSub, err := app.models.Subscriptions.Get(id)
@@ -154,7 +535,24 @@ func (app *application) deleteSubscription(c *gin.Context) {
 		return
 	}
 
-	if err := app.models.Subscriptions.Delete(id); err != nil {
+	if !checkIfMatch(c, existingSub) {
+		return
+	}
+
+	version := 0
+	if c.GetHeader("If-Match") != "" {
+		version = existingSub.Version
+	}
+
+	if err := app.subscriptions(c).Delete(id, version); err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+			return
+		}
+		if errors.Is(err, database.ErrEditConflict) {
+			editConflict(c)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete subscription"})
 		return
 	}
@@ -166,30 +564,91 @@ func (app *application) deleteSubscription(c *gin.Context) {
 //
 //	@Summary		returns list of all subscriptions
 //	@Description	returns list of all subscriptions
+//	@Description	with Accept text/csv, application/x-ndjson or xlsx content type (or 'format' query param) all filtered subscriptions are streamed as file, pagination params are ignored
 //	@Tags			Subscription
 //	@Accept			json
-//	@Produce		json
-//	@Param			user_id			query	int		false	"filter for concrete user"
+//	@Produce		json,text/csv,application/x-ndjson,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+//	@Param			user_id			query	string	false	"filter for concrete users, comma separated"	example(1,2,3)
 //	@Param			service_name	query	string	false	"filter for concrete service"
-//	@Success		200
+//	@Param			service_id		query	int	false	"filter for service from catalog"
+//	@Param			search			query	string	false	"case-insensitive search by part of service name"
+//	@Param			price_min		query	string	false	"minimal price, decimal"	example(99.90)
+//	@Param			price_max		query	string	false	"maximal price, decimal"
+//	@Param			start_from		query	string	false	"start date from, mm-yyyy"
+//	@Param			start_to		query	string	false	"start date to, mm-yyyy"
+//	@Param			end_from		query	string	false	"end date from, mm-yyyy"
+//	@Param			end_to			query	string	false	"end date to, mm-yyyy"
+//	@Param			active_at		query	string	false	"subscriptions active in month, mm-yyyy"
+//	@Param			has_end_date	query	bool	false	"filter subscriptions with or without end date"
+//	@Param			sort			query	string	false	"comma separated fields, '-' prefix for descending order"	example(price,-start_date)
+//	@Param			limit			query	int		false	"page size, 50 by default, 1000 max"
+//	@Param			cursor			query	string	false	"next_cursor from previous page"
+//	@Param			offset			query	int		false	"number of subscriptions to skip"
+//	@Param			with_total		query	bool	false	"include total count of filtered subscriptions"
+//	@Param			include_deleted	query	bool	false	"list deleted subscriptions as well"
+//	@Param			as_of			query	string	false	"list subscriptions as they were at RFC 3339 timestamp or at end of dd-mm-yyyy day, without filters every subscription is reconstructed, so filter large lists"
+//	@Param			format			query	string	false	"json (default), csv, ndjson or xlsx, overrides Accept header"
+//	@Param			currency		query	string	false	"add price converted to currency with rate of current month"
+//	@Success		200	{object}	database.SubscriptionPage
 //	@Router			/api/v1/subscription [get]
 func (app *application) listSubscription(c *gin.Context) {
 	slog.Info("Method listSubscription in controller", "query_filter", c.Request.URL.Query())
 
-	filter := make(map[string]string)
-	if u := c.Query("user_id"); u != "" {
-		_, err := strconv.Atoi(u)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter type"})
-			return
-		}
-		filter["user_id"] = u
+	filter, err := subscriptionFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	if s := c.Query("service_name"); s != "" {
-		filter["service_name"] = s
+
+	var query listQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sort, err := database.ParseSort(query.Sort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	events, err := app.models.Subscriptions.GetList(filter)
+	asOf, err := queryTimestamp(c, "as_of")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	format, isExport, err := exportFormat(c)
+	if err != nil {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
+		return
+	}
+	if isExport {
+		app.exportSubscriptions(c, filter, database.ListOptions{Sort: sort, IncludeDeleted: query.IncludeDeleted, AsOf: asOf}, format)
+		return
+	}
+
+	currency, err := currencyParam(c.Query("currency"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	events, err := app.models.Subscriptions.GetList(filter, database.ListOptions{
+		Limit:     query.Limit,
+		Offset:    query.Offset,
+		Cursor:    query.Cursor,
+		WithTotal: query.WithTotal,
+		Sort:      sort,
+
+		IncludeDeleted: query.IncludeDeleted,
+		AsOf:           asOf,
+	})
+
+	if errors.Is(err, database.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
 
 	if err != nil {
 		fmt.Println(err)
@@ -197,61 +656,144 @@ func (app *application) listSubscription(c *gin.Context) {
 		return
 	}
 
+	if currency != "" {
+		if err := app.convertPrices(events.Data, currency); err != nil {
+			if errors.Is(err, database.ErrNoExchangeRate) {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+				return
+			}
+			fmt.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to convert prices"})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, events)
 }
 
 // getPeriodPrice returns price of chosen subscription for period
 //
 //	@Summary		returns price of choosen subscription for period
-//	@Description	requests period of time in path, format "mm-yyyy:{mm-yyyy}", where right side might be ommited and autoreplaced with time.Now()
-//	@Description	query params 'user_id' and 'service_name' used as filter for request
+//	@Description	requests period of time in path, format "mm-yyyy:{mm-yyyy}", where right side might be ommited and autoreplaced with current date
+//	@Description	both sides may be given with day as "dd-mm-yyyy", period end month is included till its last day
+//	@Description	query params 'user_id' and 'service_name' used as filter for request, other filters of subscription list are supported as well
+//	@Description	'proration' chooses how partial months are billed: 'monthly' (default) bills every touched month in full, 'daily' bills share of days in month, '30/360' counts every month as 30 days
+//	@Description	response has price of every subscription and month-by-month timeline of totals, 'version=1' returns old format with preformatted strings
+//	@Description	prices in other currencies are converted to 'currency' with exchange rate valid at the first day of every billing month, 422 is returned when rate is missing
 //	@Tags			Subscription
 //	@Accept			json
 //	@Produce		json
-//	@Param			period			path	string	true	"period"	example(07-2025:08-2025)
-//	@Param			user_id			query	int		false	"filter for concrete user"
-//	@Param			service_name	query	string	false	"filter for concrete service"
-//	@Success		200
+//	@Param			period			path		string	true	"period"	example(07-2025:08-2025)
+//	@Param			user_id			query		string	false	"filter for concrete users, comma separated"
+//	@Param			service_name	query		string	false	"filter for concrete service"
+//	@Param			service_id		query		int	false	"filter for service from catalog"
+//	@Param			proration		query		string	false	"monthly, daily or 30/360"
+//	@Param			rounding		query		string	false	"half_up (default), half_even, down or up"
+//	@Param			currency		query		string	false	"currency of totals, RUB by default"
+//	@Param			version			query		int		false	"response format version, 2 by default"
+//	@Success		200				{object}	database.PriceReport
 //	@Router			/api/v1/subscription/period-price/{period} [get]
 func (app *application) getPeriodPrice(c *gin.Context) {
 	slog.Info("Method getPeriodPrice in controller", "period", c.Param("period"), "query_filter", c.Request.URL.Query())
 
 	periodSlice := strings.Split(c.Param("period"), ":")
-	var periodTime []time.Time
-	for _, d := range periodSlice {
-		time, err := time.Parse("01-2006", d)
+	if len(periodSlice) > 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription period format"})
+		return
+	}
+
+	start, err := database.ParseDate(periodSlice[0], false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription period format"})
+		return
+	}
+
+	now := time.Now().UTC()
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if len(periodSlice) == 2 {
+		end, err = database.ParseDate(periodSlice[1], true)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription period format"})
 			return
 		}
-		periodTime = append(periodTime, time)
 	}
 
-	start := periodTime[0]
-	end := time.Now()
-	if len(periodTime) == 2 {
-		end = periodTime[1]
+	if end.Before(start) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Subscription period end is before its start"})
+		return
 	}
 
-	filter := make(map[string]string)
-	if u := c.Query("user_id"); u != "" {
-		_, err := strconv.Atoi(u)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter type"})
+	proration, err := database.ParseProration(c.Query("proration"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rounding, err := database.ParseRounding(c.Query("rounding"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	version := 2
+	if v := c.Query("version"); v != "" {
+		version, err = strconv.Atoi(v)
+		if err != nil || version < 1 || version > 2 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version, expected 1 or 2"})
 			return
 		}
-		filter["user_id"] = u
 	}
-	if s := c.Query("service_name"); s != "" {
-		filter["service_name"] = s
+
+	filter, err := subscriptionFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currency, err := currencyParam(c.Query("currency"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	total, prices, err := app.models.Subscriptions.GetPrice(start, end, filter)
+	rates, err := app.rateTable()
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive exchange rates"})
+		return
+	}
+
+	report, err := app.models.Subscriptions.GetPrice(start, end, filter, database.PriceOptions{
+		Proration: proration,
+		Rounding:  rounding,
+		Currency:  currency,
+		Rates:     rates,
+	})
+	if errors.Is(err, database.ErrNoExchangeRate) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		fmt.Println(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive price"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"total price": total, "prices": prices})
+	if version == 1 {
+		c.JSON(http.StatusOK, legacyPeriodPrice(report))
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// legacyPeriodPrice returns price in format of first API version, where prices are preformatted strings by subscription id
+// and total is a number
+func legacyPeriodPrice(report *database.PriceReport) gin.H {
+	prices := make(map[int]string, len(report.Subscriptions))
+	for _, p := range report.Subscriptions {
+		prices[p.Id] = fmt.Sprintf("service_name: %s, months: %g, price: %s, user_id: %d, total_price: %s", p.ServiceName, p.Periods, p.UnitPrice, p.UserId, p.Subtotal)
+	}
+
+	return gin.H{"total price": json.Number(report.Total.String()), "prices": prices}
 }
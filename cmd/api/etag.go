@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"gin-subscription/internal/database"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// subscriptionETag returns version of subscription and its derived status, status changes with day
+// even when subscription doesn't, e.g. pending subscription becomes active.
+// Status isn't derived in responses to changes, their etag has version only.
+func subscriptionETag(sub *database.Subscription) string {
+	if sub.Status == "" {
+		return fmt.Sprintf(`"%d"`, sub.Version)
+	}
+	return fmt.Sprintf(`"%d-%s"`, sub.Version, sub.Status)
+}
+
+// etagVersion returns version part of subscription etag
+func etagVersion(etag string) string {
+	version, _, _ := strings.Cut(strings.Trim(etag, `"`), "-")
+	return version
+}
+
+// etagMatches reports whether etag is listed in If-Match or If-None-Match header value.
+// Weak comparison is used when weak is true, otherwise weak tags never match.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = tag[2:]
+		}
+		if tag == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkIfMatch responds with 412 and returns false when If-Match header is present and doesn't match subscription.
+// Only version is compared, so etag received before status changed still matches unchanged subscription.
+func checkIfMatch(c *gin.Context, sub *database.Subscription) bool {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		return true
+	}
+
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || (!strings.HasPrefix(tag, "W/") && etagVersion(tag) == strconv.Itoa(sub.Version)) {
+			return true
+		}
+	}
+
+	c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Subscription was modified"})
+	return false
+}
+
+// editConflict responds to ErrEditConflict, it is precondition failure when client sent If-Match
+func editConflict(c *gin.Context) {
+	if c.GetHeader("If-Match") != "" {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Subscription was modified"})
+		return
+	}
+
+	c.JSON(http.StatusConflict, gin.H{"error": "Subscription was modified concurrently, retry request"})
+}
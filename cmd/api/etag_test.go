@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gin-subscription/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSubscriptionETag(t *testing.T) {
+	tests := []struct {
+		name string
+		sub  database.Subscription
+		want string
+	}{
+		{name: "pending", sub: database.Subscription{Version: 3, Status: database.StatusPending}, want: `"3-pending"`},
+		{name: "active", sub: database.Subscription{Version: 3, Status: database.StatusActive}, want: `"3-active"`},
+		{name: "status not derived", sub: database.Subscription{Version: 3}, want: `"3"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			etag := subscriptionETag(&tt.sub)
+			if etag != tt.want {
+				t.Fatalf("subscriptionETag() = %s, want %s", etag, tt.want)
+			}
+
+			// If-Match compares version only, so etag stays valid when status changes
+			for _, status := range []database.SubscriptionStatus{"", database.StatusActive, database.StatusEnded} {
+				sub := database.Subscription{Version: 3, Status: status}
+
+				c, _ := gin.CreateTestContext(httptest.NewRecorder())
+				c.Request = httptest.NewRequest(http.MethodPut, "/", nil)
+				c.Request.Header.Set("If-Match", etag)
+				if !checkIfMatch(c, &sub) {
+					t.Errorf("checkIfMatch(%s) of subscription with status %q = false", etag, status)
+				}
+			}
+		})
+	}
+}
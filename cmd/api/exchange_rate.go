@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"gin-subscription/internal/database"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+type exchangeRateInput struct {
+	Rate float64 `json:"rate" binding:"required,gt=0"`
+}
+
+// currencyParam returns ISO 4217 currency code from path or query, empty when it is not given
+func currencyParam(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	code := strings.ToUpper(value)
+	if err := binding.Validator.Engine().(*validator.Validate).Var(code, "iso4217"); err != nil {
+		return "", fmt.Errorf("invalid currency %q, ISO 4217 code expected", value)
+	}
+
+	return code, nil
+}
+
+// rateTable loads all exchange rates for currency conversion
+func (app *application) rateTable() (*database.RateTable, error) {
+	rates, err := app.models.ExchangeRates.List("")
+	if err != nil {
+		return nil, err
+	}
+
+	return database.NewRateTable(rates)
+}
+
+// listExchangeRates returns exchange rates
+//
+//	@Summary		returns exchange rates
+//	@Description	rate is price of one unit of currency in RUB, valid from given day until next rate of the same currency
+//	@Tags			Admin
+//	@Produce		json
+//	@Param			Authorization	header	string	true	"Bearer ADMIN_TOKEN"
+//	@Param			currency	query	string	false	"ISO 4217 code"
+//	@Success		200			{array}	database.ExchangeRate
+//	@Router			/api/v1/admin/exchange-rates [get]
+func (app *application) listExchangeRates(c *gin.Context) {
+	currency, err := currencyParam(c.Query("currency"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rates, err := app.models.ExchangeRates.List(currency)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "ERROR in listExchangeRates", "request_id", c.GetString(requestIdKey), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive exchange rates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rates)
+}
+
+// setExchangeRate stores exchange rate of currency valid from given day
+//
+//	@Summary		sets exchange rate
+//	@Description	creates or replaces rate of currency valid from day in "dd-mm-yyyy" or "mm-yyyy" format
+//	@Tags			Admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header	string	true	"Bearer ADMIN_TOKEN"
+//	@Param			currency	path		string				true	"ISO 4217 code"	example(USD)
+//	@Param			date		path		string				true	"valid from"	example(01-07-2025)
+//	@Param			rate		body		exchangeRateInput	true	"price of one unit in RUB"
+//	@Success		200			{object}	database.ExchangeRate
+//	@Router			/api/v1/admin/exchange-rates/{currency}/{date} [put]
+func (app *application) setExchangeRate(c *gin.Context) {
+	slog.Info("Method setExchangeRate in controller", "currency", c.Param("currency"), "date", c.Param("date"))
+
+	currency, err := currencyParam(c.Param("currency"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if currency == database.BaseCurrency {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Rate of base currency is always 1"})
+		return
+	}
+
+	if _, err := database.ParseDate(c.Param("date"), false); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var input exchangeRateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rate := &database.ExchangeRate{Currency: currency, ValidFrom: c.Param("date"), Rate: input.Rate}
+	if err := app.models.ExchangeRates.Set(rate); err != nil {
+		slog.ErrorContext(c.Request.Context(), "ERROR in setExchangeRate", "request_id", c.GetString(requestIdKey), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set exchange rate"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rate)
+}
+
+// deleteExchangeRate deletes exchange rate of currency valid from given day
+//
+//	@Summary		deletes exchange rate
+//	@Tags			Admin
+//	@Param			Authorization	header	string	true	"Bearer ADMIN_TOKEN"
+//	@Param			currency	path	string	true	"ISO 4217 code"	example(USD)
+//	@Param			date		path	string	true	"valid from"	example(01-07-2025)
+//	@Success		204
+//	@Router			/api/v1/admin/exchange-rates/{currency}/{date} [delete]
+func (app *application) deleteExchangeRate(c *gin.Context) {
+	slog.Info("Method deleteExchangeRate in controller", "currency", c.Param("currency"), "date", c.Param("date"))
+
+	currency, err := currencyParam(c.Param("currency"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	validFrom, err := database.ParseDate(c.Param("date"), false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := app.models.ExchangeRates.Delete(currency, validFrom); err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Exchange rate not found"})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "ERROR in deleteExchangeRate", "request_id", c.GetString(requestIdKey), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete exchange rate"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// convertPrices fills converted price of subscriptions with exchange rate valid at the first day of current month
+func (app *application) convertPrices(subs []*database.Subscription, currency string) error {
+	rates, err := app.rateTable()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	for _, sub := range subs {
+		converted, err := rates.ConvertMoney(database.Money{Amount: sub.Price, Currency: sub.Currency}, currency, month, database.RoundHalfUp)
+		if err != nil {
+			return err
+		}
+		sub.ConvertedPrice = &converted
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"gin-subscription/internal/database"
+	"gin-subscription/internal/export"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// exportFormat negotiates list format with 'format' query param or Accept header, false means plain JSON page
+func exportFormat(c *gin.Context) (export.Format, bool, error) {
+	if name := c.Query("format"); name != "" {
+		if name == "json" {
+			return "", false, nil
+		}
+		format, ok := export.ParseFormat(name)
+		if !ok {
+			return "", false, fmt.Errorf("unsupported format %q, expected json, csv, ndjson or xlsx", name)
+		}
+		return format, true, nil
+	}
+
+	accepted := c.NegotiateFormat(append([]string{binding.MIMEJSON}, export.ContentTypes()...)...)
+	if accepted == "" || accepted == binding.MIMEJSON {
+		return "", false, nil
+	}
+
+	format, _ := export.ParseFormat(accepted)
+	return format, true, nil
+}
+
+// exportSubscriptions streams all filtered subscriptions page by page, so the whole list is never kept in memory.
+// Pagination params of opts are ignored.
+func (app *application) exportSubscriptions(c *gin.Context, filter *database.Filter, opts database.ListOptions, format export.Format) {
+	opts = database.ListOptions{Limit: export.PageSize, Sort: opts.Sort, IncludeDeleted: opts.IncludeDeleted, AsOf: opts.AsOf}
+
+	// first page is fetched before writing headers, so errors can still be reported with proper status
+	page, err := app.models.Subscriptions.GetList(filter, opts)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive subscriptions"})
+		return
+	}
+
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="subscriptions.%s"`, format))
+	c.Status(http.StatusOK)
+
+	w, err := export.NewWriter(c.Writer, format)
+	if err != nil {
+		slog.Error("ERROR in exportSubscriptions", "error", err)
+		return
+	}
+
+	rows := 0
+	for {
+		for _, sub := range page.Data {
+			if err := w.Write(sub); err != nil {
+				slog.Error("ERROR in exportSubscriptions", "error", err)
+				return
+			}
+			rows++
+		}
+		c.Writer.Flush()
+
+		if page.NextCursor == "" {
+			break
+		}
+
+		opts.Cursor = page.NextCursor
+		page, err = app.models.Subscriptions.GetList(filter, opts)
+		if err != nil {
+			// status is already sent, client gets truncated file
+			slog.Error("ERROR in exportSubscriptions", "error", err, "rows", rows)
+			return
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		slog.Error("ERROR in exportSubscriptions", "error", err)
+		return
+	}
+
+	slog.Info("Subscriptions exported", "format", format, "rows", rows)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"gin-subscription/internal/database"
+	"gin-subscription/internal/env"
+	"gin-subscription/internal/importer"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+)
+
+// importSubscriptions imports subscriptions from CSV or NDJSON request body
+//
+//	@Summary		imports subscriptions from CSV or NDJSON
+//	@Description	request body is streamed file, format is taken from 'format' query param or Content-Type (text/csv, application/x-ndjson)
+//	@Description	CSV must have header with columns user_id, start_date and service_name, service_id or plan_id, other columns are optional
+//	@Description	columns are the same as in CSV export, so exported file can be imported back
+//	@Description	every line is validated with the same rules as on create, report lists rejected lines with reasons
+//	@Description	batch which fails to insert is retried line by line, so every rejected line has its own reason
+//	@Description	when body can't be read further, e.g. NDJSON line is longer than 1 MB, 400 is returned with report of lines
+//	@Description	imported before 'aborted_at' line
+//	@Tags			Subscription
+//	@Accept			plain
+//	@Produce		json
+//	@Param			format		query		string	false	"csv or ndjson"
+//	@Param			batch_size	query		int		false	"number of rows inserted at once, 1000 by default"
+//	@Success		200			{object}	importer.Report
+//	@Failure		400			{object}	importer.Report
+//	@Router			/api/v1/subscription/import [post]
+func (app *application) importSubscriptions(c *gin.Context) {
+	slog.Info("Method importSubscriptions in controller", "format", c.Query("format"), "content_type", c.ContentType())
+
+	formatName := c.Query("format")
+	if formatName == "" {
+		formatName = c.ContentType()
+	}
+
+	format, err := importer.ParseFormat(formatName)
+	if err != nil {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return
+	}
+
+	batchSize, err := queryInt(c, "batch_size")
+	if err != nil || (batchSize != nil && (*batchSize < 1 || *batchSize > 10000)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch_size, expected 1-10000"})
+		return
+	}
+
+	im := &importer.Importer{Subscriptions: app.subscriptions(c)}
+	if batchSize != nil {
+		im.BatchSize = *batchSize
+	}
+
+	report, err := im.Import(c.Request.Body, format)
+	if err != nil && report != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, report)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// runImport is "import" subcommand, which imports file into storage and prints report
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	storage := fs.String("storage", env.GetEnvString("STORAGE", "postgres"), "storage backend: postgres or memory")
+	formatName := fs.String("format", "", "csv or ndjson, detected by file extension by default")
+	batchSize := fs.Int("batch-size", importer.DefaultBatchSize, "number of rows inserted at once")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: api import [flags] <file|->")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path := fs.Arg(0)
+
+	if *formatName == "" && path == "-" {
+		log.Fatal("-format is required when reading from stdin")
+	}
+	if *formatName == "" {
+		*formatName = filepath.Ext(path)
+	}
+	format, err := importer.ParseFormat(*formatName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	input := os.Stdin
+	if path != "-" {
+		input, err = os.Open(path)
+		if err != nil {
+			log.Fatalf("Failed to open file: %v", err)
+		}
+		defer input.Close()
+	}
+
+	models, closeModels := openModels(*storage)
+	defer closeModels()
+
+	im := &importer.Importer{Subscriptions: models.Subscriptions.As(database.Actor{Name: "cli"}), BatchSize: *batchSize}
+
+	report, err := im.Import(input, format)
+	if err != nil && report == nil {
+		log.Fatalf("Failed to import: %v", err)
+	}
+
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	out.Encode(report)
+
+	if err != nil {
+		log.Fatalf("Failed to import: %v", err)
+	}
+}
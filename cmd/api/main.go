@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	_ "gin-subscription/docs"
 	"gin-subscription/internal/database"
@@ -9,44 +10,100 @@ import (
 	"log"
 	"log/slog"
 	"os"
+	"time"
 
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/joho/godotenv/autoload"
 )
 
 type application struct {
-	port   int
-	models database.Models
+	port           int
+	models         database.Models
+	idempotencyTTL time.Duration
+	adminToken     string
+	// planPricePolicy is applied to subscriptions when price of their plan is changed
+	planPricePolicy database.PricePolicy
 }
 
 func main() {
 	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
 	slog.SetDefault(logger)
 
-	host := os.Getenv("DB_HOST")
-	port := os.Getenv("DB_PORT")
-	user := os.Getenv("DB_USER")
-	password := os.Getenv("DB_PASSWORD")
-	dbname := os.Getenv("DB_NAME")
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		runImport(os.Args[2:])
+		return
+	}
 
-	connStr := fmt.Sprintf(
-		"postgres://%s:%s@%s:%s/%s",
-		user, password, host, port, dbname,
-	)
-	db, err := pgx.Connect(context.Background(), connStr)
+	storage := flag.String("storage", env.GetEnvString("STORAGE", "postgres"), "storage backend: postgres or memory")
+	flag.Parse()
+
+	planPricePolicy, err := database.ParsePricePolicy(env.GetEnvString("PLAN_PRICE_POLICY", string(database.PriceKeep)))
 	if err != nil {
-		log.Fatalf("Failed to connect db: %v", err)
+		log.Fatal(err)
 	}
 
-	defer db.Close(context.Background())
+	models, closeModels := openModels(*storage)
+	defer closeModels()
 
-	models := database.NewModels(db)
 	app := &application{
-		port:   env.GetEnvInt("PORT", 8080),
-		models: models,
+		port:            env.GetEnvInt("PORT", 8080),
+		models:          models,
+		idempotencyTTL:  env.GetEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		adminToken:      env.GetEnvString("ADMIN_TOKEN", ""),
+		planPricePolicy: planPricePolicy,
 	}
 
+	go app.purgeIdempotencyKeys(time.Hour)
+	go app.applyPriceChanges(time.Hour)
+
 	if err := app.serve(); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// openModels connects to chosen storage backend, returned function releases its resources
+func openModels(storage string) (database.Models, func()) {
+	switch storage {
+	case "memory":
+		slog.Info("Using in-memory storage")
+		return database.NewMemoryModels(), func() {}
+	case "postgres":
+		db, err := openDB()
+		if err != nil {
+			log.Fatalf("Failed to connect db: %v", err)
+		}
+
+		models := database.NewModels(db)
+
+		if env.GetEnvString("IDEMPOTENCY_STORE", "postgres") == "memory" {
+			models.Idempotency = database.NewMemoryIdempotencyModel()
+		}
+
+		return models, db.Close
+	}
+
+	log.Fatalf("Unknown storage %q", storage)
+	return database.Models{}, nil
+}
+
+func openDB() (*pgxpool.Pool, error) {
+	host := os.Getenv("DB_HOST")
+	port := os.Getenv("DB_PORT")
+	user := os.Getenv("DB_USER")
+	password := os.Getenv("DB_PASSWORD")
+	dbname := os.Getenv("DB_NAME")
+
+	connStr := fmt.Sprintf(
+		"postgres://%s:%s@%s:%s/%s",
+		user, password, host, port, dbname,
+	)
+
+	return database.NewPool(context.Background(), database.PoolConfig{
+		ConnString:        connStr,
+		MaxConns:          int32(env.GetEnvInt("DB_MAX_CONNS", 25)),
+		MinConns:          int32(env.GetEnvInt("DB_MIN_CONNS", 2)),
+		MaxConnLifetime:   env.GetEnvDuration("DB_MAX_CONN_LIFETIME", time.Hour),
+		MaxConnIdleTime:   env.GetEnvDuration("DB_MAX_CONN_IDLE_TIME", 30*time.Minute),
+		HealthCheckPeriod: env.GetEnvDuration("DB_HEALTH_CHECK_PERIOD", time.Minute),
+	})
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"gin-subscription/internal/database"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bodyRecorder keeps copy of response body written by handler
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// replayedHeaders are response headers stored with idempotency record and replayed with its body
+var replayedHeaders = []string{"ETag", "Location"}
+
+// idempotent replays stored response for requests repeated with the same Idempotency-Key header.
+// Reusing key with different request returns 422, concurrent request with the same key returns 409.
+func (app *application) idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > 255 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		hash.Write([]byte(c.Request.Method + " " + c.FullPath() + "\n"))
+		hash.Write(body)
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		rec, err := app.models.Idempotency.Reserve(key, requestHash, app.idempotencyTTL)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check Idempotency-Key"})
+			return
+		}
+
+		if rec != nil {
+			switch {
+			case rec.RequestHash != requestHash:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key is already used with different request"})
+			case rec.StatusCode == 0:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "Request with this Idempotency-Key is in progress"})
+			default:
+				for name, value := range rec.Headers {
+					c.Header(name, value)
+				}
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(rec.StatusCode, "application/json; charset=utf-8", rec.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		// reservation is released unless response is stored, including when handler panics,
+		// so client can retry with the same key
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			if err := app.models.Idempotency.Release(key); err != nil {
+				slog.Error("ERROR in idempotent", "key", key, "error", err)
+			}
+		}()
+
+		c.Next()
+
+		// server errors are not stored, so client can retry with the same key
+		if recorder.Status() >= http.StatusInternalServerError {
+			return
+		}
+
+		headers := make(map[string]string)
+		for _, name := range replayedHeaders {
+			if value := recorder.Header().Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+
+		if err := app.models.Idempotency.Complete(key, recorder.Status(), headers, recorder.body.Bytes()); err != nil {
+			slog.Error("ERROR in idempotent", "key", key, "error", err)
+			return
+		}
+		completed = true
+	}
+}
+
+// purgeIdempotencyKeys periodically deletes expired idempotency records
+func (app *application) purgeIdempotencyKeys(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := app.models.Idempotency.DeleteExpired(); err != nil {
+			slog.Error("ERROR in purgeIdempotencyKeys", "error", err)
+		}
+	}
+}
+
+// authenticate marks request with "Authorization: Bearer <ADMIN_TOKEN>" header as admin request, other requests pass as anonymous
+func (app *application) authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if ok && app.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(app.adminToken)) == 1 {
+			c.Set(adminKey, true)
+		}
+
+		c.Next()
+	}
+}
+
+// requireAdmin allows only admin requests, admin routes are disabled when token is not set
+func (app *application) requireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if app.adminToken == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin endpoints are disabled"})
+			return
+		}
+
+		if !c.GetBool(adminKey) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin token"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+const (
+	requestIdKey = "request_id"
+	adminKey     = "admin"
+)
+
+// requestID takes request id from X-Request-Id header or generates new one, it is returned in the same header
+// and recorded in audit log with changes made by request
+func requestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader("X-Request-Id")
+		if id == "" || len(id) > 255 {
+			id = rand.Text()
+		}
+
+		c.Set(requestIdKey, id)
+		c.Header("X-Request-Id", id)
+
+		c.Next()
+	}
+}
+
+// actorOf returns who makes request. X-Actor header is trusted only on admin requests, which default to "admin",
+// so it can be set by gateway holding admin token. Other requests are made by "anonymous".
+func actorOf(c *gin.Context) database.Actor {
+	name := "anonymous"
+	if c.GetBool(adminKey) {
+		name = c.GetHeader("X-Actor")
+		if name == "" {
+			name = "admin"
+		}
+	}
+	if len(name) > 255 {
+		name = name[:255]
+	}
+
+	return database.Actor{Name: name, RequestId: c.GetString(requestIdKey)}
+}
+
+// subscriptions returns repository which records changes as made by actor of request
+func (app *application) subscriptions(c *gin.Context) database.SubscriptionRepository {
+	return app.models.Subscriptions.As(actorOf(c))
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"gin-subscription/internal/database"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type pauseInput struct {
+	From  string `json:"from" binding:"omitempty,datetime=02-2006|datetime=02-01-2006" example:"01-06-2025"`
+	Until string `json:"until" binding:"omitempty,datetime=02-2006|datetime=02-01-2006" example:"08-2025"`
+}
+
+type resumeInput struct {
+	Date string `json:"date" binding:"omitempty,datetime=02-2006|datetime=02-01-2006" example:"01-09-2025"`
+}
+
+// pauseDate parses optional day of pause or resume, today by default
+func pauseDate(s string, endOfMonth bool) (time.Time, error) {
+	if s == "" {
+		return database.Today(), nil
+	}
+	return database.ParseDate(s, endOfMonth)
+}
+
+func pauseStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, database.ErrRecordNotFound):
+		return http.StatusNotFound, "Subscription not found"
+	case errors.Is(err, database.ErrPauseConflict), errors.Is(err, database.ErrNotPaused):
+		return http.StatusConflict, err.Error()
+	case errors.Is(err, database.ErrPauseOutOfRange):
+		return http.StatusBadRequest, err.Error()
+	}
+
+	fmt.Println(err)
+	return http.StatusInternalServerError, "Failed to change subscription"
+}
+
+// pauseSubscription suspends billing of subscription
+//
+//	@Summary		pauses subscription
+//	@Description	subscription isn't billed from 'from' (today by default) till 'until' inclusive or until it is resumed, both in "dd-mm-yyyy" or "mm-yyyy" format
+//	@Description	409 is returned when subscription is already paused in this period
+//	@Tags			Subscription
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int			true	"Subscription id"
+//	@Param			pause	body		pauseInput	false	"Pause period"
+//	@Success		200		{object}	database.Subscription
+//	@Failure		409
+//	@Router			/api/v1/subscription/{id}/pause [post]
+func (app *application) pauseSubscription(c *gin.Context) {
+	slog.Info("Method pauseSubscription in controller", "id", c.Param("id"))
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		return
+	}
+
+	var input pauseInput
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	from, err := pauseDate(input.From, false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var until *time.Time
+	if input.Until != "" {
+		t, err := database.ParseDate(input.Until, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if t.Before(from) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Pause ends before it starts"})
+			return
+		}
+		until = &t
+	}
+
+	sub, err := app.subscriptions(c).Pause(id, from, until)
+	if err != nil {
+		status, message := pauseStatus(err)
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	c.Header("ETag", subscriptionETag(sub))
+	c.JSON(http.StatusOK, sub)
+}
+
+// resumeSubscription ends pause of subscription
+//
+//	@Summary		resumes subscription
+//	@Description	subscription is billed again from 'date' (today by default), pause which hasn't started yet is cancelled
+//	@Description	409 is returned when subscription is not paused
+//	@Tags			Subscription
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int			true	"Subscription id"
+//	@Param			resume	body		resumeInput	false	"Resume day"
+//	@Success		200		{object}	database.Subscription
+//	@Failure		409
+//	@Router			/api/v1/subscription/{id}/resume [post]
+func (app *application) resumeSubscription(c *gin.Context) {
+	slog.Info("Method resumeSubscription in controller", "id", c.Param("id"))
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		return
+	}
+
+	var input resumeInput
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	at, err := pauseDate(input.Date, false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sub, err := app.subscriptions(c).Resume(id, at)
+	if err != nil {
+		status, message := pauseStatus(err)
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	c.Header("ETag", subscriptionETag(sub))
+	c.JSON(http.StatusOK, sub)
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"gin-subscription/internal/database"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type planUpdateResponse struct {
+	Plan *database.Plan `json:"plan"`
+	// Subscriptions is number of subscriptions which got new price of plan or have it scheduled
+	Subscriptions int `json:"subscriptions"`
+}
+
+// bindPlan reads plan from request body and checks its price
+func bindPlan(c *gin.Context) (*database.Plan, bool) {
+	var p database.Plan
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if p.Price.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Price must be positive"})
+		return nil, false
+	}
+	if _, err := currencyParam(p.Price.Currency); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	return &p, true
+}
+
+// planId returns id of plan from path, responds 400 when it is invalid
+func planId(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid plan ID"})
+		return 0, false
+	}
+
+	return id, true
+}
+
+// listPlans returns plans of service
+//
+//	@Summary		returns plans of service
+//	@Description	plans sorted by price, subscription referencing plan by 'plan_id' gets its price and billing period
+//	@Tags			Service
+//	@Produce		json
+//	@Param			id	path	int	true	"Service id"
+//	@Success		200	{array}	database.Plan
+//	@Failure		404
+//	@Router			/api/v1/services/{id}/plans [get]
+func (app *application) listPlans(c *gin.Context) {
+	id, ok := serviceId(c)
+	if !ok {
+		return
+	}
+
+	s, err := app.models.Services.Get(id)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "ERROR in listPlans", "request_id", c.GetString(requestIdKey), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive plans"})
+		return
+	}
+	if s == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+		return
+	}
+
+	plans, err := app.models.Plans.List(id)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "ERROR in listPlans", "request_id", c.GetString(requestIdKey), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive plans"})
+		return
+	}
+
+	c.JSON(http.StatusOK, plans)
+}
+
+// getPlan returns single plan
+//
+//	@Summary		returns single plan
+//	@Description	returns single plan of service
+//	@Tags			Service
+//	@Produce		json
+//	@Param			id	path		int	true	"Plan id"
+//	@Success		200	{object}	database.Plan
+//	@Failure		404
+//	@Router			/api/v1/plans/{id} [get]
+func (app *application) getPlan(c *gin.Context) {
+	id, ok := planId(c)
+	if !ok {
+		return
+	}
+
+	p, err := app.models.Plans.Get(id)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "ERROR in getPlan", "request_id", c.GetString(requestIdKey), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive plan"})
+		return
+	}
+	if p == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Plan not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
+// createPlan adds plan to service
+//
+//	@Summary		creates plan
+//	@Description	plan name is unique within service ignoring case, billing period is monthly by default
+//	@Tags			Admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string			true	"Bearer ADMIN_TOKEN"
+//	@Param			id				path		int				true	"Service id"
+//	@Param			plan			body		database.Plan	true	"Plan"
+//	@Success		201				{object}	database.Plan
+//	@Failure		404
+//	@Failure		409
+//	@Router			/api/v1/admin/services/{id}/plans [post]
+func (app *application) createPlan(c *gin.Context) {
+	id, ok := serviceId(c)
+	if !ok {
+		return
+	}
+
+	p, ok := bindPlan(c)
+	if !ok {
+		return
+	}
+	p.ServiceId = id
+
+	if err := app.models.Plans.Insert(p); err != nil {
+		if errors.Is(err, database.ErrServiceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+			return
+		}
+		if errors.Is(err, database.ErrPlanNameConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "ERROR in createPlan", "request_id", c.GetString(requestIdKey), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create plan"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, p)
+}
+
+// updatePlan replaces plan
+//
+//	@Summary		updates plan
+//	@Description	when price is changed, subscriptions on plan which have its previous price get new one according to 'price_policy':
+//	@Description	'keep' leaves their price, 'immediate' changes it from today and 'next_cycle' schedules it from next billing date of every subscription.
+//	@Description	PLAN_PRICE_POLICY is used by default. Subscriptions with custom price and changed billing period of plan are not affected
+//	@Tags			Admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string			true	"Bearer ADMIN_TOKEN"
+//	@Param			id				path		int				true	"Plan id"
+//	@Param			price_policy	query		string			false	"keep, immediate or next_cycle"
+//	@Param			plan			body		database.Plan	true	"Plan"
+//	@Success		200				{object}	planUpdateResponse
+//	@Failure		404
+//	@Failure		409
+//	@Router			/api/v1/admin/plans/{id} [put]
+func (app *application) updatePlan(c *gin.Context) {
+	slog.Info("Method updatePlan in controller", "id", c.Param("id"), "price_policy", c.Query("price_policy"))
+
+	id, ok := planId(c)
+	if !ok {
+		return
+	}
+
+	policy := app.planPricePolicy
+	if s := c.Query("price_policy"); s != "" {
+		var err error
+		if policy, err = database.ParsePricePolicy(s); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	p, ok := bindPlan(c)
+	if !ok {
+		return
+	}
+	p.Id = id
+
+	changed, err := app.models.Plans.As(actorOf(c)).Update(p, policy)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Plan not found"})
+			return
+		}
+		if errors.Is(err, database.ErrPlanNameConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "ERROR in updatePlan", "request_id", c.GetString(requestIdKey), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update plan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, planUpdateResponse{Plan: p, Subscriptions: changed})
+}
+
+// deletePlan deletes plan without subscriptions
+//
+//	@Summary		deletes plan
+//	@Description	plan which has subscriptions, including deleted ones, can't be deleted
+//	@Tags			Admin
+//	@Param			Authorization	header	string	true	"Bearer ADMIN_TOKEN"
+//	@Param			id				path	int		true	"Plan id"
+//	@Success		204
+//	@Failure		404
+//	@Failure		409
+//	@Router			/api/v1/admin/plans/{id} [delete]
+func (app *application) deletePlan(c *gin.Context) {
+	slog.Info("Method deletePlan in controller", "id", c.Param("id"))
+
+	id, ok := planId(c)
+	if !ok {
+		return
+	}
+
+	if err := app.models.Plans.Delete(id); err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Plan not found"})
+			return
+		}
+		if errors.Is(err, database.ErrPlanInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "ERROR in deletePlan", "request_id", c.GetString(requestIdKey), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete plan"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
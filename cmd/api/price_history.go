@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"gin-subscription/internal/database"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type priceChangeInput struct {
+	Price         json.Number `json:"price" binding:"required" swaggertype:"string" example:"499.00"`
+	EffectiveFrom string      `json:"effective_from" binding:"required,datetime=02-2006|datetime=02-01-2006" example:"01-2026"`
+}
+
+// getPriceHistory returns price changes of subscription
+//
+//	@Summary		returns price history of subscription
+//	@Description	every price is effective from given day until next change, changes in the future are scheduled ones
+//	@Tags			Subscription
+//	@Produce		json
+//	@Param			id	path	int	true	"Subscription id"
+//	@Success		200	{array}	database.PriceChange
+//	@Router			/api/v1/subscription/{id}/prices [get]
+func (app *application) getPriceHistory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		return
+	}
+
+	history, err := app.models.Subscriptions.PriceHistory(id)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "ERROR in getPriceHistory", "request_id", c.GetString(requestIdKey), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive price history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// schedulePriceChange sets future price of subscription
+//
+//	@Summary		schedules price change
+//	@Description	price in subscription currency becomes effective from day in "dd-mm-yyyy" or "mm-yyyy" format, which must be in the future
+//	@Description	change scheduled for the same day is replaced, use PUT or PATCH to change price from today
+//	@Tags			Subscription
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Subscription id"
+//	@Param			change	body		priceChangeInput	true	"New price"
+//	@Success		201		{object}	database.PriceChange
+//	@Router			/api/v1/subscription/{id}/prices [post]
+func (app *application) schedulePriceChange(c *gin.Context) {
+	slog.Info("Method schedulePriceChange in controller", "id", c.Param("id"))
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		return
+	}
+
+	var input priceChangeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	effectiveFrom, err := database.ParseDate(input.EffectiveFrom, false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !effectiveFrom.After(database.Today()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "effective_from must be in the future, use PUT or PATCH to change current price"})
+		return
+	}
+
+	sub, err := app.models.Subscriptions.Get(id)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "ERROR in schedulePriceChange", "request_id", c.GetString(requestIdKey), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive subscription"})
+		return
+	}
+	if sub == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+		return
+	}
+
+	if sub.EndDate != "" {
+		_, endDate, _ := database.ParseDates(sub.StartDate, sub.EndDate)
+		if effectiveFrom.After(*endDate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "effective_from is after subscription end date"})
+			return
+		}
+	}
+
+	price, err := database.ParsePrice(input.Price.String(), sub.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	change, err := app.subscriptions(c).SchedulePriceChange(id, effectiveFrom, database.Money{Amount: price, Currency: sub.Currency})
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "ERROR in schedulePriceChange", "request_id", c.GetString(requestIdKey), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule price change"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, change)
+}
+
+// cancelPriceChange deletes scheduled price change
+//
+//	@Summary		cancels scheduled price change
+//	@Description	only changes which are not effective yet may be cancelled
+//	@Tags			Subscription
+//	@Param			id		path	int		true	"Subscription id"
+//	@Param			date	path	string	true	"effective from"	example(01-01-2026)
+//	@Success		204
+//	@Router			/api/v1/subscription/{id}/prices/{date} [delete]
+func (app *application) cancelPriceChange(c *gin.Context) {
+	slog.Info("Method cancelPriceChange in controller", "id", c.Param("id"), "date", c.Param("date"))
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		return
+	}
+
+	effectiveFrom, err := database.ParseDate(c.Param("date"), false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := app.subscriptions(c).CancelPriceChange(id, effectiveFrom); err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled price change not found"})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "ERROR in cancelPriceChange", "request_id", c.GetString(requestIdKey), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel price change"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// applyPriceChanges periodically sets current price of subscriptions whose scheduled price became effective
+func (app *application) applyPriceChanges(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		changed, err := app.models.Subscriptions.ApplyPriceChanges(database.Today())
+		if err != nil {
+			slog.Error("ERROR in applyPriceChanges", "error", err)
+		} else if changed > 0 {
+			slog.Info("Scheduled prices applied", "subscriptions", changed)
+		}
+
+		<-ticker.C
+	}
+}
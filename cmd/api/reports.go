@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"gin-subscription/internal/database"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// spendQuery is period, grouping and ordering params of spend report
+type spendQuery struct {
+	From      string `form:"from" binding:"required"`
+	To        string `form:"to"`
+	GroupBy   string `form:"group_by"`
+	Sort      string `form:"sort"`
+	Limit     int    `form:"limit" binding:"omitempty,min=1"`
+	Proration string `form:"proration"`
+	Rounding  string `form:"rounding"`
+}
+
+// getSpendReport returns cost of subscriptions within period grouped by user, service and month
+//
+//	@Summary		returns spend grouped by user, service or month
+//	@Description	period is given with 'from' and optional 'to' (current date by default) in "mm-yyyy" or "dd-mm-yyyy" format, 'to' month is included till its last day
+//	@Description	'group_by' is comma separated list of user_id, service_name and month, without it single total is returned
+//	@Description	'sort' may use total, subscriptions and grouped fields, '-' prefix for descending order, '-total' by default, 'limit' returns top N groups
+//	@Description	filters of subscription list are supported as well
+//	@Tags			Reports
+//	@Accept			json
+//	@Produce		json
+//	@Param			from			query		string	true	"period start"	example(01-2025)
+//	@Param			to				query		string	false	"period end"	example(12-2025)
+//	@Param			group_by		query		string	false	"comma separated groups"	example(service_name,month)
+//	@Param			sort			query		string	false	"comma separated fields"	example(-total)
+//	@Param			limit			query		int		false	"number of top groups"
+//	@Param			proration		query		string	false	"monthly, daily or 30/360"
+//	@Param			rounding		query		string	false	"half_up (default), half_even, down or up"
+//	@Param			user_id			query		string	false	"filter for concrete users, comma separated"
+//	@Param			service_name	query		string	false	"filter for concrete service"
+//	@Param			service_id		query		int	false	"filter for service from catalog"
+//	@Success		200				{object}	database.SpendReport
+//	@Router			/api/v1/reports/spend [get]
+func (app *application) getSpendReport(c *gin.Context) {
+	slog.Info("Method getSpendReport in controller", "query", c.Request.URL.Query())
+
+	var query spendQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	start, err := database.ParseDate(query.From, false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid from %q, mm-yyyy or dd-mm-yyyy expected", query.From)})
+		return
+	}
+
+	now := time.Now().UTC()
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if query.To != "" {
+		end, err = database.ParseDate(query.To, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid to %q, mm-yyyy or dd-mm-yyyy expected", query.To)})
+			return
+		}
+	}
+
+	if end.Before(start) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Period end is before its start"})
+		return
+	}
+
+	opts := database.SpendOptions{Limit: query.Limit}
+
+	if opts.Proration, err = database.ParseProration(query.Proration); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if opts.Rounding, err = database.ParseRounding(query.Rounding); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if opts.GroupBy, err = database.ParseSpendGroups(query.GroupBy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if opts.Sort, err = database.ParseSpendSort(query.Sort, opts.GroupBy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter, err := subscriptionFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := app.models.Subscriptions.GetSpend(start, end, filter, opts)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive spend report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// churnQuery is period and grouping params of churn report
+type churnQuery struct {
+	From    string `form:"from" binding:"required"`
+	To      string `form:"to"`
+	GroupBy string `form:"group_by"`
+}
+
+// getChurnReport returns number of cancellations within period grouped by reason, service and month
+//
+//	@Summary		returns cancellations grouped by reason, service or month
+//	@Description	counts cancellations whose effective date is within period given with 'from' and optional 'to' (current date by default) in "mm-yyyy" or "dd-mm-yyyy" format
+//	@Description	'group_by' is comma separated list of reason, service_name and month, 'reason' by default, groups are sorted by number of cancellations descending
+//	@Description	filters of subscription list are supported as well
+//	@Tags			Reports
+//	@Produce		json
+//	@Param			from			query		string	true	"period start"	example(01-2025)
+//	@Param			to				query		string	false	"period end"	example(12-2025)
+//	@Param			group_by		query		string	false	"comma separated groups"	example(reason,month)
+//	@Param			user_id			query		string	false	"filter for concrete users, comma separated"
+//	@Param			service_name	query		string	false	"filter for concrete service"
+//	@Param			service_id		query		int	false	"filter for service from catalog"
+//	@Success		200				{object}	database.ChurnReport
+//	@Router			/api/v1/reports/churn [get]
+func (app *application) getChurnReport(c *gin.Context) {
+	slog.Info("Method getChurnReport in controller", "query", c.Request.URL.Query())
+
+	var query churnQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	start, err := database.ParseDate(query.From, false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid from %q, mm-yyyy or dd-mm-yyyy expected", query.From)})
+		return
+	}
+
+	end := database.Today()
+	if query.To != "" {
+		end, err = database.ParseDate(query.To, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid to %q, mm-yyyy or dd-mm-yyyy expected", query.To)})
+			return
+		}
+	}
+
+	if end.Before(start) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Period end is before its start"})
+		return
+	}
+
+	groupBy, err := database.ParseChurnGroups(query.GroupBy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter, err := subscriptionFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := app.models.Subscriptions.GetChurn(start, end, filter, groupBy)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive churn report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
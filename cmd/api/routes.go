@@ -10,15 +10,50 @@ import (
 
 func (app *application) routes() http.Handler {
 	g := gin.Default()
+	g.Use(requestID(), app.authenticate())
 
 	v1 := g.Group("/api/v1")
 	{
 		v1.GET("/subscription", app.listSubscription)
-		v1.POST("/subscription", app.createSubscription)
+		v1.POST("/subscription", app.idempotent(), app.createSubscription)
+		v1.POST("/subscription/batch", app.idempotent(), app.batchSubscription)
+		v1.POST("/subscription/import", app.importSubscriptions)
 		v1.GET("/subscription/:id", app.getSubscription)
 		v1.PUT("/subscription/:id", app.updateSubscription)
+		v1.PATCH("/subscription/:id", app.patchSubscription)
 		v1.DELETE("/subscription/:id", app.deleteSubscription)
+		v1.GET("/subscription/:id/prices", app.getPriceHistory)
+		v1.POST("/subscription/:id/prices", app.schedulePriceChange)
+		v1.DELETE("/subscription/:id/prices/:date", app.cancelPriceChange)
+		v1.POST("/subscription/:id/pause", app.pauseSubscription)
+		v1.POST("/subscription/:id/resume", app.resumeSubscription)
+		v1.POST("/subscription/:id/cancel", app.cancelSubscription)
+		v1.POST("/subscription/:id/reactivate", app.reactivateSubscription)
+		v1.POST("/subscription/:id/restore", app.restoreSubscription)
+		v1.GET("/subscription/:id/history", app.getSubscriptionHistory)
 		v1.GET("/subscription/period-price/:period", app.getPeriodPrice)
+
+		v1.GET("/services", app.listServices)
+		v1.GET("/services/:id", app.getService)
+		v1.GET("/services/:id/plans", app.listPlans)
+		v1.GET("/plans/:id", app.getPlan)
+
+		v1.GET("/reports/spend", app.getSpendReport)
+		v1.GET("/reports/churn", app.getChurnReport)
+
+		admin := v1.Group("/admin", app.requireAdmin())
+		admin.GET("/exchange-rates", app.listExchangeRates)
+		admin.PUT("/exchange-rates/:currency/:date", app.setExchangeRate)
+		admin.DELETE("/exchange-rates/:currency/:date", app.deleteExchangeRate)
+		admin.DELETE("/subscription", app.purgeDeletedSubscriptions)
+		admin.DELETE("/subscription/:id", app.purgeSubscription)
+		admin.POST("/services", app.createService)
+		admin.PUT("/services/:id", app.updateService)
+		admin.DELETE("/services/:id", app.deleteService)
+		admin.POST("/services/:id/merge", app.mergeServices)
+		admin.POST("/services/:id/plans", app.createPlan)
+		admin.PUT("/plans/:id", app.updatePlan)
+		admin.DELETE("/plans/:id", app.deletePlan)
 	}
 
 	g.GET("/swagger/*any", func(c *gin.Context) {
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gin-subscription/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter returns router over memory storage seeded with subscriptions:
+// 1 Yandex Plus 400.00 of user 1 from 07-2025,
+// 2 Netflix 100.00 of user 2 from 01-2025 till 05-2025,
+// 3 Spotify 250.00 of user 1 from 03-2025 till 12-2025
+func newTestRouter(t *testing.T) http.Handler {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	app := &application{
+		models:          database.NewMemoryModels(),
+		idempotencyTTL:  time.Hour,
+		adminToken:      "secret",
+		planPricePolicy: database.PriceKeep,
+	}
+	router := app.routes()
+
+	for _, body := range []string{
+		`{"service_name": "Yandex Plus", "price": "400", "user_id": 1, "start_date": "07-2025"}`,
+		`{"service_name": "Netflix", "price": "100", "user_id": 2, "start_date": "01-2025", "end_date": "05-2025"}`,
+		`{"service_name": "Spotify", "price": "250", "user_id": 1, "start_date": "03-2025", "end_date": "12-2025"}`,
+	} {
+		if rec := serve(router, http.MethodPost, "/api/v1/subscription", body, nil); rec.Code != http.StatusCreated {
+			t.Fatalf("seed %s: status %d, body %s", body, rec.Code, rec.Body)
+		}
+	}
+
+	return router
+}
+
+func serve(router http.Handler, method, target, body string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSubscriptionRoutes(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		header     map[string]string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:   "create",
+			method: http.MethodPost, target: "/api/v1/subscription",
+			body:       `{"service_name": "Kinopoisk", "price": "299.90", "user_id": 3, "start_date": "01-2026"}`,
+			wantStatus: http.StatusCreated, wantBody: `"price":"299.90"`,
+		},
+		{
+			name:   "create without user",
+			method: http.MethodPost, target: "/api/v1/subscription",
+			body:       `{"service_name": "Kinopoisk", "price": "299.90", "start_date": "01-2026"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "create with zero price",
+			method: http.MethodPost, target: "/api/v1/subscription",
+			body:       `{"service_name": "Kinopoisk", "price": "0", "user_id": 3, "start_date": "01-2026"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "get",
+			method: http.MethodGet, target: "/api/v1/subscription/2",
+			wantStatus: http.StatusOK, wantBody: `"service_name":"Netflix"`,
+		},
+		{
+			name:   "get missing",
+			method: http.MethodGet, target: "/api/v1/subscription/42",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:   "get invalid id",
+			method: http.MethodGet, target: "/api/v1/subscription/abc",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "update",
+			method: http.MethodPut, target: "/api/v1/subscription/1",
+			body:       `{"service_name": "Yandex Plus", "price": "450", "user_id": 1, "start_date": "07-2025"}`,
+			wantStatus: http.StatusOK, wantBody: `"price":"450.00"`,
+		},
+		{
+			name:   "update with stale etag",
+			method: http.MethodPut, target: "/api/v1/subscription/1",
+			body:       `{"service_name": "Yandex Plus", "price": "450", "user_id": 1, "start_date": "07-2025"}`,
+			header:     map[string]string{"If-Match": `"stale"`},
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:   "delete",
+			method: http.MethodDelete, target: "/api/v1/subscription/2",
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:   "delete missing",
+			method: http.MethodDelete, target: "/api/v1/subscription/42",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:   "period price",
+			method: http.MethodGet, target: "/api/v1/subscription/period-price/07-2025:08-2025",
+			wantStatus: http.StatusOK, wantBody: `"total":{"amount":"1300.00","currency":"RUB"}`,
+		},
+		{
+			name:   "period price of user",
+			method: http.MethodGet, target: "/api/v1/subscription/period-price/01-2025:12-2025?user_id=2",
+			wantStatus: http.StatusOK, wantBody: `"total":{"amount":"500.00","currency":"RUB"}`,
+		},
+		{
+			name:   "period price with end before start",
+			method: http.MethodGet, target: "/api/v1/subscription/period-price/08-2025:07-2025",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "admin route without token",
+			method: http.MethodDelete, target: "/api/v1/admin/subscription/1",
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestRouter(t)
+
+			rec := serve(router, tt.method, tt.target, tt.body, tt.header)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", rec.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestGetSubscriptionNotModified(t *testing.T) {
+	router := newTestRouter(t)
+
+	rec := serve(router, http.MethodGet, "/api/v1/subscription/1", "", nil)
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" {
+		t.Fatalf("status = %d, ETag = %q", rec.Code, etag)
+	}
+
+	rec = serve(router, http.MethodGet, "/api/v1/subscription/1", "", map[string]string{"If-None-Match": etag})
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("status with If-None-Match = %d, want %d", rec.Code, http.StatusNotModified)
+	}
+
+	serve(router, http.MethodPut, "/api/v1/subscription/1", `{"service_name": "Yandex Plus", "price": "450", "user_id": 1, "start_date": "07-2025"}`, nil)
+
+	rec = serve(router, http.MethodGet, "/api/v1/subscription/1", "", map[string]string{"If-None-Match": etag})
+	if rec.Code != http.StatusOK {
+		t.Errorf("status with If-None-Match after update = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestListSubscriptionRoutes(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		want       [][]int
+	}{
+		{name: "all", want: [][]int{{1, 2, 3}}},
+		{name: "by user", query: "user_id=1", want: [][]int{{1, 3}}},
+		{name: "by price", query: "price_min=200&price_max=300", want: [][]int{{3}}},
+		{name: "search", query: "search=flix", want: [][]int{{2}}},
+		{name: "sorted", query: "sort=-price", want: [][]int{{1, 3, 2}}},
+		{name: "pages", query: "sort=price&limit=2", want: [][]int{{2, 3}, {1}}},
+		{name: "pages by id", query: "limit=1", want: [][]int{{1}, {2}, {3}}},
+		{name: "invalid sort", query: "sort=currency", wantStatus: http.StatusBadRequest},
+		{name: "invalid cursor", query: "cursor=garbage", wantStatus: http.StatusBadRequest},
+	}
+
+	router := newTestRouter(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantStatus := tt.wantStatus
+			if wantStatus == 0 {
+				wantStatus = http.StatusOK
+			}
+
+			var got [][]int
+			target := "/api/v1/subscription?" + tt.query
+			for {
+				rec := serve(router, http.MethodGet, target, "", nil)
+				if rec.Code != wantStatus {
+					t.Fatalf("GET %s status = %d, want %d, body %s", target, rec.Code, wantStatus, rec.Body)
+				}
+				if wantStatus != http.StatusOK {
+					return
+				}
+
+				var page struct {
+					Data []struct {
+						Id int `json:"id"`
+					} `json:"data"`
+					NextCursor string `json:"next_cursor"`
+				}
+				if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+					t.Fatalf("GET %s body %s: %v", target, rec.Body, err)
+				}
+
+				ids := []int{}
+				for _, sub := range page.Data {
+					ids = append(ids, sub.Id)
+				}
+				got = append(got, ids)
+
+				if page.NextCursor == "" || len(got) > len(tt.want) {
+					break
+				}
+				target = "/api/v1/subscription?" + tt.query + "&cursor=" + page.NextCursor
+			}
+
+			if !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("pages = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIdempotentReplay(t *testing.T) {
+	router := newTestRouter(t)
+
+	body := `{"service_name": "Kinopoisk", "price": "299.90", "user_id": 3, "start_date": "01-2026"}`
+	header := map[string]string{"Idempotency-Key": "create-kinopoisk"}
+
+	first := serve(router, http.MethodPost, "/api/v1/subscription", body, header)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body %s", first.Code, first.Body)
+	}
+
+	replay := serve(router, http.MethodPost, "/api/v1/subscription", body, header)
+	if replay.Code != http.StatusCreated || replay.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("replay status = %d, Idempotent-Replayed = %q", replay.Code, replay.Header().Get("Idempotent-Replayed"))
+	}
+	if replay.Body.String() != first.Body.String() {
+		t.Errorf("replay body = %s, want %s", replay.Body, first.Body)
+	}
+	for _, name := range []string{"ETag", "Location"} {
+		if got, want := replay.Header().Get(name), first.Header().Get(name); got != want || want == "" {
+			t.Errorf("replay %s = %q, want %q", name, got, want)
+		}
+	}
+
+	rec := serve(router, http.MethodGet, "/api/v1/subscription?limit=10&with_total=true", "", nil)
+	if !strings.Contains(rec.Body.String(), `"total":4`) {
+		t.Errorf("list after replay = %s, want 4 subscriptions", rec.Body)
+	}
+
+	rec = serve(router, http.MethodPost, "/api/v1/subscription", strings.Replace(body, "299.90", "199.90", 1), header)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status with other body = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestBatchNotAppliedInTime(t *testing.T) {
+	router := newTestRouter(t)
+
+	body := `{"mode": "partial", "operations": [
+		{"op": "create", "subscription": {"service_name": "Kinopoisk", "price": "299.90", "user_id": 3, "start_date": "01-2026"}},
+		{"op": "delete", "id": 1}
+	]}`
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/api/v1/subscription/batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d, body %s", rec.Code, http.StatusServiceUnavailable, rec.Body)
+	}
+
+	rec = serve(router, http.MethodGet, "/api/v1/subscription?with_total=true", "", nil)
+	if !strings.Contains(rec.Body.String(), `"total":3`) {
+		t.Errorf("list after batch = %s, want 3 subscriptions", rec.Body)
+	}
+}
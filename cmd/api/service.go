@@ -0,0 +1,278 @@
+package main
+
+import (
+	"errors"
+	"gin-subscription/internal/database"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type mergeServicesInput struct {
+	ServiceIds []int `json:"service_ids" binding:"required,min=1,dive,min=1"`
+}
+
+// serviceRefError responds 422 when subscription references unknown service or plan or has no price, returns false for other errors
+func serviceRefError(c *gin.Context, err error) bool {
+	if isServiceRefError(err) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return true
+	}
+
+	return false
+}
+
+func isServiceRefError(err error) bool {
+	for _, target := range []error{database.ErrServiceNotFound, database.ErrPriceRequired, database.ErrPlanNotFound, database.ErrPlanMismatch} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// bindService reads service from request body and checks its default price
+func bindService(c *gin.Context) (*database.Service, bool) {
+	var s database.Service
+	if err := c.ShouldBindJSON(&s); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if s.DefaultPrice != nil {
+		if s.DefaultPrice.Amount <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Default price must be positive"})
+			return nil, false
+		}
+		if _, err := currencyParam(s.DefaultPrice.Currency); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
+		}
+	}
+
+	return &s, true
+}
+
+// serviceId returns id of service from path, responds 400 when it is invalid
+func serviceId(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service ID"})
+		return 0, false
+	}
+
+	return id, true
+}
+
+// listServices returns service catalog
+//
+//	@Summary		returns service catalog
+//	@Description	services sorted by name, subscriptions reference service by 'service_id' or by its name or alias
+//	@Tags			Service
+//	@Produce		json
+//	@Param			category	query	string	false	"filter for category"	example(video)
+//	@Success		200			{array}	database.Service
+//	@Router			/api/v1/services [get]
+func (app *application) listServices(c *gin.Context) {
+	services, err := app.models.Services.List(c.Query("category"))
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "ERROR in listServices", "request_id", c.GetString(requestIdKey), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive services"})
+		return
+	}
+
+	c.JSON(http.StatusOK, services)
+}
+
+// getService returns single service
+//
+//	@Summary		returns single service
+//	@Description	returns single service of catalog
+//	@Tags			Service
+//	@Produce		json
+//	@Param			id	path		int	true	"Service id"
+//	@Success		200	{object}	database.Service
+//	@Failure		404
+//	@Router			/api/v1/services/{id} [get]
+func (app *application) getService(c *gin.Context) {
+	id, ok := serviceId(c)
+	if !ok {
+		return
+	}
+
+	s, err := app.models.Services.Get(id)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "ERROR in getService", "request_id", c.GetString(requestIdKey), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive service"})
+		return
+	}
+	if s == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, s)
+}
+
+// createService adds service to catalog
+//
+//	@Summary		creates service
+//	@Description	name and aliases are matched ignoring case and repeated spaces and must not be used by other service
+//	@Tags			Admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string				true	"Bearer ADMIN_TOKEN"
+//	@Param			service			body		database.Service	true	"Service"
+//	@Success		201				{object}	database.Service
+//	@Failure		409
+//	@Router			/api/v1/admin/services [post]
+func (app *application) createService(c *gin.Context) {
+	s, ok := bindService(c)
+	if !ok {
+		return
+	}
+
+	if err := app.models.Services.As(actorOf(c)).Insert(s); err != nil {
+		if errors.Is(err, database.ErrServiceNameConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "ERROR in createService", "request_id", c.GetString(requestIdKey), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create service"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, s)
+}
+
+// updateService replaces service of catalog
+//
+//	@Summary		updates service
+//	@Description	subscriptions of renamed service get its new name, old name should be kept as alias to match it
+//	@Tags			Admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string				true	"Bearer ADMIN_TOKEN"
+//	@Param			id				path		int					true	"Service id"
+//	@Param			service			body		database.Service	true	"Service"
+//	@Success		200				{object}	database.Service
+//	@Failure		404
+//	@Failure		409
+//	@Router			/api/v1/admin/services/{id} [put]
+func (app *application) updateService(c *gin.Context) {
+	slog.Info("Method updateService in controller", "id", c.Param("id"))
+
+	id, ok := serviceId(c)
+	if !ok {
+		return
+	}
+
+	s, ok := bindService(c)
+	if !ok {
+		return
+	}
+	s.Id = id
+
+	if err := app.models.Services.As(actorOf(c)).Update(s); err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+			return
+		}
+		if errors.Is(err, database.ErrServiceNameConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "ERROR in updateService", "request_id", c.GetString(requestIdKey), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update service"})
+		return
+	}
+
+	c.JSON(http.StatusOK, s)
+}
+
+// deleteService deletes service without subscriptions
+//
+//	@Summary		deletes service
+//	@Description	service which has subscriptions, including deleted ones, can't be deleted, merge it into other service instead
+//	@Tags			Admin
+//	@Param			Authorization	header	string	true	"Bearer ADMIN_TOKEN"
+//	@Param			id				path	int		true	"Service id"
+//	@Success		204
+//	@Failure		404
+//	@Failure		409
+//	@Router			/api/v1/admin/services/{id} [delete]
+func (app *application) deleteService(c *gin.Context) {
+	slog.Info("Method deleteService in controller", "id", c.Param("id"))
+
+	id, ok := serviceId(c)
+	if !ok {
+		return
+	}
+
+	if err := app.models.Services.As(actorOf(c)).Delete(id); err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+			return
+		}
+		if errors.Is(err, database.ErrServiceInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "ERROR in deleteService", "request_id", c.GetString(requestIdKey), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete service"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// mergeServices merges duplicate services into one
+//
+//	@Summary		merges services
+//	@Description	subscriptions and plans of services from 'service_ids' are moved to service with id, names and aliases of merged services
+//	@Description	become its aliases and merged services are deleted, 409 is returned when plans of merged services have the same name
+//	@Tags			Admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string				true	"Bearer ADMIN_TOKEN"
+//	@Param			id				path		int					true	"Service id"
+//	@Param			merge			body		mergeServicesInput	true	"Merged services"
+//	@Success		200				{object}	database.Service
+//	@Failure		404
+//	@Failure		409
+//	@Router			/api/v1/admin/services/{id}/merge [post]
+func (app *application) mergeServices(c *gin.Context) {
+	slog.Info("Method mergeServices in controller", "id", c.Param("id"))
+
+	id, ok := serviceId(c)
+	if !ok {
+		return
+	}
+
+	var input mergeServicesInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	slices.Sort(input.ServiceIds)
+
+	s, err := app.models.Services.As(actorOf(c)).Merge(id, slices.Compact(input.ServiceIds))
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+			return
+		}
+		if errors.Is(err, database.ErrPlanNameConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "ERROR in mergeServices", "request_id", c.GetString(requestIdKey), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge services"})
+		return
+	}
+
+	c.JSON(http.StatusOK, s)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"gin-subscription/internal/database"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// restoreSubscription brings back deleted subscription
+//
+//	@Summary		restores deleted subscription
+//	@Description	409 is returned when subscription isn't deleted
+//	@Tags			Subscription
+//	@Produce		json
+//	@Param			id	path		int	true	"Subscription id"
+//	@Success		200	{object}	database.Subscription
+//	@Failure		409
+//	@Router			/api/v1/subscription/{id}/restore [post]
+func (app *application) restoreSubscription(c *gin.Context) {
+	slog.Info("Method restoreSubscription in controller", "id", c.Param("id"))
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		return
+	}
+
+	sub, err := app.subscriptions(c).Restore(id)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+			return
+		}
+		if errors.Is(err, database.ErrNotDeleted) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "ERROR in restoreSubscription", "request_id", c.GetString(requestIdKey), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore subscription"})
+		return
+	}
+
+	c.Header("ETag", subscriptionETag(sub))
+	c.JSON(http.StatusOK, sub)
+}
+
+// purgeSubscription permanently deletes subscription
+//
+//	@Summary		purges deleted subscription
+//	@Description	subscription with its price history, pauses and cancellation is deleted permanently and disappears from reports, only deleted subscription may be purged
+//	@Tags			Admin
+//	@Param			Authorization	header	string	true	"Bearer ADMIN_TOKEN"
+//	@Param			id				path	int		true	"Subscription id"
+//	@Success		204
+//	@Failure		409
+//	@Router			/api/v1/admin/subscription/{id} [delete]
+func (app *application) purgeSubscription(c *gin.Context) {
+	slog.Info("Method purgeSubscription in controller", "id", c.Param("id"))
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		return
+	}
+
+	if err := app.subscriptions(c).Purge(id); err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+			return
+		}
+		if errors.Is(err, database.ErrNotDeleted) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Subscription must be deleted before it is purged"})
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "ERROR in purgeSubscription", "request_id", c.GetString(requestIdKey), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge subscription"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// purgeDeletedSubscriptions permanently deletes subscriptions deleted before given day
+//
+//	@Summary		purges deleted subscriptions
+//	@Description	subscriptions deleted before 'deleted_before' day in "dd-mm-yyyy" or "mm-yyyy" format are deleted permanently, response has number of purged subscriptions
+//	@Tags			Admin
+//	@Produce		json
+//	@Param			Authorization	header	string	true	"Bearer ADMIN_TOKEN"
+//	@Param			deleted_before	query	string	true	"deleted before day"	example(01-01-2026)
+//	@Success		200
+//	@Router			/api/v1/admin/subscription [delete]
+func (app *application) purgeDeletedSubscriptions(c *gin.Context) {
+	slog.Info("Method purgeDeletedSubscriptions in controller", "deleted_before", c.Query("deleted_before"))
+
+	if c.Query("deleted_before") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "deleted_before is required"})
+		return
+	}
+
+	before, err := queryDate(c, "deleted_before", false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	purged, err := app.subscriptions(c).PurgeDeleted(*before)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "ERROR in purgeDeletedSubscriptions", "request_id", c.GetString(requestIdKey), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge subscriptions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"purged": purged})
+}
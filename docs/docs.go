@@ -15,41 +15,47 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/v1/subscription": {
+        "/api/v1/admin/exchange-rates": {
             "get": {
-                "description": "returns list of all subscriptions",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "rate is price of one unit of currency in RUB, valid from given day until next rate of the same currency",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Subscription"
+                    "Admin"
                 ],
-                "summary": "returns list of all subscriptions",
+                "summary": "returns exchange rates",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "filter for concrete user",
-                        "name": "user_id",
-                        "in": "query"
+                        "type": "string",
+                        "description": "Bearer ADMIN_TOKEN",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
                     },
                     {
                         "type": "string",
-                        "description": "filter for concrete service",
-                        "name": "service_name",
+                        "description": "ISO 4217 code",
+                        "name": "currency",
                         "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK"
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/database.ExchangeRate"
+                            }
+                        }
                     }
                 }
-            },
-            "post": {
-                "description": "creates new subscription",
+            }
+        },
+        "/api/v1/admin/exchange-rates/{currency}/{date}": {
+            "put": {
+                "description": "creates or replaces rate of currency valid from day in \"dd-mm-yyyy\" or \"mm-yyyy\" format",
                 "consumes": [
                     "application/json"
                 ],
@@ -57,33 +63,92 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Subscription"
+                    "Admin"
                 ],
-                "summary": "creates new subscription",
+                "summary": "sets exchange rate",
                 "parameters": [
                     {
-                        "description": "Name of subscription provider",
-                        "name": "subscription",
+                        "type": "string",
+                        "description": "Bearer ADMIN_TOKEN",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "USD",
+                        "description": "ISO 4217 code",
+                        "name": "currency",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "01-07-2025",
+                        "description": "valid from",
+                        "name": "date",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "price of one unit in RUB",
+                        "name": "rate",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/database.Subscription"
+                            "$ref": "#/definitions/main.exchangeRateInput"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/database.Subscription"
+                            "$ref": "#/definitions/database.ExchangeRate"
                         }
                     }
                 }
+            },
+            "delete": {
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "deletes exchange rate",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer ADMIN_TOKEN",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "USD",
+                        "description": "ISO 4217 code",
+                        "name": "currency",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "01-07-2025",
+                        "description": "valid from",
+                        "name": "date",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
             }
         },
-        "/api/v1/subscription/period-price/{period}": {
-            "get": {
-                "description": "requests period of time in path, format \"mm-yyyy:{mm-yyyy}\", where right side might be ommited and autoreplaced with time.Now()\nquery params 'user_id' and 'service_name' used as filter for request",
+        "/api/v1/admin/plans/{id}": {
+            "put": {
+                "description": "when price is changed, subscriptions on plan which have its previous price get new one according to 'price_policy':\n'keep' leaves their price, 'immediate' changes it from today and 'next_cycle' schedules it from next billing date of every subscription.\nPLAN_PRICE_POLICY is used by default. Subscriptions with custom price and changed billing period of plan are not affected",
                 "consumes": [
                     "application/json"
                 ],
@@ -91,41 +156,93 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Subscription"
+                    "Admin"
                 ],
-                "summary": "returns price of choosen subscription for period",
+                "summary": "updates plan",
                 "parameters": [
                     {
                         "type": "string",
-                        "example": "07-2025:08-2025",
-                        "description": "period",
-                        "name": "period",
-                        "in": "path",
+                        "description": "Bearer ADMIN_TOKEN",
+                        "name": "Authorization",
+                        "in": "header",
                         "required": true
                     },
                     {
                         "type": "integer",
-                        "description": "filter for concrete user",
-                        "name": "user_id",
-                        "in": "query"
+                        "description": "Plan id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     },
                     {
                         "type": "string",
-                        "description": "filter for concrete service",
-                        "name": "service_name",
+                        "description": "keep, immediate or next_cycle",
+                        "name": "price_policy",
                         "in": "query"
+                    },
+                    {
+                        "description": "Plan",
+                        "name": "plan",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/database.Plan"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK"
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.planUpdateResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "409": {
+                        "description": "Conflict"
+                    }
+                }
+            },
+            "delete": {
+                "description": "plan which has subscriptions, including deleted ones, can't be deleted",
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "deletes plan",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer ADMIN_TOKEN",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Plan id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "409": {
+                        "description": "Conflict"
                     }
                 }
             }
         },
-        "/api/v1/subscription/{id}": {
-            "get": {
-                "description": "returns single subscription",
+        "/api/v1/admin/services": {
+            "post": {
+                "description": "name and aliases are matched ignoring case and repeated spaces and must not be used by other service",
                 "consumes": [
                     "application/json"
                 ],
@@ -133,29 +250,131 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Subscription"
+                    "Admin"
                 ],
-                "summary": "returns single subscription",
+                "summary": "creates service",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer ADMIN_TOKEN",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Service",
+                        "name": "service",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/database.Service"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/database.Service"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict"
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/services/{id}": {
+            "put": {
+                "description": "subscriptions of renamed service get its new name, old name should be kept as alias to match it",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "updates service",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer ADMIN_TOKEN",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
                     {
                         "type": "integer",
-                        "description": "Subscription id",
+                        "description": "Service id",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Service",
+                        "name": "service",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/database.Service"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/database.Subscription"
+                            "$ref": "#/definitions/database.Service"
                         }
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "409": {
+                        "description": "Conflict"
                     }
                 }
             },
-            "put": {
-                "description": "updates existing subscription",
+            "delete": {
+                "description": "service which has subscriptions, including deleted ones, can't be deleted, merge it into other service instead",
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "deletes service",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer ADMIN_TOKEN",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Service id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "409": {
+                        "description": "Conflict"
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/services/{id}/merge": {
+            "post": {
+                "description": "subscriptions and plans of services from 'service_ids' are moved to service with id, names and aliases of merged services\nbecome its aliases and merged services are deleted, 409 is returned when plans of merged services have the same name",
                 "consumes": [
                     "application/json"
                 ],
@@ -163,24 +382,31 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Subscription"
+                    "Admin"
                 ],
-                "summary": "updates existing subscription",
+                "summary": "merges services",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer ADMIN_TOKEN",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
                     {
                         "type": "integer",
-                        "description": "Subscription id",
+                        "description": "Service id",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Subscription",
-                        "name": "subscription",
+                        "description": "Merged services",
+                        "name": "merge",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/database.Subscription"
+                            "$ref": "#/definitions/main.mergeServicesInput"
                         }
                     }
                 ],
@@ -188,13 +414,21 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/database.Subscription"
+                            "$ref": "#/definitions/database.Service"
                         }
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "409": {
+                        "description": "Conflict"
                     }
                 }
-            },
-            "delete": {
-                "description": "deletes existing subscription",
+            }
+        },
+        "/api/v1/admin/services/{id}/plans": {
+            "post": {
+                "description": "plan name is unique within service ignoring case, billing period is monthly by default",
                 "consumes": [
                     "application/json"
                 ],
@@ -202,55 +436,2153 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Subscription"
+                    "Admin"
                 ],
-                "summary": "deletes existing subscription",
+                "summary": "creates plan",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer ADMIN_TOKEN",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
                     {
                         "type": "integer",
-                        "description": "Subscription id",
+                        "description": "Service id",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Plan",
+                        "name": "plan",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/database.Plan"
+                        }
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/database.Plan"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "409": {
+                        "description": "Conflict"
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "database.Subscription": {
-            "type": "object",
-            "required": [
-                "price",
-                "service_name",
-                "start_date",
-                "user_id"
-            ],
-            "properties": {
-                "end_date": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "integer"
-                },
-                "price": {
-                    "type": "integer"
+        },
+        "/api/v1/admin/subscription": {
+            "delete": {
+                "description": "subscriptions deleted before 'deleted_before' day in \"dd-mm-yyyy\" or \"mm-yyyy\" format are deleted permanently, response has number of purged subscriptions",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "purges deleted subscriptions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer ADMIN_TOKEN",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "01-01-2026",
+                        "description": "deleted before day",
+                        "name": "deleted_before",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/subscription/{id}": {
+            "delete": {
+                "description": "subscription with its price history, pauses and cancellation is deleted permanently and disappears from reports, only deleted subscription may be purged",
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "purges deleted subscription",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer ADMIN_TOKEN",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Subscription id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "409": {
+                        "description": "Conflict"
+                    }
+                }
+            }
+        },
+        "/api/v1/plans/{id}": {
+            "get": {
+                "description": "returns single plan of service",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Service"
+                ],
+                "summary": "returns single plan",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Plan id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/database.Plan"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    }
+                }
+            }
+        },
+        "/api/v1/reports/churn": {
+            "get": {
+                "description": "counts cancellations whose effective date is within period given with 'from' and optional 'to' (current date by default) in \"mm-yyyy\" or \"dd-mm-yyyy\" format\n'group_by' is comma separated list of reason, service_name and month, 'reason' by default, groups are sorted by number of cancellations descending\nfilters of subscription list are supported as well",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Reports"
+                ],
+                "summary": "returns cancellations grouped by reason, service or month",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "01-2025",
+                        "description": "period start",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "12-2025",
+                        "description": "period end",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "reason,month",
+                        "description": "comma separated groups",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "filter for concrete users, comma separated",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "filter for concrete service",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "filter for service from catalog",
+                        "name": "service_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/database.ChurnReport"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/reports/spend": {
+            "get": {
+                "description": "period is given with 'from' and optional 'to' (current date by default) in \"mm-yyyy\" or \"dd-mm-yyyy\" format, 'to' month is included till its last day\n'group_by' is comma separated list of user_id, service_name and month, without it single total is returned\n'sort' may use total, subscriptions and grouped fields, '-' prefix for descending order, '-total' by default, 'limit' returns top N groups\nfilters of subscription list are supported as well",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Reports"
+                ],
+                "summary": "returns spend grouped by user, service or month",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "01-2025",
+                        "description": "period start",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "12-2025",
+                        "description": "period end",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "service_name,month",
+                        "description": "comma separated groups",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "-total",
+                        "description": "comma separated fields",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "number of top groups",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "monthly, daily or 30/360",
+                        "name": "proration",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "half_up (default), half_even, down or up",
+                        "name": "rounding",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "filter for concrete users, comma separated",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "filter for concrete service",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "filter for service from catalog",
+                        "name": "service_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/database.SpendReport"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/services": {
+            "get": {
+                "description": "services sorted by name, subscriptions reference service by 'service_id' or by its name or alias",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Service"
+                ],
+                "summary": "returns service catalog",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "video",
+                        "description": "filter for category",
+                        "name": "category",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/database.Service"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/services/{id}": {
+            "get": {
+                "description": "returns single service of catalog",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Service"
+                ],
+                "summary": "returns single service",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Service id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/database.Service"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    }
+                }
+            }
+        },
+        "/api/v1/services/{id}/plans": {
+            "get": {
+                "description": "plans sorted by price, subscription referencing plan by 'plan_id' gets its price and billing period",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Service"
+                ],
+                "summary": "returns plans of service",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Service id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/database.Plan"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    }
+                }
+            }
+        },
+        "/api/v1/subscription": {
+            "get": {
+                "description": "returns list of all subscriptions\nwith Accept text/csv, application/x-ndjson or xlsx content type (or 'format' query param) all filtered subscriptions are streamed as file, pagination params are ignored",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "text/csv",
+                    "application/x-ndjson",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "returns list of all subscriptions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "1,2,3",
+                        "description": "filter for concrete users, comma separated",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "filter for concrete service",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "filter for service from catalog",
+                        "name": "service_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "case-insensitive search by part of service name",
+                        "name": "search",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "99.90",
+                        "description": "minimal price, decimal",
+                        "name": "price_min",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "maximal price, decimal",
+                        "name": "price_max",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "start date from, mm-yyyy",
+                        "name": "start_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "start date to, mm-yyyy",
+                        "name": "start_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "end date from, mm-yyyy",
+                        "name": "end_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "end date to, mm-yyyy",
+                        "name": "end_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "subscriptions active in month, mm-yyyy",
+                        "name": "active_at",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "filter subscriptions with or without end date",
+                        "name": "has_end_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "price,-start_date",
+                        "description": "comma separated fields, '-' prefix for descending order",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "page size, 50 by default, 1000 max",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor from previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "number of subscriptions to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "include total count of filtered subscriptions",
+                        "name": "with_total",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "list deleted subscriptions as well",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "list subscriptions as they were at RFC 3339 timestamp or at end of dd-mm-yyyy day, without filters every subscription is reconstructed, so filter large lists",
+                        "name": "as_of",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "json (default), csv, ndjson or xlsx, overrides Accept header",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "add price converted to currency with rate of current month",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/database.SubscriptionPage"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "creates new subscription",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "creates new subscription",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "unique key to safely retry request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Name of subscription provider",
+                        "name": "subscription",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/database.Subscription"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/database.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "subscription version and its status"
+                            },
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of created subscription"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict"
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity"
+                    }
+                }
+            }
+        },
+        "/api/v1/subscription/batch": {
+            "post": {
+                "description": "operations are applied in single transaction, in 'atomic' mode (default) any failed operation rolls back whole batch\nin 'partial' mode failed operations are skipped and others are applied\nevery operation is {\"op\": \"create|update|delete\", \"id\": 1, \"version\": 1, \"subscription\": {...}}, version is optional\nresponds 200 when all operations succeeded, 207 when some operations failed in partial mode and 422 when atomic batch was rolled back\n503 is returned when batch isn't applied within 30 seconds, then none of operations is applied",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "applies batch of subscription changes",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "unique key to safely retry request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Batch of operations",
+                        "name": "batch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.batchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.batchResponse"
+                        }
+                    },
+                    "207": {
+                        "description": "Multi-Status",
+                        "schema": {
+                            "$ref": "#/definitions/main.batchResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/main.batchResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable"
+                    }
+                }
+            }
+        },
+        "/api/v1/subscription/import": {
+            "post": {
+                "description": "request body is streamed file, format is taken from 'format' query param or Content-Type (text/csv, application/x-ndjson)\nCSV must have header with columns user_id, start_date and service_name, service_id or plan_id, other columns are optional\ncolumns are the same as in CSV export, so exported file can be imported back\nevery line is validated with the same rules as on create, report lists rejected lines with reasons\nbatch which fails to insert is retried line by line, so every rejected line has its own reason\nwhen body can't be read further, e.g. NDJSON line is longer than 1 MB, 400 is returned with report of lines\nimported before 'aborted_at' line",
+                "consumes": [
+                    "text/plain"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "imports subscriptions from CSV or NDJSON",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "csv or ndjson",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "number of rows inserted at once, 1000 by default",
+                        "name": "batch_size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/importer.Report"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/importer.Report"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscription/period-price/{period}": {
+            "get": {
+                "description": "requests period of time in path, format \"mm-yyyy:{mm-yyyy}\", where right side might be ommited and autoreplaced with current date\nboth sides may be given with day as \"dd-mm-yyyy\", period end month is included till its last day\nquery params 'user_id' and 'service_name' used as filter for request, other filters of subscription list are supported as well\n'proration' chooses how partial months are billed: 'monthly' (default) bills every touched month in full, 'daily' bills share of days in month, '30/360' counts every month as 30 days\nresponse has price of every subscription and month-by-month timeline of totals, 'version=1' returns old format with preformatted strings\nprices in other currencies are converted to 'currency' with exchange rate valid at the first day of every billing month, 422 is returned when rate is missing",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "returns price of choosen subscription for period",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "07-2025:08-2025",
+                        "description": "period",
+                        "name": "period",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "filter for concrete users, comma separated",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "filter for concrete service",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "filter for service from catalog",
+                        "name": "service_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "monthly, daily or 30/360",
+                        "name": "proration",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "half_up (default), half_even, down or up",
+                        "name": "rounding",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "currency of totals, RUB by default",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "response format version, 2 by default",
+                        "name": "version",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/database.PriceReport"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscription/{id}": {
+            "get": {
+                "description": "returns single subscription, with 'as_of' subscription is reconstructed from audit log as it was at that time",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "returns single subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "2026-03-01T00:00:00Z",
+                        "description": "RFC 3339 timestamp or dd-mm-yyyy for end of day",
+                        "name": "as_of",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of cached subscription",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/database.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "subscription version and its status"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    }
+                }
+            },
+            "put": {
+                "description": "updates existing subscription, end date of cancelled subscription can't be changed until it is reactivated",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "updates existing subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of subscription",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Subscription",
+                        "name": "subscription",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/database.Subscription"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/database.Subscription"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict"
+                    },
+                    "412": {
+                        "description": "Precondition Failed"
+                    }
+                }
+            },
+            "delete": {
+                "description": "subscription is marked as deleted, it isn't returned anymore but stays in reports for the time it was active until it is purged",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "deletes existing subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of subscription",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "412": {
+                        "description": "Precondition Failed"
+                    }
+                }
+            },
+            "patch": {
+                "description": "accepts JSON Merge Patch (RFC 7396) with Content-Type application/merge-patch+json or application/json\nand JSON Patch (RFC 6902) with Content-Type application/json-patch+json\npatched subscription is validated with the same rules as on create\nend date of cancelled subscription can't be changed until it is reactivated",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "partially updates existing subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of subscription",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Merge patch or JSON patch",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/database.Subscription"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict"
+                    },
+                    "412": {
+                        "description": "Precondition Failed"
+                    }
+                }
+            }
+        },
+        "/api/v1/subscription/{id}/cancel": {
+            "post": {
+                "description": "'immediate' mode ends subscription today, 'end_of_cycle' (default) on the last day of current billing period, end date of subscription is set accordingly\nreason is one of too_expensive, not_using, switched_service, missing_features, technical_issues, customer_service and other, comment is free text\n409 is returned when subscription is already cancelled, has ended or is cancelled immediately before its start",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "cancels subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Cancellation",
+                        "name": "cancel",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.cancelInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/database.Subscription"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict"
+                    }
+                }
+            }
+        },
+        "/api/v1/subscription/{id}/history": {
+            "get": {
+                "description": "changes of subscription with actor and request id, newest first. Changes contain fields before and after change, log of deleted and purged subscription is kept",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "get audit log of subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "page size, 50 by default, 1000 max",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor from previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/database.AuditPage"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    }
+                }
+            }
+        },
+        "/api/v1/subscription/{id}/pause": {
+            "post": {
+                "description": "subscription isn't billed from 'from' (today by default) till 'until' inclusive or until it is resumed, both in \"dd-mm-yyyy\" or \"mm-yyyy\" format\n409 is returned when subscription is already paused in this period",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "pauses subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Pause period",
+                        "name": "pause",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/main.pauseInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/database.Subscription"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict"
+                    }
+                }
+            }
+        },
+        "/api/v1/subscription/{id}/prices": {
+            "get": {
+                "description": "every price is effective from given day until next change, changes in the future are scheduled ones",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "returns price history of subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/database.PriceChange"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "price in subscription currency becomes effective from day in \"dd-mm-yyyy\" or \"mm-yyyy\" format, which must be in the future\nchange scheduled for the same day is replaced, use PUT or PATCH to change price from today",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "schedules price change",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New price",
+                        "name": "change",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.priceChangeInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/database.PriceChange"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscription/{id}/prices/{date}": {
+            "delete": {
+                "description": "only changes which are not effective yet may be cancelled",
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "cancels scheduled price change",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "01-01-2026",
+                        "description": "effective from",
+                        "name": "date",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/api/v1/subscription/{id}/reactivate": {
+            "post": {
+                "description": "cancellation may be withdrawn until its effective date inclusive, end date of subscription before cancellation is restored\n409 is returned when subscription isn't cancelled or cancellation is already effective",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "reactivates cancelled subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/database.Subscription"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict"
+                    }
+                }
+            }
+        },
+        "/api/v1/subscription/{id}/restore": {
+            "post": {
+                "description": "409 is returned when subscription isn't deleted",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "restores deleted subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/database.Subscription"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict"
+                    }
+                }
+            }
+        },
+        "/api/v1/subscription/{id}/resume": {
+            "post": {
+                "description": "subscription is billed again from 'date' (today by default), pause which hasn't started yet is cancelled\n409 is returned when subscription is not paused",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "resumes subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Resume day",
+                        "name": "resume",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/main.resumeInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/database.Subscription"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict"
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "database.AuditAction": {
+            "type": "string",
+            "enum": [
+                "create",
+                "update",
+                "delete",
+                "restore",
+                "purge",
+                "pause",
+                "resume",
+                "cancel",
+                "reactivate",
+                "schedule_price",
+                "cancel_price",
+                "apply_price"
+            ],
+            "x-enum-varnames": [
+                "AuditCreate",
+                "AuditUpdate",
+                "AuditDelete",
+                "AuditRestore",
+                "AuditPurge",
+                "AuditPause",
+                "AuditResume",
+                "AuditCancel",
+                "AuditReactivate",
+                "AuditSchedulePrice",
+                "AuditCancelPrice",
+                "AuditApplyPrice"
+            ]
+        },
+        "database.AuditEntry": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "$ref": "#/definitions/database.AuditAction"
+                },
+                "actor": {
+                    "type": "string"
+                },
+                "changes": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/database.FieldChange"
+                    }
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "subscription_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "database.AuditPage": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/database.AuditEntry"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string"
+                }
+            }
+        },
+        "database.BillingPeriod": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "maximum": 1000,
+                    "minimum": 1
+                },
+                "unit": {
+                    "enum": [
+                        "day",
+                        "week",
+                        "month",
+                        "year"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/database.BillingUnit"
+                        }
+                    ]
+                }
+            }
+        },
+        "database.BillingUnit": {
+            "type": "string",
+            "enum": [
+                "day",
+                "week",
+                "month",
+                "year"
+            ],
+            "x-enum-varnames": [
+                "BillingDay",
+                "BillingWeek",
+                "BillingMonth",
+                "BillingYear"
+            ]
+        },
+        "database.CancelMode": {
+            "type": "string",
+            "enum": [
+                "immediate",
+                "end_of_cycle"
+            ],
+            "x-enum-varnames": [
+                "CancelImmediate",
+                "CancelEndOfCycle"
+            ]
+        },
+        "database.CancelReason": {
+            "type": "string",
+            "enum": [
+                "too_expensive",
+                "not_using",
+                "switched_service",
+                "missing_features",
+                "technical_issues",
+                "customer_service",
+                "other"
+            ],
+            "x-enum-varnames": [
+                "ReasonTooExpensive",
+                "ReasonNotUsing",
+                "ReasonSwitchedService",
+                "ReasonMissingFeatures",
+                "ReasonTechnicalIssues",
+                "ReasonCustomerService",
+                "ReasonOther"
+            ]
+        },
+        "database.Cancellation": {
+            "type": "object",
+            "properties": {
+                "cancelled_at": {
+                    "type": "string"
+                },
+                "comment": {
+                    "type": "string"
+                },
+                "effective_date": {
+                    "type": "string"
+                },
+                "mode": {
+                    "$ref": "#/definitions/database.CancelMode"
+                },
+                "reason": {
+                    "$ref": "#/definitions/database.CancelReason"
+                }
+            }
+        },
+        "database.ChurnReport": {
+            "type": "object",
+            "properties": {
+                "cancellations": {
+                    "type": "integer"
+                },
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/database.ChurnRow"
+                    }
+                },
+                "group_by": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "period_end": {
+                    "type": "string"
+                },
+                "period_start": {
+                    "type": "string"
+                }
+            }
+        },
+        "database.ChurnRow": {
+            "type": "object",
+            "properties": {
+                "cancellations": {
+                    "type": "integer"
+                },
+                "month": {
+                    "type": "string"
+                },
+                "reason": {
+                    "$ref": "#/definitions/database.CancelReason"
+                },
+                "service_name": {
+                    "type": "string"
+                }
+            }
+        },
+        "database.ExchangeRate": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "rate": {
+                    "type": "number"
+                },
+                "valid_from": {
+                    "type": "string"
+                }
+            }
+        },
+        "database.FieldChange": {
+            "type": "object",
+            "properties": {
+                "after": {
+                    "type": "object"
+                },
+                "before": {
+                    "type": "object"
+                }
+            }
+        },
+        "database.Money": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "string",
+                    "example": "399.99"
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "RUB"
+                }
+            }
+        },
+        "database.MonthTotal": {
+            "type": "object",
+            "properties": {
+                "month": {
+                    "type": "string"
+                },
+                "total": {
+                    "$ref": "#/definitions/database.Money"
+                }
+            }
+        },
+        "database.Pause": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "string"
+                },
+                "until": {
+                    "type": "string"
+                }
+            }
+        },
+        "database.Plan": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "billing_period": {
+                    "$ref": "#/definitions/database.BillingPeriod"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 100
+                },
+                "price": {
+                    "$ref": "#/definitions/database.Money"
+                },
+                "service_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "database.PriceChange": {
+            "type": "object",
+            "properties": {
+                "effective_from": {
+                    "type": "string"
+                },
+                "price": {
+                    "$ref": "#/definitions/database.Money"
+                }
+            }
+        },
+        "database.PriceReport": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "period_end": {
+                    "type": "string"
+                },
+                "period_start": {
+                    "type": "string"
+                },
+                "proration": {
+                    "$ref": "#/definitions/database.Proration"
+                },
+                "rounding": {
+                    "$ref": "#/definitions/database.Rounding"
+                },
+                "subscriptions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/database.SubscriptionPrice"
+                    }
+                },
+                "timeline": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/database.MonthTotal"
+                    }
+                },
+                "total": {
+                    "$ref": "#/definitions/database.Money"
+                }
+            }
+        },
+        "database.Proration": {
+            "type": "string",
+            "enum": [
+                "monthly",
+                "daily",
+                "30/360"
+            ],
+            "x-enum-varnames": [
+                "ProrationMonthly",
+                "ProrationDaily",
+                "Proration30360"
+            ]
+        },
+        "database.Rounding": {
+            "type": "string",
+            "enum": [
+                "half_up",
+                "half_even",
+                "down",
+                "up"
+            ],
+            "x-enum-varnames": [
+                "RoundHalfUp",
+                "RoundHalfEven",
+                "RoundDown",
+                "RoundUp"
+            ]
+        },
+        "database.Service": {
+            "type": "object",
+            "required": [
+                "aliases",
+                "name"
+            ],
+            "properties": {
+                "aliases": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "category": {
+                    "description": "Category groups services, e.g. \"video\" or \"music\"",
+                    "type": "string",
+                    "maxLength": 100
+                },
+                "default_price": {
+                    "description": "DefaultPrice is used when subscription referencing service by id has no price",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/database.Money"
+                        }
+                    ]
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "logo_url": {
+                    "type": "string",
+                    "maxLength": 2048
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 255
+                }
+            }
+        },
+        "database.SpendReport": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/database.SpendRow"
+                    }
+                },
+                "group_by": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "period_end": {
+                    "type": "string"
+                },
+                "period_start": {
+                    "type": "string"
+                },
+                "proration": {
+                    "$ref": "#/definitions/database.Proration"
+                },
+                "rounding": {
+                    "$ref": "#/definitions/database.Rounding"
+                }
+            }
+        },
+        "database.SpendRow": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "month": {
+                    "type": "string"
                 },
                 "service_name": {
                     "type": "string"
                 },
+                "subscriptions": {
+                    "type": "integer"
+                },
+                "total": {
+                    "$ref": "#/definitions/database.Money"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "database.Subscription": {
+            "type": "object",
+            "required": [
+                "start_date",
+                "user_id"
+            ],
+            "properties": {
+                "billing_period": {
+                    "description": "Price is charged once per BillingPeriod",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/database.BillingPeriod"
+                        }
+                    ]
+                },
+                "cancellation": {
+                    "$ref": "#/definitions/database.Cancellation"
+                },
+                "converted_price": {
+                    "description": "ConvertedPrice is filled only in list responses, when other currency is requested, and is never stored",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/database.Money"
+                        }
+                    ]
+                },
+                "currency": {
+                    "description": "Currency is ISO 4217 code of price, BaseCurrency by default",
+                    "type": "string"
+                },
+                "deleted_at": {
+                    "description": "DeletedAt is set when subscription is deleted, deleted subscriptions are listed only on request",
+                    "type": "string"
+                },
+                "end_date": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "pauses": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/database.Pause"
+                    }
+                },
+                "plan_id": {
+                    "description": "PlanId references plan of the service, subscription on plan gets its service and billing period\nand its price when price is omitted",
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "price": {
+                    "description": "Price is in minor units of Currency, in JSON it is decimal string like \"399.99\", number is accepted as well.\nIt may be omitted when service given by id has default price or plan is given.",
+                    "type": "string",
+                    "example": "399.99"
+                },
+                "service_id": {
+                    "description": "ServiceId references service catalog, service may be given by id or by name or alias instead.\nServiceName is always replaced with name of the service, service with unknown name is created.",
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "service_name": {
+                    "type": "string",
+                    "maxLength": 255
+                },
                 "start_date": {
                     "type": "string"
                 },
+                "status": {
+                    "description": "Status, Pauses and Cancellation are filled only when single subscription is read",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/database.SubscriptionStatus"
+                        }
+                    ]
+                },
+                "user_id": {
+                    "type": "integer"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "database.SubscriptionPage": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/database.Subscription"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "database.SubscriptionPrice": {
+            "type": "object",
+            "properties": {
+                "billing_period": {
+                    "$ref": "#/definitions/database.BillingPeriod"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "periods": {
+                    "description": "Periods is number of billed periods, months for monthly billing, rounded to 4 decimal places for display only",
+                    "type": "number"
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "subtotal": {
+                    "$ref": "#/definitions/database.Money"
+                },
+                "unit_price": {
+                    "description": "UnitPrice is current price in subscription currency, months are billed with price valid at their first billed day.\nSubtotal is in report currency.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/database.Money"
+                        }
+                    ]
+                },
                 "user_id": {
                     "type": "integer"
                 }
             }
+        },
+        "database.SubscriptionStatus": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "active",
+                "paused",
+                "ended",
+                "cancelled"
+            ],
+            "x-enum-varnames": [
+                "StatusPending",
+                "StatusActive",
+                "StatusPaused",
+                "StatusEnded",
+                "StatusCancelled"
+            ]
+        },
+        "importer.RejectedLine": {
+            "type": "object",
+            "properties": {
+                "line": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
+        "importer.Report": {
+            "type": "object",
+            "properties": {
+                "aborted_at": {
+                    "description": "AbortedAt is line import stopped at because input couldn't be read, lines before it are imported.\nError is the reason, both are empty when whole input is read.",
+                    "type": "integer"
+                },
+                "accepted": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/importer.RejectedLine"
+                    }
+                },
+                "rejected": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.batchItemResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "index": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "subscription": {
+                    "$ref": "#/definitions/database.Subscription"
+                }
+            }
+        },
+        "main.batchOperation": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "op": {
+                    "type": "string"
+                },
+                "subscription": {
+                    "$ref": "#/definitions/database.Subscription"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.batchRequest": {
+            "type": "object",
+            "required": [
+                "operations"
+            ],
+            "properties": {
+                "mode": {
+                    "type": "string",
+                    "enum": [
+                        "atomic",
+                        "partial"
+                    ]
+                },
+                "operations": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/main.batchOperation"
+                    }
+                }
+            }
+        },
+        "main.batchResponse": {
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "type": "integer"
+                },
+                "mode": {
+                    "type": "string"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.batchItemResult"
+                    }
+                },
+                "succeeded": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.cancelInput": {
+            "type": "object",
+            "required": [
+                "reason"
+            ],
+            "properties": {
+                "comment": {
+                    "type": "string",
+                    "maxLength": 1000,
+                    "example": "Found cheaper plan"
+                },
+                "mode": {
+                    "enum": [
+                        "immediate",
+                        "end_of_cycle"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/database.CancelMode"
+                        }
+                    ],
+                    "example": "end_of_cycle"
+                },
+                "reason": {
+                    "enum": [
+                        "too_expensive",
+                        "not_using",
+                        "switched_service",
+                        "missing_features",
+                        "technical_issues",
+                        "customer_service",
+                        "other"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/database.CancelReason"
+                        }
+                    ],
+                    "example": "too_expensive"
+                }
+            }
+        },
+        "main.exchangeRateInput": {
+            "type": "object",
+            "required": [
+                "rate"
+            ],
+            "properties": {
+                "rate": {
+                    "type": "number"
+                }
+            }
+        },
+        "main.mergeServicesInput": {
+            "type": "object",
+            "required": [
+                "service_ids"
+            ],
+            "properties": {
+                "service_ids": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "main.pauseInput": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "string",
+                    "example": "01-06-2025"
+                },
+                "until": {
+                    "type": "string",
+                    "example": "08-2025"
+                }
+            }
+        },
+        "main.planUpdateResponse": {
+            "type": "object",
+            "properties": {
+                "plan": {
+                    "$ref": "#/definitions/database.Plan"
+                },
+                "subscriptions": {
+                    "description": "Subscriptions is number of subscriptions which got new price of plan or have it scheduled",
+                    "type": "integer"
+                }
+            }
+        },
+        "main.priceChangeInput": {
+            "type": "object",
+            "required": [
+                "effective_from",
+                "price"
+            ],
+            "properties": {
+                "effective_from": {
+                    "type": "string",
+                    "example": "01-2026"
+                },
+                "price": {
+                    "type": "string",
+                    "example": "499.00"
+                }
+            }
+        },
+        "main.resumeInput": {
+            "type": "object",
+            "properties": {
+                "date": {
+                    "type": "string",
+                    "example": "01-09-2025"
+                }
+            }
         }
     }
 }`
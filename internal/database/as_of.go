@@ -0,0 +1,331 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// auditBumpsVersion reports whether action increments version of subscription
+func auditBumpsVersion(action AuditAction) bool {
+	return action != AuditSchedulePrice && action != AuditCancelPrice && action != AuditPurge
+}
+
+// subscriptionAt reconstructs subscription as it was at given time by undoing changes made later.
+// Current is stored subscription with its state, nil when it was purged. Later holds audit entries of subscription
+// made after that time, newest first. Nil is returned when subscription didn't exist at that time.
+// Version of purged subscription is unknown and left zero.
+func subscriptionAt(id int, current *Subscription, later []AuditEntry, at time.Time) (*Subscription, error) {
+	fields := auditFields(current)
+	version := 0
+	if current != nil {
+		version = current.Version
+	}
+
+	for _, e := range later {
+		// scheduled price changes are not fields of subscription
+		if e.Action == AuditSchedulePrice || e.Action == AuditCancelPrice {
+			continue
+		}
+		if auditBumpsVersion(e.Action) {
+			version--
+		}
+
+		for f, change := range e.Changes {
+			if string(change.Before) == "null" {
+				delete(fields, f)
+			} else {
+				fields[f] = change.Before
+			}
+		}
+	}
+
+	if _, ok := fields["service_name"]; !ok {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	var sub Subscription
+	if err := json.Unmarshal(data, &sub); err != nil {
+		return nil, err
+	}
+	sub.Id = id
+	sub.Version = max(version, 0)
+
+	if err := sub.parseState(); err != nil {
+		return nil, err
+	}
+	sub.setStatus(truncateDay(at.UTC()))
+
+	return &sub, nil
+}
+
+// parseState fills parsed dates of pauses and cancellation read from JSON
+func (sub *Subscription) parseState() error {
+	for i, p := range sub.Pauses {
+		from, err := time.Parse(dayLayout, p.From)
+		if err != nil {
+			return err
+		}
+
+		var until *time.Time
+		if p.Until != "" {
+			day, err := time.Parse(dayLayout, p.Until)
+			if err != nil {
+				return err
+			}
+			until = &day
+		}
+
+		sub.Pauses[i] = newPause(from, until)
+	}
+
+	if sub.Cancellation != nil {
+		effective, err := time.Parse(dayLayout, sub.Cancellation.EffectiveDate)
+		if err != nil {
+			return err
+		}
+		sub.Cancellation.setEffectiveDate(effective)
+	}
+
+	return nil
+}
+
+// subscriptionsAt reconstructs all subscriptions which existed at given time, current holds stored subscriptions
+// with their state and later holds audit entries made after that time, newest first
+func subscriptionsAt(current map[int]*Subscription, later map[int][]AuditEntry, at time.Time) ([]Subscription, error) {
+	ids := make(map[int]bool, len(current)+len(later))
+	for id := range current {
+		ids[id] = true
+	}
+	// purged subscriptions are known only from audit log
+	for id := range later {
+		ids[id] = true
+	}
+
+	subs := make([]Subscription, 0, len(ids))
+	for id := range ids {
+		sub, err := subscriptionAt(id, current[id], later[id], at)
+		if err != nil {
+			return nil, err
+		}
+		if sub == nil {
+			continue
+		}
+
+		// state of subscription is filled only when single subscription is read
+		sub.Status, sub.Pauses, sub.Cancellation = "", nil, nil
+		subs = append(subs, *sub)
+	}
+
+	return subs, nil
+}
+
+// loadAuditAfter returns audit entries made after given time grouped by subscription, newest first.
+// Nil ids loads entries of all subscriptions.
+func loadAuditAfter(ctx context.Context, q querier, at time.Time, ids []int) (map[int][]AuditEntry, error) {
+	query := `SELECT id, subscription_id, action, actor, request_id, changes, created_at FROM subscription_audit
+			WHERE created_at > $1 AND ($2::integer[] IS NULL OR subscription_id = ANY($2)) ORDER BY id DESC`
+
+	rows, err := q.Query(ctx, query, at, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	entries := make(map[int][]AuditEntry)
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.Id, &e.SubscriptionId, &e.Action, &e.Actor, &e.RequestId, &e.Changes, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries[e.SubscriptionId] = append(entries[e.SubscriptionId], e)
+	}
+
+	return entries, rows.Err()
+}
+
+// GetAt returns subscription as it was at given time, nil when it didn't exist or was deleted then.
+// Subscription changed before audit log was introduced is returned as it was at the first recorded change.
+func (m *SubscriptionModel) GetAt(id int, at time.Time) (*Subscription, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var sub *Subscription
+	err := m.readSnapshot(ctx, func(tx pgx.Tx) error {
+		current, err := scanSubscription(tx.QueryRow(ctx, "SELECT "+subscriptionSelect+" FROM subscription WHERE id = $1", id))
+		switch {
+		case err == pgx.ErrNoRows:
+			current = nil
+		case err != nil:
+			return err
+		default:
+			if err := loadState(ctx, tx, current); err != nil {
+				return err
+			}
+		}
+
+		later, err := loadAuditAfter(ctx, tx, at, []int{id})
+		if err != nil {
+			return err
+		}
+
+		sub, err = subscriptionAt(id, current, later[id], at)
+		return err
+	})
+	if err != nil {
+		slog.Error("ERROR in Subscription GetAt", "error", err)
+		return nil, err
+	}
+	if sub == nil || sub.DeletedAt != nil {
+		return nil, nil
+	}
+
+	return sub, nil
+}
+
+// getListAt lists subscriptions as they were at given time. Subscriptions are reconstructed and filtered in application,
+// so it is slower than listing current ones. Filter is pushed down to database as well: only subscriptions which match it now
+// or had filtered fields changed, or were purged, after that time are read, without filter all subscriptions are read.
+func (m *SubscriptionModel) getListAt(at time.Time, filter *Filter, opts ListOptions) (*SubscriptionPage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var subs []Subscription
+	err := m.readSnapshot(ctx, func(tx pgx.Tx) error {
+		var qb queryBuilder
+		var changed []int
+		filtered := len(filter.Conditions()) > 0
+		if filtered {
+			// subscription which doesn't match filter now could match it only before change of filtered field
+			query := `SELECT DISTINCT subscription_id FROM subscription_audit
+					WHERE created_at > $1 AND (action = $2 OR changes ?| $3)`
+			rows, err := tx.Query(ctx, query, at, AuditPurge, filter.fields())
+			if err != nil {
+				return err
+			}
+			if changed, err = pgx.CollectRows(rows, pgx.RowTo[int]); err != nil {
+				return err
+			}
+
+			if err := qb.addFilter(filter); err != nil {
+				return err
+			}
+			qb.where = []string{fmt.Sprintf("((%s) OR id = ANY(%s))", strings.Join(qb.where, " AND "), qb.arg(changed))}
+		}
+
+		rows, err := tx.Query(ctx, "SELECT "+subscriptionSelect+" FROM subscription "+qb.whereClause(), qb.args...)
+		if err != nil {
+			return err
+		}
+		stored, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Subscription, error) { return scanSubscription(row) })
+		if err != nil {
+			return err
+		}
+
+		current := make(map[int]*Subscription, len(stored))
+		storedIds := make([]int, len(stored))
+		for i, sub := range stored {
+			current[sub.Id] = sub
+			storedIds[i] = sub.Id
+		}
+
+		pauses, err := loadPauses(ctx, tx, storedIds)
+		if err != nil {
+			return err
+		}
+		cancellations, err := loadCancellations(ctx, tx, storedIds)
+		if err != nil {
+			return err
+		}
+		for id, sub := range current {
+			sub.Pauses, sub.Cancellation = pauses[id], cancellations[id]
+		}
+
+		// with filter only history of read and purged candidates is needed, nil ids load all of it
+		var ids []int
+		if filtered {
+			ids = append(append(make([]int, 0, len(changed)+len(storedIds)), changed...), storedIds...)
+		}
+		later, err := loadAuditAfter(ctx, tx, at, ids)
+		if err != nil {
+			return err
+		}
+
+		subs, err = subscriptionsAt(current, later, at)
+		return err
+	})
+	if err != nil {
+		if !errors.Is(err, ErrInvalidFilter) {
+			slog.Error("ERROR in Subscription GetList", "error", err)
+		}
+		return nil, err
+	}
+
+	page, err := listPage(subs, filter, opts)
+	if err != nil && !errors.Is(err, ErrInvalidCursor) {
+		slog.Error("ERROR in Subscription GetList", "error", err)
+	}
+
+	return page, err
+}
+
+func (m *MemorySubscriptionModel) GetAt(id int, at time.Time) (*Subscription, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sub, err := subscriptionAt(id, m.snapshot(id), m.auditAfter(at)[id], at)
+	if err != nil {
+		slog.Error("ERROR in MemorySubscription GetAt", "error", err)
+		return nil, err
+	}
+	if sub == nil || sub.DeletedAt != nil {
+		return nil, nil
+	}
+
+	return sub, nil
+}
+
+func (m *MemorySubscriptionModel) getListAt(at time.Time, filter *Filter, opts ListOptions) (*SubscriptionPage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	current := make(map[int]*Subscription, len(m.subs))
+	for id := range m.subs {
+		current[id] = m.snapshot(id)
+	}
+
+	subs, err := subscriptionsAt(current, m.auditAfter(at), at)
+	if err != nil {
+		slog.Error("ERROR in MemorySubscription GetList", "error", err)
+		return nil, err
+	}
+
+	page, err := listPage(subs, filter, opts)
+	if err != nil && !errors.Is(err, ErrInvalidCursor) {
+		slog.Error("ERROR in MemorySubscription GetList", "error", err)
+	}
+
+	return page, err
+}
+
+// auditAfter returns audit entries made after given time grouped by subscription, newest first. Caller must hold the lock.
+func (m *MemorySubscriptionModel) auditAfter(at time.Time) map[int][]AuditEntry {
+	entries := make(map[int][]AuditEntry)
+	for i := len(m.auditLog) - 1; i >= 0 && m.auditLog[i].CreatedAt.After(at); i-- {
+		e := m.auditLog[i]
+		entries[e.SubscriptionId] = append(entries[e.SubscriptionId], e)
+	}
+	return entries
+}
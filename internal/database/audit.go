@@ -0,0 +1,249 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// Actor is who changes subscriptions and id of request the change is made in, both are recorded in audit log.
+// Changes made without actor, e.g. by background jobs, are recorded as made by SystemActor.
+type Actor struct {
+	Name      string
+	RequestId string
+}
+
+const SystemActor = "system"
+
+func (a Actor) name() string {
+	if a.Name == "" {
+		return SystemActor
+	}
+	return a.Name
+}
+
+type AuditAction string
+
+const (
+	AuditCreate        AuditAction = "create"
+	AuditUpdate        AuditAction = "update"
+	AuditDelete        AuditAction = "delete"
+	AuditRestore       AuditAction = "restore"
+	AuditPurge         AuditAction = "purge"
+	AuditPause         AuditAction = "pause"
+	AuditResume        AuditAction = "resume"
+	AuditCancel        AuditAction = "cancel"
+	AuditReactivate    AuditAction = "reactivate"
+	AuditSchedulePrice AuditAction = "schedule_price"
+	AuditCancelPrice   AuditAction = "cancel_price"
+	AuditApplyPrice    AuditAction = "apply_price"
+)
+
+// FieldChange is value of field before and after change in the same format as in API, null means empty field
+type FieldChange struct {
+	Before json.RawMessage `json:"before" swaggertype:"object"`
+	After  json.RawMessage `json:"after" swaggertype:"object"`
+}
+
+// AuditEntry is single change of subscription, entries are never modified or deleted
+type AuditEntry struct {
+	Id             int64                  `json:"id"`
+	SubscriptionId int                    `json:"subscription_id"`
+	Action         AuditAction            `json:"action"`
+	Actor          string                 `json:"actor"`
+	RequestId      string                 `json:"request_id,omitempty"`
+	Changes        map[string]FieldChange `json:"changes"`
+	CreatedAt      time.Time              `json:"created_at"`
+}
+
+// AuditPage is page of audit log, newest entries first
+type AuditPage struct {
+	Data       []AuditEntry `json:"data"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+// auditIgnored are fields which are derived or change with every write, so they are not recorded
+var auditIgnored = []string{"id", "version", "status", "converted_price"}
+
+// auditFields returns fields of subscription as they are written to JSON, nil subscription has no fields
+func auditFields(sub *Subscription) map[string]json.RawMessage {
+	fields := map[string]json.RawMessage{}
+	if sub == nil {
+		return fields
+	}
+
+	data, err := json.Marshal(sub)
+	if err != nil {
+		return fields
+	}
+	json.Unmarshal(data, &fields)
+
+	for _, f := range auditIgnored {
+		delete(fields, f)
+	}
+	return fields
+}
+
+// auditChanges returns fields which differ between subscription snapshots, nil snapshot means subscription doesn't exist
+func auditChanges(before, after *Subscription) map[string]FieldChange {
+	old, updated := auditFields(before), auditFields(after)
+
+	changes := map[string]FieldChange{}
+	for f := range old {
+		if _, ok := updated[f]; !ok {
+			updated[f] = json.RawMessage("null")
+		}
+	}
+	for f, value := range updated {
+		prev, ok := old[f]
+		if !ok {
+			prev = json.RawMessage("null")
+		}
+		if !bytes.Equal(prev, value) {
+			changes[f] = FieldChange{Before: prev, After: value}
+		}
+	}
+
+	return changes
+}
+
+// valueChange returns change of single value, nil value is written as null
+func valueChange[T any](before, after *T) FieldChange {
+	change := FieldChange{Before: json.RawMessage("null"), After: json.RawMessage("null")}
+	if before != nil {
+		change.Before, _ = json.Marshal(before)
+	}
+	if after != nil {
+		change.After, _ = json.Marshal(after)
+	}
+	return change
+}
+
+func encodeAuditCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeAuditCursor(s string) (int64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	id, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	return id, nil
+}
+
+// newAuditPage trims entries fetched with one extra row to limit and sets next cursor when more rows exist
+func newAuditPage(entries []AuditEntry, limit int) *AuditPage {
+	page := &AuditPage{Data: entries}
+
+	if len(entries) > limit {
+		page.Data = entries[:limit]
+		page.NextCursor = encodeAuditCursor(page.Data[limit-1].Id)
+	}
+
+	return page
+}
+
+// writeAudit appends entry to audit log
+func writeAudit(ctx context.Context, q querier, actor Actor, action AuditAction, id int, changes map[string]FieldChange) error {
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	query := "INSERT INTO subscription_audit (subscription_id, action, actor, request_id, changes) VALUES ($1, $2, $3, $4, $5)"
+
+	_, err = q.Exec(ctx, query, id, action, actor.name(), actor.RequestId, data)
+
+	return err
+}
+
+// changeSubscription locks subscription, applies fn to it and records its changes in audit log in the same transaction.
+// Deleted subscription is not found unless it is restored or purged.
+func changeSubscription(ctx context.Context, q querier, actor Actor, action AuditAction, id int, fn func(sub *Subscription) error) error {
+	before, err := lockSubscription(ctx, q, id)
+	if err != nil {
+		return err
+	}
+	if before.DeletedAt != nil && action != AuditRestore && action != AuditPurge {
+		return ErrRecordNotFound
+	}
+
+	if err := fn(before); err != nil {
+		return err
+	}
+
+	// purged subscription doesn't exist anymore
+	after, err := lockSubscription(ctx, q, id)
+	if err != nil && err != ErrRecordNotFound {
+		return err
+	}
+
+	return writeAudit(ctx, q, actor, action, id, auditChanges(before, after))
+}
+
+// History returns audit log of subscription, newest entries first. Log of purged subscription is kept as well.
+func (m *SubscriptionModel) History(id int, opts ListOptions) (*AuditPage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var qb queryBuilder
+	qb.raw("subscription_id = " + qb.arg(id))
+	if opts.Cursor != "" {
+		after, err := decodeAuditCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		qb.raw("id < " + qb.arg(after))
+	}
+
+	limit := opts.limit()
+	query := "SELECT id, subscription_id, action, actor, request_id, changes, created_at FROM subscription_audit " +
+		qb.whereClause() + " ORDER BY id DESC LIMIT " + qb.arg(limit+1)
+
+	rows, err := m.DB.Query(ctx, query, qb.args...)
+	if err != nil {
+		slog.Error("ERROR in Subscription History", "error", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.Id, &e.SubscriptionId, &e.Action, &e.Actor, &e.RequestId, &e.Changes, &e.CreatedAt); err != nil {
+			slog.Error("ERROR in Subscription History", "error", err)
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		slog.Error("ERROR in Subscription History", "error", err)
+		return nil, err
+	}
+
+	// subscription created before audit log was introduced has no entries
+	if len(entries) == 0 && opts.Cursor == "" {
+		var exists bool
+		if err := m.DB.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM subscription WHERE id = $1)", id).Scan(&exists); err != nil {
+			slog.Error("ERROR in Subscription History", "error", err)
+			return nil, err
+		}
+		if !exists {
+			return nil, ErrRecordNotFound
+		}
+	}
+
+	return newAuditPage(entries, limit), nil
+}
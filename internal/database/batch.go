@@ -0,0 +1,139 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// MaxBatchSize is maximum number of operations in single batch
+const MaxBatchSize = 5000
+
+// batchTimeout limits time of single batch, batch which isn't applied in time is rolled back as a whole
+const batchTimeout = 30 * time.Second
+
+// ErrBatchRolledBack is result of operations which were rolled back or skipped because other operation of atomic batch failed
+var ErrBatchRolledBack = errors.New("batch rolled back")
+
+type BatchOp string
+
+const (
+	BatchCreate BatchOp = "create"
+	BatchUpdate BatchOp = "update"
+	BatchDelete BatchOp = "delete"
+)
+
+// BatchOperation is single change of batch, Id and Version are used by update and delete,
+// Version 0 means operation is applied regardless of stored version
+type BatchOperation struct {
+	Op           BatchOp
+	Id           int
+	Version      int
+	Subscription *Subscription
+}
+
+// BatchResult is outcome of batch operation, Subscription is nil for delete and failed operations
+type BatchResult struct {
+	Subscription *Subscription
+	Err          error
+}
+
+// rollbackResults marks every result except failed one as rolled back
+func rollbackResults(results []BatchResult, failed int) {
+	for i := range results {
+		if i != failed {
+			results[i] = BatchResult{Err: ErrBatchRolledBack}
+		}
+	}
+}
+
+func applyBatchOperation(ctx context.Context, q querier, actor Actor, op BatchOperation) BatchResult {
+	switch op.Op {
+	case BatchCreate:
+		sub := *op.Subscription
+		if err := insertSubscription(ctx, q, actor, &sub); err != nil {
+			return BatchResult{Err: err}
+		}
+		return BatchResult{Subscription: &sub}
+	case BatchUpdate:
+		sub := *op.Subscription
+		sub.Id = op.Id
+		sub.Version = op.Version
+		if err := updateSubscription(ctx, q, actor, &sub); err != nil {
+			return BatchResult{Err: err}
+		}
+		return BatchResult{Subscription: &sub}
+	case BatchDelete:
+		return BatchResult{Err: deleteSubscription(ctx, q, actor, op.Id, op.Version)}
+	}
+
+	return BatchResult{Err: fmt.Errorf("unknown batch operation %q", op.Op)}
+}
+
+// Batch applies operations in single transaction. In atomic mode first failed operation rolls back whole batch,
+// otherwise every operation runs in its own savepoint and failed operations don't affect others.
+// Returned error means batch was not applied at all, context error is returned when batch isn't applied in time
+// or request is cancelled.
+func (m *SubscriptionModel) Batch(ctx context.Context, ops []BatchOperation, atomic bool) ([]BatchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, batchTimeout)
+	defer cancel()
+
+	// operations failed because of deadline are not reported one by one, whole batch fails with context error
+	fail := func(err error) ([]BatchResult, error) {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		slog.Error("ERROR in Subscription Batch", "error", err)
+		return nil, err
+	}
+
+	tx, err := m.DB.Begin(ctx)
+	if err != nil {
+		return fail(err)
+	}
+
+	defer tx.Rollback(context.Background())
+
+	results := make([]BatchResult, len(ops))
+
+	for i, op := range ops {
+		if atomic {
+			results[i] = applyBatchOperation(ctx, tx, m.actor, op)
+			if results[i].Err != nil {
+				if ctx.Err() != nil {
+					return fail(results[i].Err)
+				}
+				rollbackResults(results, i)
+				return results, nil
+			}
+			continue
+		}
+
+		savepoint, err := tx.Begin(ctx)
+		if err != nil {
+			return fail(err)
+		}
+
+		results[i] = applyBatchOperation(ctx, savepoint, m.actor, op)
+		if results[i].Err != nil && ctx.Err() != nil {
+			return fail(results[i].Err)
+		}
+
+		if results[i].Err != nil {
+			err = savepoint.Rollback(ctx)
+		} else {
+			err = savepoint.Commit(ctx)
+		}
+		if err != nil {
+			return fail(err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fail(err)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"math/big"
+	"time"
+)
+
+type BillingUnit string
+
+const (
+	BillingDay   BillingUnit = "day"
+	BillingWeek  BillingUnit = "week"
+	BillingMonth BillingUnit = "month"
+	BillingYear  BillingUnit = "year"
+)
+
+// BillingPeriod is interval between subscription charges, price is charged once per period.
+// Empty period means monthly billing.
+type BillingPeriod struct {
+	Unit  BillingUnit `json:"unit" binding:"omitempty,oneof=day week month year"`
+	Count int         `json:"count" binding:"omitempty,min=1,max=1000"`
+}
+
+// normalize fills missing unit and count with monthly billing defaults
+func (b BillingPeriod) normalize() BillingPeriod {
+	if b.Unit == "" {
+		b.Unit = BillingMonth
+	}
+	if b.Count == 0 {
+		b.Count = 1
+	}
+	return b
+}
+
+func (b BillingPeriod) isMonthly() bool {
+	b = b.normalize()
+	return b.Unit == BillingMonth && b.Count == 1
+}
+
+// chargeDate returns n-th billing date after start, month end is kept, so 31 Jan is followed by 28 Feb and 31 Mar
+func (b BillingPeriod) chargeDate(start time.Time, n int) time.Time {
+	b = b.normalize()
+
+	switch b.Unit {
+	case BillingDay:
+		return start.AddDate(0, 0, n*b.Count)
+	case BillingWeek:
+		return start.AddDate(0, 0, 7*n*b.Count)
+	case BillingYear:
+		return addMonths(start, 12*n*b.Count)
+	}
+	return addMonths(start, n*b.Count)
+}
+
+func addMonths(t time.Time, months int) time.Time {
+	y, m, d := t.Date()
+	first := time.Date(y, m+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(d, last)-1)
+}
+
+// Breakdown splits billed part of subscription within period by calendar months.
+// Monthly billing is prorated, other periods are charged in full on every billing date with share per charge.
+func (b BillingPeriod) Breakdown(proration Proration, subStart, subEnd, periodStart, periodEnd time.Time) []MonthShare {
+	if b.isMonthly() {
+		return proration.Breakdown(subStart, subEnd, periodStart, periodEnd)
+	}
+
+	start := truncateDay(subStart)
+	end := truncateDay(subEnd)
+	if periodEnd.Before(end) {
+		end = truncateDay(periodEnd)
+	}
+
+	var shares []MonthShare
+
+	for n := 0; ; n++ {
+		date := b.chargeDate(start, n)
+		if date.After(end) {
+			break
+		}
+		if date.Before(periodStart) {
+			continue
+		}
+
+		shares = append(shares, MonthShare{Month: date.AddDate(0, 0, 1-date.Day()), Day: date, Periods: big.NewRat(1, 1)})
+	}
+
+	return shares
+}
@@ -0,0 +1,234 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrAlreadyCancelled is returned when cancelled subscription is cancelled again
+	ErrAlreadyCancelled = errors.New("subscription is already cancelled")
+	// ErrSubscriptionEnded is returned when subscription which has already ended is cancelled
+	ErrSubscriptionEnded = errors.New("subscription has already ended")
+	// ErrNotStarted is returned when subscription which hasn't started yet is cancelled immediately
+	ErrNotStarted = errors.New("subscription hasn't started yet, delete it instead")
+	// ErrNotCancelled is returned when reactivated subscription is not cancelled
+	ErrNotCancelled = errors.New("subscription is not cancelled")
+	// ErrCancellationEffective is returned when subscription is reactivated after its cancellation took effect
+	ErrCancellationEffective = errors.New("cancellation is already effective")
+	// ErrCancelledEndDate is returned when end date of cancelled subscription is edited, it is set by cancellation
+	ErrCancelledEndDate = errors.New("end date of cancelled subscription is set by its cancellation, reactivate it with POST /api/v1/subscription/{id}/reactivate first")
+)
+
+// CancelMode is when cancellation takes effect
+type CancelMode string
+
+const (
+	// CancelImmediate ends subscription today
+	CancelImmediate CancelMode = "immediate"
+	// CancelEndOfCycle ends subscription on the last day of current billing period
+	CancelEndOfCycle CancelMode = "end_of_cycle"
+)
+
+// CancelReason is reason of cancellation reported by churn report
+type CancelReason string
+
+const (
+	ReasonTooExpensive    CancelReason = "too_expensive"
+	ReasonNotUsing        CancelReason = "not_using"
+	ReasonSwitchedService CancelReason = "switched_service"
+	ReasonMissingFeatures CancelReason = "missing_features"
+	ReasonTechnicalIssues CancelReason = "technical_issues"
+	ReasonCustomerService CancelReason = "customer_service"
+	ReasonOther           CancelReason = "other"
+)
+
+// Cancellation is requested end of subscription, EffectiveDate is its last active day and new end date
+type Cancellation struct {
+	Mode          CancelMode   `json:"mode"`
+	Reason        CancelReason `json:"reason"`
+	Comment       string       `json:"comment,omitempty"`
+	CancelledAt   time.Time    `json:"cancelled_at"`
+	EffectiveDate string       `json:"effective_date"`
+
+	effectiveDate time.Time
+	// previousEnd is end date restored on reactivation
+	previousEnd *time.Time
+}
+
+func (c *Cancellation) setEffectiveDate(day time.Time) {
+	c.effectiveDate = day
+	c.EffectiveDate = day.Format(dayLayout)
+}
+
+// cycleEnd returns last day of billing period which contains day
+func (b BillingPeriod) cycleEnd(start, day time.Time) time.Time {
+	for n := 1; ; n++ {
+		if next := b.chargeDate(start, n); next.After(day) {
+			return next.AddDate(0, 0, -1)
+		}
+	}
+}
+
+// cancelDate returns last active day of subscription cancelled at day
+func cancelDate(sub *Subscription, mode CancelMode, day time.Time) (time.Time, error) {
+	if sub.Cancellation != nil {
+		return time.Time{}, ErrAlreadyCancelled
+	}
+
+	start, end, err := ParseDates(sub.StartDate, sub.EndDate)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end != nil && end.Before(day) {
+		return time.Time{}, ErrSubscriptionEnded
+	}
+
+	effective := day
+	switch {
+	case mode == CancelImmediate && start.After(day):
+		return time.Time{}, ErrNotStarted
+	case mode == CancelEndOfCycle && start.After(day):
+		effective = sub.BillingPeriod.cycleEnd(start, start)
+	case mode == CancelEndOfCycle:
+		effective = sub.BillingPeriod.cycleEnd(start, day)
+	}
+
+	if end != nil && end.Before(effective) {
+		effective = *end
+	}
+
+	return effective, nil
+}
+
+// checkReactivate reports whether cancellation of subscription may be withdrawn at day
+func checkReactivate(sub *Subscription, day time.Time) error {
+	if sub.Cancellation == nil {
+		return ErrNotCancelled
+	}
+	if sub.Cancellation.effectiveDate.Before(day) {
+		return ErrCancellationEffective
+	}
+	return nil
+}
+
+// checkEndDate rejects edit of end date of cancelled subscription, stored subscription must have its cancellation loaded
+func checkEndDate(stored, sub *Subscription) error {
+	if stored.Cancellation == nil {
+		return nil
+	}
+
+	_, storedEnd, err := ParseDates(stored.StartDate, stored.EndDate)
+	if err != nil {
+		return err
+	}
+	_, end, err := ParseDates(sub.StartDate, sub.EndDate)
+	if err != nil {
+		return err
+	}
+
+	if storedEnd == nil || end == nil || !end.Equal(*storedEnd) {
+		return ErrCancelledEndDate
+	}
+	return nil
+}
+
+// loadCancellations returns cancellations of subscriptions
+func loadCancellations(ctx context.Context, q querier, ids []int) (map[int]*Cancellation, error) {
+	query := `SELECT subscription_id, mode, reason, comment, cancelled_at, effective_date, previous_end_date
+			FROM subscription_cancellations WHERE subscription_id = ANY($1)`
+
+	rows, err := q.Query(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	cancellations := make(map[int]*Cancellation)
+	for rows.Next() {
+		var id int
+		var c Cancellation
+		var effective time.Time
+
+		if err := rows.Scan(&id, &c.Mode, &c.Reason, &c.Comment, &c.CancelledAt, &effective, &c.previousEnd); err != nil {
+			return nil, err
+		}
+		c.setEffectiveDate(effective)
+		cancellations[id] = &c
+	}
+
+	return cancellations, rows.Err()
+}
+
+// Cancel ends subscription with given mode and reason, its end date is set to effective date of cancellation
+// and version of subscription is incremented
+func (m *SubscriptionModel) Cancel(id int, cancellation Cancellation) (*Subscription, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.withTx(ctx, func(tx pgx.Tx) error {
+		return changeSubscription(ctx, tx, m.actor, AuditCancel, id, func(sub *Subscription) error {
+			effective, err := cancelDate(sub, cancellation.Mode, Today())
+			if err != nil {
+				return err
+			}
+
+			query := `INSERT INTO subscription_cancellations (subscription_id, mode, reason, comment, effective_date, previous_end_date)
+					SELECT id, $2, $3, $4, $5, end_date FROM subscription WHERE id = $1`
+			if _, err := tx.Exec(ctx, query, id, cancellation.Mode, cancellation.Reason, cancellation.Comment, effective); err != nil {
+				return err
+			}
+
+			_, err = tx.Exec(ctx, "UPDATE subscription SET end_date = $2, version = version + 1 WHERE id = $1", id, effective)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, cancelError("Cancel", err)
+	}
+
+	return m.Get(id)
+}
+
+// Reactivate withdraws cancellation which hasn't taken effect yet, end date of subscription is restored
+func (m *SubscriptionModel) Reactivate(id int) (*Subscription, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.withTx(ctx, func(tx pgx.Tx) error {
+		return changeSubscription(ctx, tx, m.actor, AuditReactivate, id, func(sub *Subscription) error {
+			if err := checkReactivate(sub, Today()); err != nil {
+				return err
+			}
+
+			if _, err := tx.Exec(ctx, "DELETE FROM subscription_cancellations WHERE subscription_id = $1", id); err != nil {
+				return err
+			}
+
+			_, err := tx.Exec(ctx, "UPDATE subscription SET end_date = $2, version = version + 1 WHERE id = $1", id, sub.Cancellation.previousEnd)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, cancelError("Reactivate", err)
+	}
+
+	return m.Get(id)
+}
+
+// cancelError logs unexpected errors of cancellation and reactivation
+func cancelError(method string, err error) error {
+	for _, expected := range []error{ErrRecordNotFound, ErrAlreadyCancelled, ErrSubscriptionEnded, ErrNotStarted, ErrNotCancelled, ErrCancellationEffective} {
+		if errors.Is(err, expected) {
+			return err
+		}
+	}
+
+	slog.Error("ERROR in Subscription "+method, "error", err)
+	return err
+}
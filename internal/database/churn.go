@@ -0,0 +1,141 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// churnGroups are fields cancellations can be grouped by
+var churnGroups = []string{"reason", "service_name", "month"}
+
+// ChurnRow is number of cancellations of one group, fields which report isn't grouped by are omitted
+type ChurnRow struct {
+	Reason        CancelReason `json:"reason,omitempty"`
+	ServiceName   string       `json:"service_name,omitempty"`
+	Month         string       `json:"month,omitempty"`
+	Cancellations int          `json:"cancellations"`
+
+	month time.Time
+}
+
+// ChurnReport counts cancellations taking effect within period, withdrawn cancellations are not counted
+type ChurnReport struct {
+	PeriodStart   string     `json:"period_start"`
+	PeriodEnd     string     `json:"period_end"`
+	GroupBy       []string   `json:"group_by"`
+	Cancellations int        `json:"cancellations"`
+	Data          []ChurnRow `json:"data"`
+}
+
+// ParseChurnGroups parses comma separated list of reason, service_name and month, report is grouped by reason by default
+func ParseChurnGroups(s string) ([]string, error) {
+	groups, err := parseGroups(s, churnGroups)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		groups = append(groups, "reason")
+	}
+	return groups, nil
+}
+
+func newChurnReport(start, end time.Time, groupBy []string) *ChurnReport {
+	return &ChurnReport{
+		PeriodStart: formatDate(start, false),
+		PeriodEnd:   formatDate(end, true),
+		GroupBy:     groupBy,
+		Data:        []ChurnRow{},
+	}
+}
+
+// add appends row to report, rows must be added in report order
+func (r *ChurnReport) add(row ChurnRow) {
+	if !row.month.IsZero() {
+		row.Month = row.month.Format(monthLayout)
+	}
+	r.Cancellations += row.Cancellations
+	r.Data = append(r.Data, row)
+}
+
+// GetChurn counts cancellations of filtered subscriptions whose effective date is within period.
+// Groups are sorted by number of cancellations descending.
+func (m *SubscriptionModel) GetChurn(startPeriod, endPeriod time.Time, filter *Filter, groupBy []string) (*ChurnReport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var qb queryBuilder
+	qb.raw(fmt.Sprintf("c.effective_date BETWEEN %s AND %s", qb.arg(startPeriod), qb.arg(endPeriod)))
+	if err := qb.addFilter(filter); err != nil {
+		slog.Error("ERROR in Subscription GetChurn", "error", err)
+		return nil, err
+	}
+
+	columns := make([]string, len(groupBy))
+	for i, g := range groupBy {
+		columns[i] = g
+		if g == "month" {
+			// date is truncated as timestamp without time zone, so month doesn't depend on session time zone
+			columns[i] = "date_trunc('month', c.effective_date::timestamp)::date AS month"
+		}
+	}
+
+	query := fmt.Sprintf(`SELECT %s, COUNT(*) AS cancellations
+		FROM subscription_cancellations AS c JOIN subscription ON subscription.id = c.subscription_id %s
+		GROUP BY %s ORDER BY cancellations DESC, %s`,
+		strings.Join(columns, ", "), qb.whereClause(), strings.Join(groupBy, ", "), strings.Join(groupBy, ", "))
+
+	rows, err := m.DB.Query(ctx, query, qb.args...)
+	if err != nil {
+		slog.Error("ERROR in Subscription GetChurn", "error", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	report := newChurnReport(startPeriod, endPeriod, groupBy)
+
+	for rows.Next() {
+		var row ChurnRow
+
+		dest := []any{}
+		for _, g := range groupBy {
+			switch g {
+			case "reason":
+				dest = append(dest, &row.Reason)
+			case "service_name":
+				dest = append(dest, &row.ServiceName)
+			case "month":
+				dest = append(dest, &row.month)
+			}
+		}
+		dest = append(dest, &row.Cancellations)
+
+		if err := rows.Scan(dest...); err != nil {
+			slog.Error("ERROR in Subscription GetChurn", "error", err)
+			return nil, err
+		}
+
+		report.add(row)
+	}
+
+	if err = rows.Err(); err != nil {
+		slog.Error("ERROR in Subscription GetChurn", "error", err)
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// compareChurnRows orders rows by cancellations descending and then by grouped fields
+func compareChurnRows(a, b *ChurnRow) int {
+	return cmp.Or(
+		cmp.Compare(b.Cancellations, a.Cancellations),
+		cmp.Compare(a.Reason, b.Reason),
+		cmp.Compare(a.ServiceName, b.ServiceName),
+		a.month.Compare(b.month),
+	)
+}
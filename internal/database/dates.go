@@ -0,0 +1,62 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	monthLayout = "01-2006"
+	dayLayout   = "02-01-2006"
+)
+
+// ParseDate parses date in "dd-mm-yyyy" or "mm-yyyy" format.
+// Month without day means its first day or, with endOfMonth, its last day.
+func ParseDate(s string, endOfMonth bool) (time.Time, error) {
+	if t, err := time.Parse(dayLayout, s); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(monthLayout, s)
+	if err != nil {
+		return t, fmt.Errorf("invalid date %q, mm-yyyy or dd-mm-yyyy expected", s)
+	}
+
+	if endOfMonth {
+		t = t.AddDate(0, 1, -1)
+	}
+
+	return t, nil
+}
+
+// ParseDates parses subscription dates, end date is optional and inclusive, so "05-2025" ends on 31 May
+func ParseDates(start, end string) (startTime time.Time, endTime *time.Time, err error) {
+	startTime, err = ParseDate(start, false)
+	if err != nil {
+		return startTime, nil, err
+	}
+
+	if end != "" {
+		t, err := ParseDate(end, true)
+		if err != nil {
+			return startTime, nil, err
+		}
+		endTime = &t
+	}
+
+	return startTime, endTime, nil
+}
+
+// formatDate returns "mm-yyyy" for whole months, that is first day for start date and last day for end date, and "dd-mm-yyyy" otherwise
+func formatDate(t time.Time, endOfMonth bool) string {
+	if (!endOfMonth && t.Day() == 1) || (endOfMonth && t.AddDate(0, 0, 1).Day() == 1) {
+		return t.Format(monthLayout)
+	}
+	return t.Format(dayLayout)
+}
+
+// truncateDay drops time of day, subscriptions are billed by whole days
+func truncateDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,204 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// BaseCurrency is currency exchange rates are given in, its rate is always 1
+const BaseCurrency = "RUB"
+
+var ErrNoExchangeRate = errors.New("no exchange rate")
+
+// ExchangeRate is price of one unit of currency in BaseCurrency, valid from given day until next rate of the currency
+type ExchangeRate struct {
+	Currency  string  `json:"currency"`
+	ValidFrom string  `json:"valid_from"`
+	Rate      float64 `json:"rate"`
+}
+
+type ExchangeRateModel struct {
+	DB *pgxpool.Pool
+}
+
+func (m *ExchangeRateModel) List(currency string) ([]ExchangeRate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := "SELECT currency, valid_from, rate::float8 FROM exchange_rates WHERE ($1 = '' OR currency = $1) ORDER BY currency, valid_from"
+
+	rows, err := m.DB.Query(ctx, query, currency)
+	if err != nil {
+		slog.Error("ERROR in ExchangeRate List", "error", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	rates := []ExchangeRate{}
+	for rows.Next() {
+		var rate ExchangeRate
+		var validFrom time.Time
+
+		if err := rows.Scan(&rate.Currency, &validFrom, &rate.Rate); err != nil {
+			slog.Error("ERROR in ExchangeRate List", "error", err)
+			return nil, err
+		}
+
+		rate.ValidFrom = validFrom.Format(dayLayout)
+		rates = append(rates, rate)
+	}
+
+	if err = rows.Err(); err != nil {
+		slog.Error("ERROR in ExchangeRate List", "error", err)
+		return nil, err
+	}
+
+	return rates, nil
+}
+
+// Set stores rate of currency valid from given day, existing rate for the same day is replaced
+func (m *ExchangeRateModel) Set(rate *ExchangeRate) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	validFrom, err := ParseDate(rate.ValidFrom, false)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO exchange_rates (currency, valid_from, rate) VALUES ($1, $2, $3)
+			ON CONFLICT (currency, valid_from) DO UPDATE SET rate = EXCLUDED.rate`
+
+	if _, err := m.DB.Exec(ctx, query, rate.Currency, validFrom, rate.Rate); err != nil {
+		slog.Error("ERROR in ExchangeRate Set", "error", err)
+		return err
+	}
+
+	rate.ValidFrom = validFrom.Format(dayLayout)
+
+	return nil
+}
+
+func (m *ExchangeRateModel) Delete(currency string, validFrom time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tag, err := m.DB.Exec(ctx, "DELETE FROM exchange_rates WHERE currency = $1 AND valid_from = $2", currency, validFrom)
+	if err != nil {
+		slog.Error("ERROR in ExchangeRate Delete", "error", err)
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// RateTable converts amounts between currencies with rates valid at given day, arithmetic is exact
+type RateTable struct {
+	rates map[string][]rateEntry
+}
+
+type rateEntry struct {
+	validFrom time.Time
+	rate      *big.Rat
+}
+
+// NewRateTable builds table from list of rates in any order
+func NewRateTable(rates []ExchangeRate) (*RateTable, error) {
+	t := &RateTable{rates: make(map[string][]rateEntry)}
+
+	for _, r := range rates {
+		validFrom, err := ParseDate(r.ValidFrom, false)
+		if err != nil {
+			return nil, err
+		}
+
+		// shortest decimal form of rate is taken, so rate 92.5 is exactly 925/10
+		rate, ok := new(big.Rat).SetString(strconv.FormatFloat(r.Rate, 'f', -1, 64))
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate %v of %s", r.Rate, r.Currency)
+		}
+
+		t.rates[r.Currency] = append(t.rates[r.Currency], rateEntry{validFrom: validFrom, rate: rate})
+	}
+
+	for _, entries := range t.rates {
+		slices.SortFunc(entries, func(a, b rateEntry) int { return a.validFrom.Compare(b.validFrom) })
+	}
+
+	return t, nil
+}
+
+// rate returns price of currency in BaseCurrency at given day
+func (t *RateTable) rate(currency string, at time.Time) (*big.Rat, error) {
+	if currency == BaseCurrency {
+		return big.NewRat(1, 1), nil
+	}
+
+	var entries []rateEntry
+	if t != nil {
+		entries = t.rates[currency]
+	}
+
+	i, found := slices.BinarySearchFunc(entries, at, func(e rateEntry, at time.Time) int { return e.validFrom.Compare(at) })
+	if !found {
+		i--
+	}
+	if i < 0 {
+		return nil, fmt.Errorf("%w for %s at %s", ErrNoExchangeRate, currency, at.Format(dayLayout))
+	}
+
+	return entries[i].rate, nil
+}
+
+// Convert converts exact amount in minor units of one currency to minor units of another and rounds result
+func (t *RateTable) Convert(amount *big.Rat, from, to string, at time.Time, rounding Rounding) (int64, error) {
+	if from == to {
+		return rounding.round(amount), nil
+	}
+
+	fromRate, err := t.rate(from, at)
+	if err != nil {
+		return 0, err
+	}
+	toRate, err := t.rate(to, at)
+	if err != nil {
+		return 0, err
+	}
+
+	x := new(big.Rat).Mul(amount, fromRate)
+	x.Quo(x, toRate)
+
+	// minor units of currencies may differ, e.g. 1 RUB is 100 kopecks while JPY has no minor unit
+	exp := CurrencyExponent(to) - CurrencyExponent(from)
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(max(exp, -exp))), nil))
+	if exp > 0 {
+		x.Mul(x, scale)
+	} else {
+		x.Quo(x, scale)
+	}
+
+	return rounding.round(x), nil
+}
+
+// ConvertMoney converts money to another currency
+func (t *RateTable) ConvertMoney(m Money, to string, at time.Time, rounding Rounding) (Money, error) {
+	amount, err := t.Convert(big.NewRat(m.Amount, 1), m.Currency, to, at, rounding)
+	if err != nil {
+		return Money{}, err
+	}
+
+	return Money{Amount: amount, Currency: to}, nil
+}
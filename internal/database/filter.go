@@ -0,0 +1,265 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+type Operator string
+
+const (
+	OpEq      Operator = "="
+	OpIn      Operator = "IN"
+	OpGt      Operator = ">"
+	OpGte     Operator = ">="
+	OpLt      Operator = "<"
+	OpLte     Operator = "<="
+	OpLike    Operator = "LIKE"
+	OpILike   Operator = "ILIKE"
+	OpIsNull  Operator = "IS NULL"
+	OpNotNull Operator = "IS NOT NULL"
+	OpOr      Operator = "OR"
+)
+
+// subscriptionColumns is whitelist of columns which are allowed in filters
+var subscriptionColumns = map[string]bool{
+	"id":           true,
+	"service_name": true,
+	"service_id":   true,
+	"price":        true,
+	"user_id":      true,
+	"start_date":   true,
+	"end_date":     true,
+}
+
+type Condition struct {
+	Field string
+	Op    Operator
+	Value any
+}
+
+// Filter is ordered list of conditions joined with AND.
+// Nil filter matches every row.
+type Filter struct {
+	conditions []Condition
+}
+
+func NewFilter() *Filter {
+	return &Filter{}
+}
+
+func (f *Filter) Where(field string, op Operator, value any) *Filter {
+	f.conditions = append(f.conditions, Condition{Field: field, Op: op, Value: value})
+	return f
+}
+
+func (f *Filter) Eq(field string, value any) *Filter {
+	return f.Where(field, OpEq, value)
+}
+
+func (f *Filter) In(field string, values ...any) *Filter {
+	return f.Where(field, OpIn, values)
+}
+
+func (f *Filter) Gt(field string, value any) *Filter {
+	return f.Where(field, OpGt, value)
+}
+
+func (f *Filter) Gte(field string, value any) *Filter {
+	return f.Where(field, OpGte, value)
+}
+
+func (f *Filter) Lt(field string, value any) *Filter {
+	return f.Where(field, OpLt, value)
+}
+
+func (f *Filter) Lte(field string, value any) *Filter {
+	return f.Where(field, OpLte, value)
+}
+
+// Like matches field against SQL LIKE pattern, where % matches any sequence and _ matches single character
+func (f *Filter) Like(field, pattern string) *Filter {
+	return f.Where(field, OpLike, pattern)
+}
+
+// ILike is case-insensitive Like
+func (f *Filter) ILike(field, pattern string) *Filter {
+	return f.Where(field, OpILike, pattern)
+}
+
+// Contains matches field containing substr ignoring case, wildcards in substr are matched literally
+func (f *Filter) Contains(field, substr string) *Filter {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(substr)
+	return f.ILike(field, "%"+escaped+"%")
+}
+
+func (f *Filter) IsNull(field string) *Filter {
+	return f.Where(field, OpIsNull, nil)
+}
+
+func (f *Filter) NotNull(field string) *Filter {
+	return f.Where(field, OpNotNull, nil)
+}
+
+// Or matches when any of filters matches
+func (f *Filter) Or(filters ...*Filter) *Filter {
+	return f.Where("", OpOr, filters)
+}
+
+func (f *Filter) Conditions() []Condition {
+	if f == nil {
+		return nil
+	}
+	return f.conditions
+}
+
+// fields returns fields compared by filter, including ones of nested filters
+func (f *Filter) fields() []string {
+	var fields []string
+	for _, c := range f.Conditions() {
+		if c.Op != OpOr {
+			fields = append(fields, c.Field)
+			continue
+		}
+		for _, sub := range c.Value.([]*Filter) {
+			fields = append(fields, sub.fields()...)
+		}
+	}
+	return fields
+}
+
+func (c Condition) validate() error {
+	if c.Op == OpOr {
+		filters, ok := c.Value.([]*Filter)
+		if !ok || len(filters) == 0 {
+			return fmt.Errorf("%w: %s requires non-empty list of filters", ErrInvalidFilter, c.Op)
+		}
+		for _, f := range filters {
+			for _, sub := range f.Conditions() {
+				if err := sub.validate(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if !subscriptionColumns[c.Field] {
+		return fmt.Errorf("%w: unknown field %q", ErrInvalidFilter, c.Field)
+	}
+
+	switch c.Op {
+	case OpEq, OpGt, OpGte, OpLt, OpLte, OpIsNull, OpNotNull:
+	case OpIn:
+		values, ok := c.Value.([]any)
+		if !ok || len(values) == 0 {
+			return fmt.Errorf("%w: %s requires non-empty list of values", ErrInvalidFilter, c.Op)
+		}
+	case OpLike, OpILike:
+		if _, ok := c.Value.(string); !ok {
+			return fmt.Errorf("%w: %s requires string pattern", ErrInvalidFilter, c.Op)
+		}
+	default:
+		return fmt.Errorf("%w: unknown operator %q", ErrInvalidFilter, c.Op)
+	}
+
+	return nil
+}
+
+// queryBuilder collects WHERE clauses and binds every value as positional parameter
+type queryBuilder struct {
+	where []string
+	args  []any
+}
+
+// arg binds value and returns its placeholder
+func (b *queryBuilder) arg(value any) string {
+	b.args = append(b.args, value)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+// raw adds clause as is, values must be bound with arg
+func (b *queryBuilder) raw(clause string) {
+	b.where = append(b.where, clause)
+}
+
+func (b *queryBuilder) conditionSQL(c Condition) string {
+	switch c.Op {
+	case OpIn:
+		values := c.Value.([]any)
+		placeholders := make([]string, len(values))
+		for i, v := range values {
+			placeholders[i] = b.arg(v)
+		}
+		return fmt.Sprintf("%s IN (%s)", c.Field, strings.Join(placeholders, ", "))
+	case OpIsNull, OpNotNull:
+		return fmt.Sprintf("%s %s", c.Field, c.Op)
+	case OpOr:
+		var groups []string
+		for _, f := range c.Value.([]*Filter) {
+			conds := []string{}
+			for _, sub := range f.Conditions() {
+				conds = append(conds, b.conditionSQL(sub))
+			}
+			if len(conds) == 0 {
+				conds = append(conds, "TRUE")
+			}
+			groups = append(groups, "("+strings.Join(conds, " AND ")+")")
+		}
+		return "(" + strings.Join(groups, " OR ") + ")"
+	}
+
+	return fmt.Sprintf("%s %s %s", c.Field, c.Op, b.arg(c.Value))
+}
+
+func (b *queryBuilder) addFilter(filter *Filter) error {
+	for _, c := range filter.Conditions() {
+		if err := c.validate(); err != nil {
+			return err
+		}
+
+		b.raw(b.conditionSQL(c))
+	}
+
+	return nil
+}
+
+func (b *queryBuilder) whereClause() string {
+	if len(b.where) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(b.where, " AND ")
+}
+
+// fieldValue returns value of subscription column used for filter comparison
+func fieldValue(sub *Subscription, field string) (any, error) {
+	switch field {
+	case "id":
+		return sub.Id, nil
+	case "service_name":
+		return sub.ServiceName, nil
+	case "service_id":
+		return sub.ServiceId, nil
+	case "price":
+		return sub.Price, nil
+	case "user_id":
+		return sub.UserId, nil
+	case "start_date", "end_date":
+		startDate, endDate, err := ParseDates(sub.StartDate, sub.EndDate)
+		if err != nil {
+			return nil, err
+		}
+		if field == "start_date" {
+			return startDate, nil
+		}
+		if endDate == nil {
+			return nil, nil
+		}
+		return *endDate, nil
+	}
+
+	return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidFilter, field)
+}
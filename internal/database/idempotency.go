@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// IdempotencyRecord is stored result of request made with Idempotency-Key.
+// StatusCode is zero while request is still in progress.
+// Headers are response headers which are replayed together with body, e.g. ETag and Location.
+type IdempotencyRecord struct {
+	Key         string
+	RequestHash string
+	StatusCode  int
+	Headers     map[string]string
+	Body        []byte
+	ExpiresAt   time.Time
+}
+
+type IdempotencyModel struct {
+	DB *pgxpool.Pool
+}
+
+// Reserve stores in-progress record for key if there is no live record yet and returns nil.
+// If key is already used, existing record is returned instead.
+func (m *IdempotencyModel) Reserve(key, requestHash string, ttl time.Duration) (*IdempotencyRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO idempotency_key (key, request_hash, expires_at)
+			VALUES ($1, $2, now() + $3::interval)
+			ON CONFLICT (key) DO UPDATE
+			SET request_hash = EXCLUDED.request_hash, status_code = 0, response_headers = '{}', response_body = NULL, expires_at = EXCLUDED.expires_at
+			WHERE idempotency_key.expires_at < now()
+			RETURNING key`
+
+	var reserved string
+	err := m.DB.QueryRow(ctx, query, key, requestHash, ttl).Scan(&reserved)
+	if err == nil {
+		return nil, nil
+	}
+	if err != pgx.ErrNoRows {
+		slog.Error("ERROR in Idempotency Reserve", "error", err)
+		return nil, err
+	}
+
+	query = "SELECT key, request_hash, status_code, response_headers, response_body, expires_at FROM idempotency_key WHERE key = $1"
+
+	var rec IdempotencyRecord
+	err = m.DB.QueryRow(ctx, query, key).Scan(&rec.Key, &rec.RequestHash, &rec.StatusCode, &rec.Headers, &rec.Body, &rec.ExpiresAt)
+	if err != nil {
+		slog.Error("ERROR in Idempotency Reserve", "error", err)
+		return nil, err
+	}
+
+	return &rec, nil
+}
+
+// Complete stores response of reserved request
+func (m *IdempotencyModel) Complete(key string, statusCode int, headers map[string]string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := "UPDATE idempotency_key SET status_code = $1, response_headers = $2, response_body = $3 WHERE key = $4"
+
+	_, err := m.DB.Exec(ctx, query, statusCode, headers, body, key)
+	if err != nil {
+		slog.Error("ERROR in Idempotency Complete", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// Release removes reservation, so request with the same key can be retried
+func (m *IdempotencyModel) Release(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := "DELETE FROM idempotency_key WHERE key = $1 AND status_code = 0"
+
+	_, err := m.DB.Exec(ctx, query, key)
+	if err != nil {
+		slog.Error("ERROR in Idempotency Release", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (m *IdempotencyModel) DeleteExpired() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := "DELETE FROM idempotency_key WHERE expires_at < now()"
+
+	_, err := m.DB.Exec(ctx, query)
+	if err != nil {
+		slog.Error("ERROR in Idempotency DeleteExpired", "error", err)
+		return err
+	}
+
+	return nil
+}
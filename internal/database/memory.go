@@ -0,0 +1,1713 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemorySubscriptionModel is SubscriptionRepository which keeps subscriptions in memory.
+// It is safe for concurrent use and mirrors behaviour of SubscriptionModel.
+type MemorySubscriptionModel struct {
+	*memorySubscriptions
+	actor Actor
+}
+
+// memorySubscriptions is storage shared by models of all actors
+type memorySubscriptions struct {
+	mu     sync.RWMutex
+	nextId int
+	subs   map[int]Subscription
+	// history holds price changes sorted by effective date, slices are replaced and never modified in place
+	history map[int][]PriceChange
+	// pauses are sorted by start, slices are replaced and never modified in place
+	pauses        map[int][]Pause
+	cancellations map[int]Cancellation
+	// auditLog is append only, entries are sorted by id
+	auditLog    []AuditEntry
+	nextAuditId int64
+	// serviceKeys maps lookup keys of service names and aliases to service ids
+	services      map[int]Service
+	serviceKeys   map[string]int
+	nextServiceId int
+	plans         map[int]Plan
+	nextPlanId    int
+}
+
+func NewMemorySubscriptionModel() *MemorySubscriptionModel {
+	return &MemorySubscriptionModel{memorySubscriptions: &memorySubscriptions{
+		nextId:  1,
+		subs:    make(map[int]Subscription),
+		history: make(map[int][]PriceChange),
+		pauses:  make(map[int][]Pause),
+
+		cancellations: make(map[int]Cancellation),
+		nextAuditId:   1,
+		services:      make(map[int]Service),
+		serviceKeys:   make(map[string]int),
+		nextServiceId: 1,
+		plans:         make(map[int]Plan),
+		nextPlanId:    1,
+	}}
+}
+
+// checkpoint saves state of storage, returned function restores it. Caller must hold the lock.
+func (m *memorySubscriptions) checkpoint() func() {
+	subs, history, pauses, cancellations := maps.Clone(m.subs), maps.Clone(m.history), maps.Clone(m.pauses), maps.Clone(m.cancellations)
+	services, serviceKeys, plans := maps.Clone(m.services), maps.Clone(m.serviceKeys), maps.Clone(m.plans)
+	nextId, nextServiceId, nextPlanId := m.nextId, m.nextServiceId, m.nextPlanId
+	auditLog, nextAuditId := m.auditLog, m.nextAuditId
+
+	return func() {
+		m.subs, m.history, m.pauses, m.cancellations = subs, history, pauses, cancellations
+		m.services, m.serviceKeys, m.plans = services, serviceKeys, plans
+		m.nextId, m.nextServiceId, m.nextPlanId = nextId, nextServiceId, nextPlanId
+		m.auditLog, m.nextAuditId = auditLog, nextAuditId
+	}
+}
+
+// As returns model which records changes in audit log as made by actor, storage is shared
+func (m *MemorySubscriptionModel) As(actor Actor) SubscriptionRepository {
+	return &MemorySubscriptionModel{memorySubscriptions: m.memorySubscriptions, actor: actor}
+}
+
+// likeToRegexp converts SQL LIKE pattern to anchored regular expression, backslash escapes next character
+func likeToRegexp(pattern string, ignoreCase bool) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	if ignoreCase {
+		sb.WriteString("(?i)")
+	}
+	sb.WriteString("^")
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			sb.WriteString("(?s:.*)")
+		case r == '_':
+			sb.WriteString("(?s:.)")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+
+	return regexp.Compile(sb.String())
+}
+
+func matchCondition(sub *Subscription, c Condition) (bool, error) {
+	if err := c.validate(); err != nil {
+		return false, err
+	}
+
+	if c.Op == OpOr {
+		for _, f := range c.Value.([]*Filter) {
+			ok, err := matchFilter(sub, f)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	}
+
+	column, err := fieldValue(sub, c.Field)
+	if err != nil {
+		return false, err
+	}
+
+	switch c.Op {
+	case OpIsNull:
+		return column == nil, nil
+	case OpNotNull:
+		return column != nil, nil
+	}
+
+	// NULL never satisfies comparison, same as in SQL
+	if column == nil {
+		return false, nil
+	}
+
+	switch c.Op {
+	case OpIn:
+		for _, v := range c.Value.([]any) {
+			if res, ok := compareValues(column, v); ok && res == 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	case OpLike, OpILike:
+		re, err := likeToRegexp(c.Value.(string), c.Op == OpILike)
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(fmt.Sprint(column)), nil
+	}
+
+	res, ok := compareValues(column, c.Value)
+	if !ok {
+		return false, fmt.Errorf("%w: value %v is not comparable with %s", ErrInvalidFilter, c.Value, c.Field)
+	}
+
+	switch c.Op {
+	case OpEq:
+		return res == 0, nil
+	case OpGt:
+		return res > 0, nil
+	case OpGte:
+		return res >= 0, nil
+	case OpLt:
+		return res < 0, nil
+	case OpLte:
+		return res <= 0, nil
+	}
+
+	return false, nil
+}
+
+// matchFilter reports whether subscription satisfies every filter condition
+func matchFilter(sub *Subscription, filter *Filter) (bool, error) {
+	for _, c := range filter.Conditions() {
+		ok, err := matchCondition(sub, c)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func (m *MemorySubscriptionModel) Insert(sub *Subscription) error {
+	if _, _, err := ParseDates(sub.StartDate, sub.EndDate); err != nil {
+		slog.Error("ERROR in MemorySubscription Insert", "error", err)
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.insert(sub)
+}
+
+func (m *MemorySubscriptionModel) InsertMany(subs []*Subscription) error {
+	for _, sub := range subs {
+		if _, _, err := ParseDates(sub.StartDate, sub.EndDate); err != nil {
+			slog.Error("ERROR in MemorySubscription InsertMany", "error", err)
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	restore := m.checkpoint()
+	for _, sub := range subs {
+		if err := m.insert(sub); err != nil {
+			restore()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insert stores new subscription, caller must hold the lock
+func (m *MemorySubscriptionModel) insert(sub *Subscription) error {
+	if err := m.resolveService(sub); err != nil {
+		return err
+	}
+
+	sub.normalize()
+	sub.Id = m.nextId
+	sub.Version = 1
+	m.nextId++
+	m.subs[sub.Id] = *sub
+
+	m.audit(AuditCreate, sub.Id, nil)
+
+	return nil
+}
+
+// stored returns subscription which exists and isn't deleted, caller must hold the lock
+func (m *MemorySubscriptionModel) stored(id int) (Subscription, bool) {
+	sub, ok := m.subs[id]
+	return sub, ok && sub.DeletedAt == nil
+}
+
+// checkVersion returns stored subscription if it exists and its version matches, caller must hold the lock
+func (m *MemorySubscriptionModel) checkVersion(id, version int) (Subscription, error) {
+	stored, ok := m.stored(id)
+	if !ok {
+		return stored, ErrRecordNotFound
+	}
+	if version != 0 && stored.Version != version {
+		return stored, ErrEditConflict
+	}
+
+	return stored, nil
+}
+
+func (m *MemorySubscriptionModel) Get(id int) (*Subscription, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sub, ok := m.stored(id)
+	if !ok {
+		return nil, nil
+	}
+
+	m.loadState(&sub)
+	sub.setStatus(Today())
+
+	return &sub, nil
+}
+
+func (m *MemorySubscriptionModel) Update(sub *Subscription) error {
+	if _, _, err := ParseDates(sub.StartDate, sub.EndDate); err != nil {
+		slog.Error("ERROR in MemorySubscription Update", "error", err)
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.update(sub)
+}
+
+// update replaces stored subscription, caller must hold the lock
+func (m *MemorySubscriptionModel) update(sub *Subscription) error {
+	stored, err := m.checkVersion(sub.Id, sub.Version)
+	if err != nil {
+		return err
+	}
+	if err := m.checkEndDate(stored, sub); err != nil {
+		return err
+	}
+	if err := m.resolveService(sub); err != nil {
+		return err
+	}
+
+	before := m.snapshot(sub.Id)
+	m.ensurePriceHistory(sub.Id)
+
+	sub.normalize()
+	sub.Version = stored.Version + 1
+	m.subs[sub.Id] = *sub
+
+	m.recordPrice(sub.Id, Today())
+	m.audit(AuditUpdate, sub.Id, before)
+
+	return nil
+}
+
+func (m *MemorySubscriptionModel) UpdateFields(sub *Subscription, fields []string) error {
+	if _, _, err := ParseDates(sub.StartDate, sub.EndDate); err != nil {
+		slog.Error("ERROR in MemorySubscription UpdateFields", "error", err)
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(fields) == 0 {
+		return nil
+	}
+	fields = planFields(fields)
+
+	stored, err := m.checkVersion(sub.Id, sub.Version)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(fields, "end_date") {
+		if err := m.checkEndDate(stored, sub); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(fields, "service_name") {
+		if err := m.resolveService(sub); err != nil {
+			return err
+		}
+	}
+	before := m.snapshot(sub.Id)
+
+	for _, f := range fields {
+		switch f {
+		case "service_name":
+			stored.ServiceId = sub.ServiceId
+			stored.ServiceName = sub.ServiceName
+		case "plan_id":
+			stored.PlanId = sub.PlanId
+		case "price":
+			stored.Price = sub.Price
+		case "currency":
+			stored.Currency = sub.Currency
+		case "user_id":
+			stored.UserId = sub.UserId
+		case "start_date":
+			stored.StartDate = sub.StartDate
+		case "end_date":
+			stored.EndDate = sub.EndDate
+		case "billing_period":
+			stored.BillingPeriod = sub.BillingPeriod
+		default:
+			return fmt.Errorf("unknown field %q", f)
+		}
+	}
+	m.ensurePriceHistory(sub.Id)
+
+	stored.normalize()
+	sub.BillingPeriod = stored.BillingPeriod
+	stored.Version++
+	sub.Version = stored.Version
+	m.subs[sub.Id] = stored
+
+	m.recordPrice(sub.Id, Today())
+	m.audit(AuditUpdate, sub.Id, before)
+
+	return nil
+}
+
+func (m *MemorySubscriptionModel) Delete(id int, version int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.delete(id, version)
+}
+
+// delete removes stored subscription, caller must hold the lock
+func (m *MemorySubscriptionModel) delete(id int, version int) error {
+	if _, err := m.checkVersion(id, version); err != nil {
+		return err
+	}
+
+	before := m.snapshot(id)
+
+	sub := m.subs[id]
+	now := time.Now().UTC()
+	sub.DeletedAt = &now
+	sub.Version++
+	m.subs[id] = sub
+
+	m.audit(AuditDelete, id, before)
+
+	return nil
+}
+
+func (m *MemorySubscriptionModel) applyBatchOperation(op BatchOperation) BatchResult {
+	switch op.Op {
+	case BatchCreate, BatchUpdate:
+		sub := *op.Subscription
+		if _, _, err := ParseDates(sub.StartDate, sub.EndDate); err != nil {
+			return BatchResult{Err: err}
+		}
+
+		if op.Op == BatchCreate {
+			if err := m.insert(&sub); err != nil {
+				return BatchResult{Err: err}
+			}
+			return BatchResult{Subscription: &sub}
+		}
+
+		sub.Id = op.Id
+		sub.Version = op.Version
+		if err := m.update(&sub); err != nil {
+			return BatchResult{Err: err}
+		}
+		return BatchResult{Subscription: &sub}
+	case BatchDelete:
+		return BatchResult{Err: m.delete(op.Id, op.Version)}
+	}
+
+	return BatchResult{Err: fmt.Errorf("unknown batch operation %q", op.Op)}
+}
+
+func (m *MemorySubscriptionModel) Batch(ctx context.Context, ops []BatchOperation, atomic bool) ([]BatchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, batchTimeout)
+	defer cancel()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	restoreBatch := m.checkpoint()
+
+	results := make([]BatchResult, len(ops))
+
+	for i, op := range ops {
+		if err := ctx.Err(); err != nil {
+			restoreBatch()
+			slog.Error("ERROR in MemorySubscription Batch", "error", err)
+			return nil, err
+		}
+
+		// failed operation may have created service, it is rolled back like savepoint is
+		restore := m.checkpoint()
+		results[i] = m.applyBatchOperation(op)
+
+		if results[i].Err != nil {
+			restore()
+		}
+		if atomic && results[i].Err != nil {
+			restoreBatch()
+			rollbackResults(results, i)
+			break
+		}
+	}
+
+	return results, nil
+}
+
+// sortedIds returns ids of stored subscriptions in ascending order, caller must hold the lock
+func (m *MemorySubscriptionModel) sortedIds() []int {
+	ids := make([]int, 0, len(m.subs))
+	for id := range m.subs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	return ids
+}
+
+func (m *MemorySubscriptionModel) GetList(filter *Filter, opts ListOptions) (*SubscriptionPage, error) {
+	if opts.AsOf != nil {
+		return m.getListAt(*opts.AsOf, filter, opts)
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	subs := make([]Subscription, 0, len(m.subs))
+	for _, id := range m.sortedIds() {
+		subs = append(subs, m.subs[id])
+	}
+
+	page, err := listPage(subs, filter, opts)
+	if err != nil && !errors.Is(err, ErrInvalidCursor) {
+		slog.Error("ERROR in MemorySubscription GetList", "error", err)
+	}
+
+	return page, err
+}
+
+// listPage filters, sorts and paginates subscriptions the same way as database does
+func listPage(stored []Subscription, filter *Filter, opts ListOptions) (*SubscriptionPage, error) {
+	sorts, err := normalizeSort(opts.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	var after []any
+	if opts.Cursor != "" {
+		after, err = decodeCursor(opts.Cursor, sorts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	type row struct {
+		sub    *Subscription
+		values []any
+	}
+	rows := []row{}
+
+	for _, sub := range stored {
+		if sub.DeletedAt != nil && !opts.IncludeDeleted {
+			continue
+		}
+
+		ok, err := matchFilter(&sub, filter)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		r := row{sub: &sub}
+		for _, s := range sorts {
+			v, err := sortValue(&sub, s.Field)
+			if err != nil {
+				return nil, err
+			}
+			r.values = append(r.values, v)
+		}
+		rows = append(rows, r)
+	}
+
+	count := len(rows)
+
+	slices.SortFunc(rows, func(a, b row) int {
+		return compareBySort(sorts, a.values, b.values)
+	})
+
+	subs := []*Subscription{}
+	for _, r := range rows {
+		if after == nil || compareBySort(sorts, r.values, after) > 0 {
+			subs = append(subs, r.sub)
+		}
+	}
+
+	if opts.Offset < len(subs) {
+		subs = subs[opts.Offset:]
+	} else {
+		subs = []*Subscription{}
+	}
+
+	limit := opts.limit()
+	if len(subs) > limit+1 {
+		subs = subs[:limit+1]
+	}
+
+	page, err := newPage(subs, limit, sorts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.WithTotal {
+		page.Total = &count
+	}
+
+	return page, nil
+}
+
+func (m *MemorySubscriptionModel) GetPrice(startPeriodInput, endPeriodInput time.Time, filter *Filter, opts PriceOptions) (*PriceReport, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	report := newPriceReport(startPeriodInput, endPeriodInput, opts)
+
+	for _, id := range m.sortedIds() {
+		sub := m.subs[id]
+
+		ok, err := matchFilter(&sub, filter)
+		if err != nil {
+			slog.Error("ERROR in MemorySubscription GetPrice", "error", err)
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		startSub, endSub, err := ParseDates(sub.StartDate, sub.EndDate)
+		if err != nil {
+			slog.Error("ERROR in MemorySubscription GetPrice", "error", err)
+			return nil, err
+		}
+		endSub = sub.billedEnd(endSub)
+
+		if startSub.After(endPeriodInput) || (endSub != nil && endSub.Before(startPeriodInput)) {
+			continue
+		}
+
+		sub.Pauses = m.pauses[id]
+		if err := report.add(&sub, startSub, endSub, m.history[id]); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// ensurePriceHistory stores current price effective from subscription start when it has no history yet, caller must hold the lock
+func (m *MemorySubscriptionModel) ensurePriceHistory(id int) {
+	if len(m.history[id]) > 0 {
+		return
+	}
+
+	sub := m.subs[id]
+	m.history[id] = historyOf(&sub, nil)
+}
+
+// setPriceChange inserts or replaces price change of the same day, caller must hold the lock
+func (m *MemorySubscriptionModel) setPriceChange(id int, change PriceChange) {
+	history := slices.Clone(m.history[id])
+
+	i, found := slices.BinarySearchFunc(history, change.effectiveFrom, func(c PriceChange, day time.Time) int { return c.effectiveFrom.Compare(day) })
+	if found {
+		history[i] = change
+	} else {
+		history = slices.Insert(history, i, change)
+	}
+
+	m.history[id] = history
+}
+
+// recordPrice stores current price effective from day or subscription start when it starts later, caller must hold the lock
+func (m *MemorySubscriptionModel) recordPrice(id int, day time.Time) {
+	sub := m.subs[id]
+
+	start, err := ParseDate(sub.StartDate, false)
+	if err != nil {
+		return
+	}
+	if start.After(day) {
+		day = start
+	}
+
+	price := Money{Amount: sub.Price, Currency: sub.Currency}
+	if priceAt(&sub, m.history[id], day) == price {
+		return
+	}
+
+	m.setPriceChange(id, newPriceChange(day, price.Amount, price.Currency))
+}
+
+func (m *MemorySubscriptionModel) PriceHistory(id int) ([]PriceChange, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sub, ok := m.stored(id)
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	return slices.Clone(historyOf(&sub, m.history[id])), nil
+}
+
+func (m *MemorySubscriptionModel) SchedulePriceChange(id int, effectiveFrom time.Time, price Money) (*PriceChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.stored(id); !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	m.ensurePriceHistory(id)
+
+	var replaced *PriceChange
+	if i := slices.IndexFunc(m.history[id], func(c PriceChange) bool { return c.effectiveFrom.Equal(effectiveFrom) }); i >= 0 {
+		replaced = &m.history[id][i]
+	}
+
+	change := newPriceChange(effectiveFrom, price.Amount, price.Currency)
+	m.setPriceChange(id, change)
+
+	m.writeAudit(AuditSchedulePrice, id, map[string]FieldChange{"price_change": valueChange(replaced, &change)})
+
+	return &change, nil
+}
+
+func (m *MemorySubscriptionModel) CancelPriceChange(id int, effectiveFrom time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.stored(id); !ok || !effectiveFrom.After(Today()) {
+		return ErrRecordNotFound
+	}
+
+	i := slices.IndexFunc(m.history[id], func(c PriceChange) bool { return c.effectiveFrom.Equal(effectiveFrom) })
+	if i < 0 {
+		return ErrRecordNotFound
+	}
+
+	cancelled := m.history[id][i]
+	m.history[id] = slices.Delete(slices.Clone(m.history[id]), i, i+1)
+
+	m.writeAudit(AuditCancelPrice, id, map[string]FieldChange{"price_change": valueChange(&cancelled, nil)})
+
+	return nil
+}
+
+func (m *MemorySubscriptionModel) ApplyPriceChanges(day time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	changed := 0
+	for _, id := range m.sortedIds() {
+		history := m.history[id]
+		sub, ok := m.stored(id)
+		if !ok {
+			continue
+		}
+
+		i, found := slices.BinarySearchFunc(history, day, func(c PriceChange, day time.Time) int { return c.effectiveFrom.Compare(day) })
+		if !found {
+			i--
+		}
+		if i < 0 || history[i].Price == (Money{Amount: sub.Price, Currency: sub.Currency}) {
+			continue
+		}
+
+		m.writeAudit(AuditApplyPrice, id, priceAudit(sub.Price, sub.Currency, history[i].Price.Amount, history[i].Price.Currency))
+
+		sub.Price = history[i].Price.Amount
+		sub.Currency = history[i].Price.Currency
+		sub.Version++
+		m.subs[id] = sub
+		changed++
+	}
+
+	return changed, nil
+}
+
+func (m *MemorySubscriptionModel) Pause(id int, from time.Time, until *time.Time) (*Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub, ok := m.stored(id)
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	m.loadState(&sub)
+
+	if err := checkPause(&sub, from, until); err != nil {
+		return nil, err
+	}
+
+	pauses := append(slices.Clone(m.pauses[id]), newPause(from, until))
+	slices.SortFunc(pauses, func(a, b Pause) int { return a.from.Compare(b.from) })
+	m.pauses[id] = pauses
+
+	return m.touch(AuditPause, id, &sub), nil
+}
+
+func (m *MemorySubscriptionModel) Resume(id int, at time.Time) (*Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub, ok := m.stored(id)
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	m.loadState(&sub)
+
+	p, err := resumedPause(&sub, at)
+	if err != nil {
+		return nil, err
+	}
+
+	i := slices.IndexFunc(sub.Pauses, func(other Pause) bool { return other.from.Equal(p.from) })
+	pauses := slices.Clone(sub.Pauses)
+	if at.After(p.from) {
+		until := at.AddDate(0, 0, -1)
+		pauses[i] = newPause(p.from, &until)
+	} else {
+		pauses = slices.Delete(pauses, i, i+1)
+	}
+	m.pauses[id] = pauses
+
+	return m.touch(AuditResume, id, &sub), nil
+}
+
+// touch increments version of subscription changed by action, records the change in audit log
+// and returns subscription as Get does, caller must hold the lock
+func (m *MemorySubscriptionModel) touch(action AuditAction, id int, before *Subscription) *Subscription {
+	sub := m.subs[id]
+	sub.Version++
+	m.subs[id] = sub
+
+	m.audit(action, id, before)
+
+	m.loadState(&sub)
+	sub.setStatus(Today())
+
+	return &sub
+}
+
+// snapshot returns subscription with its state as it is recorded in audit log, nil when it doesn't exist.
+// Caller must hold the lock.
+func (m *MemorySubscriptionModel) snapshot(id int) *Subscription {
+	sub, ok := m.subs[id]
+	if !ok {
+		return nil
+	}
+	m.loadState(&sub)
+
+	return &sub
+}
+
+// audit records changes of subscription made by action since before snapshot, caller must hold the lock
+func (m *MemorySubscriptionModel) audit(action AuditAction, id int, before *Subscription) {
+	m.writeAudit(action, id, auditChanges(before, m.snapshot(id)))
+}
+
+// writeAudit appends entry to audit log, caller must hold the lock
+func (m *MemorySubscriptionModel) writeAudit(action AuditAction, id int, changes map[string]FieldChange) {
+	m.auditLog = append(m.auditLog, AuditEntry{
+		Id:             m.nextAuditId,
+		SubscriptionId: id,
+		Action:         action,
+		Actor:          m.actor.name(),
+		RequestId:      m.actor.RequestId,
+		Changes:        changes,
+		CreatedAt:      time.Now().UTC(),
+	})
+	m.nextAuditId++
+}
+
+// checkEndDate is checkEndDate with cancellation of stored subscription, caller must hold the lock
+func (m *MemorySubscriptionModel) checkEndDate(stored Subscription, sub *Subscription) error {
+	m.loadState(&stored)
+	return checkEndDate(&stored, sub)
+}
+
+// loadState fills pauses and cancellation of subscription, caller must hold the lock
+func (m *MemorySubscriptionModel) loadState(sub *Subscription) {
+	sub.Pauses = m.pauses[sub.Id]
+	if c, ok := m.cancellations[sub.Id]; ok {
+		sub.Cancellation = &c
+	}
+}
+
+func (m *MemorySubscriptionModel) Cancel(id int, cancellation Cancellation) (*Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub, ok := m.stored(id)
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	m.loadState(&sub)
+
+	effective, err := cancelDate(&sub, cancellation.Mode, Today())
+	if err != nil {
+		return nil, err
+	}
+
+	_, cancellation.previousEnd, _ = ParseDates(sub.StartDate, sub.EndDate)
+	cancellation.CancelledAt = time.Now().UTC()
+	cancellation.setEffectiveDate(effective)
+	m.cancellations[id] = cancellation
+
+	stored := m.subs[id]
+	stored.EndDate = formatDate(effective, true)
+	m.subs[id] = stored
+
+	return m.touch(AuditCancel, id, &sub), nil
+}
+
+func (m *MemorySubscriptionModel) Reactivate(id int) (*Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub, ok := m.stored(id)
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	m.loadState(&sub)
+
+	if err := checkReactivate(&sub, Today()); err != nil {
+		return nil, err
+	}
+
+	stored := m.subs[id]
+	stored.EndDate = ""
+	if end := sub.Cancellation.previousEnd; end != nil {
+		stored.EndDate = formatDate(*end, true)
+	}
+	m.subs[id] = stored
+	delete(m.cancellations, id)
+
+	return m.touch(AuditReactivate, id, &sub), nil
+}
+
+func (m *MemorySubscriptionModel) GetChurn(startPeriod, endPeriod time.Time, filter *Filter, groupBy []string) (*ChurnReport, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[ChurnRow]int)
+
+	for id, c := range m.cancellations {
+		if c.effectiveDate.Before(startPeriod) || c.effectiveDate.After(endPeriod) {
+			continue
+		}
+
+		sub := m.subs[id]
+		ok, err := matchFilter(&sub, filter)
+		if err != nil {
+			slog.Error("ERROR in MemorySubscription GetChurn", "error", err)
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		var key ChurnRow
+		for _, g := range groupBy {
+			switch g {
+			case "reason":
+				key.Reason = c.Reason
+			case "service_name":
+				key.ServiceName = sub.ServiceName
+			case "month":
+				key.month = c.effectiveDate.AddDate(0, 0, 1-c.effectiveDate.Day())
+			}
+		}
+		counts[key]++
+	}
+
+	rows := make([]ChurnRow, 0, len(counts))
+	for key, n := range counts {
+		key.Cancellations = n
+		rows = append(rows, key)
+	}
+	slices.SortFunc(rows, func(a, b ChurnRow) int { return compareChurnRows(&a, &b) })
+
+	report := newChurnReport(startPeriod, endPeriod, groupBy)
+	for _, row := range rows {
+		report.add(row)
+	}
+
+	return report, nil
+}
+
+func (m *MemorySubscriptionModel) Restore(id int) (*Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub, ok := m.subs[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	if sub.DeletedAt == nil {
+		return nil, ErrNotDeleted
+	}
+	before := m.snapshot(id)
+
+	sub.DeletedAt = nil
+	m.subs[id] = sub
+
+	return m.touch(AuditRestore, id, before), nil
+}
+
+func (m *MemorySubscriptionModel) Purge(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub, ok := m.subs[id]
+	if !ok {
+		return ErrRecordNotFound
+	}
+	if sub.DeletedAt == nil {
+		return ErrNotDeleted
+	}
+
+	m.purge(id)
+
+	return nil
+}
+
+func (m *MemorySubscriptionModel) PurgeDeleted(before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	purged := 0
+	for _, id := range m.sortedIds() {
+		if sub := m.subs[id]; sub.DeletedAt != nil && sub.DeletedAt.Before(before) {
+			m.purge(id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// purge removes subscription with its history, its audit log is kept. Caller must hold the lock.
+func (m *MemorySubscriptionModel) purge(id int) {
+	before := m.snapshot(id)
+
+	delete(m.subs, id)
+	delete(m.history, id)
+	delete(m.pauses, id)
+	delete(m.cancellations, id)
+
+	m.audit(AuditPurge, id, before)
+}
+
+func (m *MemorySubscriptionModel) History(id int, opts ListOptions) (*AuditPage, error) {
+	var after int64
+	if opts.Cursor != "" {
+		var err error
+		if after, err = decodeAuditCursor(opts.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	limit := opts.limit()
+	entries := []AuditEntry{}
+	for i := len(m.auditLog) - 1; i >= 0 && len(entries) <= limit; i-- {
+		e := m.auditLog[i]
+		if e.SubscriptionId == id && (after == 0 || e.Id < after) {
+			entries = append(entries, e)
+		}
+	}
+
+	if _, ok := m.subs[id]; !ok && len(entries) == 0 && opts.Cursor == "" {
+		return nil, ErrRecordNotFound
+	}
+
+	return newAuditPage(entries, limit), nil
+}
+
+// resolveService links subscription to service given by id or by name the same way as SubscriptionModel does,
+// caller must hold the lock
+func (m *memorySubscriptions) resolveService(sub *Subscription) error {
+	if err := m.applyPlan(sub); err != nil {
+		return err
+	}
+
+	var s Service
+	switch id, ok := m.serviceKeys[ServiceKey(sub.ServiceName)]; {
+	case sub.ServiceId != 0:
+		if s, ok = m.services[sub.ServiceId]; !ok {
+			return ErrServiceNotFound
+		}
+	case ok:
+		s = m.services[id]
+	default:
+		s = Service{Name: sub.ServiceName}
+		if err := m.insertService(&s); err != nil {
+			return err
+		}
+	}
+
+	return s.fill(sub)
+}
+
+// applyPlan fills subscription from its plan the same way as SubscriptionModel does, caller must hold the lock
+func (m *memorySubscriptions) applyPlan(sub *Subscription) error {
+	if sub.PlanId == 0 {
+		return nil
+	}
+
+	p, ok := m.plans[sub.PlanId]
+	if !ok {
+		return ErrPlanNotFound
+	}
+
+	if sub.ServiceId == 0 && sub.ServiceName != "" {
+		id, ok := m.serviceKeys[ServiceKey(sub.ServiceName)]
+		if !ok {
+			return ErrPlanMismatch
+		}
+		sub.ServiceId = id
+	}
+
+	return p.fill(sub)
+}
+
+// insertService stores new service, caller must hold the lock
+func (m *memorySubscriptions) insertService(s *Service) error {
+	s.normalize()
+	s.Id = m.nextServiceId
+
+	if err := m.setServiceNames(s); err != nil {
+		return err
+	}
+
+	m.nextServiceId++
+	m.services[s.Id] = *s
+
+	return nil
+}
+
+// setServiceNames replaces lookup keys of service, caller must hold the lock
+func (m *memorySubscriptions) setServiceNames(s *Service) error {
+	keys := s.keys()
+	for _, key := range keys {
+		if id, ok := m.serviceKeys[key]; ok && id != s.Id {
+			return ErrServiceNameConflict
+		}
+	}
+
+	maps.DeleteFunc(m.serviceKeys, func(_ string, id int) bool { return id == s.Id })
+	for _, key := range keys {
+		m.serviceKeys[key] = s.Id
+	}
+
+	return nil
+}
+
+// moveSubscriptions makes subscriptions of service with id subscriptions of service s, caller must hold the lock
+func (m *MemorySubscriptionModel) moveSubscriptions(id int, s *Service) {
+	for _, subId := range m.sortedIds() {
+		sub := m.subs[subId]
+		if sub.ServiceId != id || (sub.ServiceId == s.Id && sub.ServiceName == s.Name) {
+			continue
+		}
+
+		before := m.snapshot(subId)
+		sub.ServiceId, sub.ServiceName = s.Id, s.Name
+		sub.Version++
+		m.subs[subId] = sub
+		m.audit(AuditUpdate, subId, before)
+	}
+}
+
+// MemoryServiceModel is ServiceRepository which keeps service catalog in memory, it shares storage with MemorySubscriptionModel
+type MemoryServiceModel struct {
+	*memorySubscriptions
+	actor Actor
+}
+
+func (m *MemoryServiceModel) As(actor Actor) ServiceRepository {
+	return &MemoryServiceModel{memorySubscriptions: m.memorySubscriptions, actor: actor}
+}
+
+// subscriptions returns model which changes subscriptions of the same storage as made by the same actor
+func (m *MemoryServiceModel) subscriptions() *MemorySubscriptionModel {
+	return &MemorySubscriptionModel{memorySubscriptions: m.memorySubscriptions, actor: m.actor}
+}
+
+// cloneService returns copy of service which doesn't share aliases with stored one
+func cloneService(s Service) *Service {
+	s.Aliases = slices.Clone(s.Aliases)
+	return &s
+}
+
+func (m *MemoryServiceModel) List(category string) ([]Service, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	services := []Service{}
+	for _, s := range m.services {
+		if category == "" || s.Category == category {
+			services = append(services, *cloneService(s))
+		}
+	}
+	slices.SortFunc(services, func(a, b Service) int { return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.Id, b.Id)) })
+
+	return services, nil
+}
+
+func (m *MemoryServiceModel) Get(id int) (*Service, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, ok := m.services[id]
+	if !ok {
+		return nil, nil
+	}
+
+	return cloneService(s), nil
+}
+
+func (m *MemoryServiceModel) Lookup(name string) (*Service, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.serviceKeys[ServiceKey(name)]
+	if !ok {
+		return nil, nil
+	}
+
+	return cloneService(m.services[id]), nil
+}
+
+func (m *MemoryServiceModel) Insert(s *Service) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.insertService(s)
+}
+
+func (m *MemoryServiceModel) Update(s *Service) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.services[s.Id]; !ok {
+		return ErrRecordNotFound
+	}
+
+	s.normalize()
+	if err := m.setServiceNames(s); err != nil {
+		return err
+	}
+	m.services[s.Id] = *cloneService(*s)
+
+	m.subscriptions().moveSubscriptions(s.Id, s)
+
+	return nil
+}
+
+func (m *MemoryServiceModel) Delete(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.services[id]; !ok {
+		return ErrRecordNotFound
+	}
+	for _, sub := range m.subs {
+		if sub.ServiceId == id {
+			return ErrServiceInUse
+		}
+	}
+
+	delete(m.services, id)
+	maps.DeleteFunc(m.serviceKeys, func(_ string, serviceId int) bool { return serviceId == id })
+	maps.DeleteFunc(m.plans, func(_ int, p Plan) bool { return p.ServiceId == id })
+
+	return nil
+}
+
+func (m *MemoryServiceModel) Merge(id int, merged []int) (*Service, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.services[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	for _, mergedId := range merged {
+		if _, ok := m.services[mergedId]; !ok {
+			return nil, ErrRecordNotFound
+		}
+	}
+
+	restore := m.checkpoint()
+	target := cloneService(stored)
+	for _, mergedId := range merged {
+		if mergedId == id {
+			continue
+		}
+
+		s, ok := m.services[mergedId]
+		if !ok {
+			// the same service listed twice
+			continue
+		}
+
+		m.subscriptions().moveSubscriptions(s.Id, target)
+		if err := m.movePlans(s.Id, target.Id); err != nil {
+			restore()
+			return nil, err
+		}
+		delete(m.services, s.Id)
+		maps.DeleteFunc(m.serviceKeys, func(_ string, serviceId int) bool { return serviceId == s.Id })
+
+		target.mergeNames(&s)
+	}
+
+	if err := m.setServiceNames(target); err != nil {
+		restore()
+		return nil, err
+	}
+	m.services[id] = *cloneService(*target)
+
+	return target, nil
+}
+
+// planNameUsed reports whether service has other plan with the same name, caller must hold the lock
+func (m *memorySubscriptions) planNameUsed(p *Plan) bool {
+	for _, other := range m.plans {
+		if other.Id != p.Id && other.ServiceId == p.ServiceId && strings.ToLower(other.Name) == strings.ToLower(p.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// movePlans makes plans of service with id plans of service with serviceId, caller must hold the lock
+func (m *memorySubscriptions) movePlans(id, serviceId int) error {
+	for planId, p := range m.plans {
+		if p.ServiceId != id {
+			continue
+		}
+
+		p.ServiceId = serviceId
+		if m.planNameUsed(&p) {
+			return ErrPlanNameConflict
+		}
+		m.plans[planId] = p
+	}
+
+	return nil
+}
+
+// MemoryPlanModel is PlanRepository which keeps plans in memory, it shares storage with MemorySubscriptionModel
+type MemoryPlanModel struct {
+	*memorySubscriptions
+	actor Actor
+}
+
+func (m *MemoryPlanModel) As(actor Actor) PlanRepository {
+	return &MemoryPlanModel{memorySubscriptions: m.memorySubscriptions, actor: actor}
+}
+
+func (m *MemoryPlanModel) List(serviceId int) ([]Plan, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	plans := []Plan{}
+	for _, p := range m.plans {
+		if p.ServiceId == serviceId {
+			plans = append(plans, p)
+		}
+	}
+	slices.SortFunc(plans, func(a, b Plan) int {
+		return cmp.Or(cmp.Compare(a.Price.Amount, b.Price.Amount), cmp.Compare(a.Id, b.Id))
+	})
+
+	return plans, nil
+}
+
+func (m *MemoryPlanModel) Get(id int) (*Plan, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	p, ok := m.plans[id]
+	if !ok {
+		return nil, nil
+	}
+
+	return &p, nil
+}
+
+func (m *MemoryPlanModel) Insert(p *Plan) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.services[p.ServiceId]; !ok {
+		return ErrServiceNotFound
+	}
+
+	p.normalize()
+	if m.planNameUsed(p) {
+		return ErrPlanNameConflict
+	}
+
+	p.Id = m.nextPlanId
+	m.nextPlanId++
+	m.plans[p.Id] = *p
+
+	return nil
+}
+
+func (m *MemoryPlanModel) Update(p *Plan, policy PricePolicy) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, ok := m.plans[p.Id]
+	if !ok {
+		return 0, ErrRecordNotFound
+	}
+
+	p.normalize()
+	p.ServiceId = old.ServiceId
+	if m.planNameUsed(p) {
+		return 0, ErrPlanNameConflict
+	}
+	m.plans[p.Id] = *p
+
+	if policy == PriceKeep || old.Price == p.Price {
+		return 0, nil
+	}
+
+	subs := &MemorySubscriptionModel{memorySubscriptions: m.memorySubscriptions, actor: m.actor}
+
+	return subs.propagatePlanPrice(&old, p.Price, policy)
+}
+
+// propagatePlanPrice sets new price to subscriptions following plan the same way as PlanModel does, caller must hold the lock
+func (m *MemorySubscriptionModel) propagatePlanPrice(old *Plan, price Money, policy PricePolicy) (int, error) {
+	today := Today()
+
+	var changed int
+	for _, id := range m.sortedIds() {
+		sub, ok := m.stored(id)
+		if !ok || !old.follows(&sub, m.history[id], today) {
+			continue
+		}
+
+		before := m.snapshot(id)
+		m.ensurePriceHistory(id)
+
+		// changes scheduled after new price would override it
+		if policy == PriceNextCycle {
+			effectiveFrom, err := nextBillingDate(&sub, today)
+			if err != nil {
+				return 0, err
+			}
+			m.dropPriceChanges(id, effectiveFrom)
+
+			var replaced *PriceChange
+			if i := slices.IndexFunc(m.history[id], func(c PriceChange) bool { return c.effectiveFrom.Equal(effectiveFrom) }); i >= 0 {
+				replaced = &m.history[id][i]
+			}
+
+			change := newPriceChange(effectiveFrom, price.Amount, price.Currency)
+			m.setPriceChange(id, change)
+			m.writeAudit(AuditSchedulePrice, id, map[string]FieldChange{"price_change": valueChange(replaced, &change)})
+		} else {
+			m.dropPriceChanges(id, today)
+
+			sub.Price, sub.Currency = price.Amount, price.Currency
+			sub.Version++
+			m.subs[id] = sub
+
+			m.recordPrice(id, today)
+			m.audit(AuditUpdate, id, before)
+		}
+		changed++
+	}
+
+	return changed, nil
+}
+
+// dropPriceChanges deletes price changes effective after day or after subscription start when it starts later
+// the same way as SubscriptionModel does, caller must hold the lock
+func (m *MemorySubscriptionModel) dropPriceChanges(id int, day time.Time) {
+	sub := m.subs[id]
+	if start, err := ParseDate(sub.StartDate, false); err == nil && start.After(day) {
+		day = start
+	}
+
+	var kept []PriceChange
+	for _, c := range m.history[id] {
+		if !c.effectiveFrom.After(day) {
+			kept = append(kept, c)
+			continue
+		}
+		m.writeAudit(AuditCancelPrice, id, map[string]FieldChange{"price_change": valueChange(&c, nil)})
+	}
+	m.history[id] = kept
+}
+
+func (m *MemoryPlanModel) Delete(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.plans[id]; !ok {
+		return ErrRecordNotFound
+	}
+	for _, sub := range m.subs {
+		if sub.PlanId == id {
+			return ErrPlanInUse
+		}
+	}
+
+	delete(m.plans, id)
+
+	return nil
+}
+
+// MemoryIdempotencyModel is IdempotencyRepository which keeps records in memory
+type MemoryIdempotencyModel struct {
+	mu      sync.Mutex
+	records map[string]IdempotencyRecord
+}
+
+func NewMemoryIdempotencyModel() *MemoryIdempotencyModel {
+	return &MemoryIdempotencyModel{
+		records: make(map[string]IdempotencyRecord),
+	}
+}
+
+func (m *MemoryIdempotencyModel) Reserve(key, requestHash string, ttl time.Duration) (*IdempotencyRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if rec, ok := m.records[key]; ok && rec.ExpiresAt.After(time.Now()) {
+		return &rec, nil
+	}
+
+	m.records[key] = IdempotencyRecord{
+		Key:         key,
+		RequestHash: requestHash,
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+
+	return nil, nil
+}
+
+func (m *MemoryIdempotencyModel) Complete(key string, statusCode int, headers map[string]string, body []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if rec, ok := m.records[key]; ok {
+		rec.StatusCode = statusCode
+		rec.Headers = headers
+		rec.Body = body
+		m.records[key] = rec
+	}
+
+	return nil
+}
+
+func (m *MemoryIdempotencyModel) Release(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if rec, ok := m.records[key]; ok && rec.StatusCode == 0 {
+		delete(m.records, key)
+	}
+
+	return nil
+}
+
+func (m *MemoryIdempotencyModel) DeleteExpired() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for key, rec := range m.records {
+		if rec.ExpiresAt.Before(now) {
+			delete(m.records, key)
+		}
+	}
+
+	return nil
+}
+
+func (m *MemorySubscriptionModel) GetSpend(startPeriod, endPeriod time.Time, filter *Filter, opts SpendOptions) (*SpendReport, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	type group struct {
+		row  SpendRow
+		subs map[int]bool
+	}
+	groups := make(map[SpendRow]*group)
+	var order []SpendRow
+
+	for _, id := range m.sortedIds() {
+		sub := m.subs[id]
+
+		ok, err := matchFilter(&sub, filter)
+		if err != nil {
+			slog.Error("ERROR in MemorySubscription GetSpend", "error", err)
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		startSub, endSub, err := ParseDates(sub.StartDate, sub.EndDate)
+		if err != nil {
+			slog.Error("ERROR in MemorySubscription GetSpend", "error", err)
+			return nil, err
+		}
+
+		end := endPeriod
+		if endSub := sub.billedEnd(endSub); endSub != nil {
+			end = *endSub
+		}
+
+		sub.Pauses = m.pauses[id]
+		for _, share := range sub.breakdown(opts.Proration, startSub, end, startPeriod, endPeriod) {
+			price := priceAt(&sub, m.history[id], share.Day)
+
+			key := SpendRow{Currency: price.Currency}
+			for _, g := range opts.GroupBy {
+				switch g {
+				case "user_id":
+					key.UserId = sub.UserId
+				case "service_name":
+					key.ServiceName = sub.ServiceName
+				case "month":
+					key.month = share.Month
+					key.Month = share.Month.Format(monthLayout)
+				}
+			}
+
+			grp, ok := groups[key]
+			if !ok {
+				grp = &group{row: key, subs: make(map[int]bool)}
+				groups[key] = grp
+				order = append(order, key)
+			}
+			grp.subs[sub.Id] = true
+			grp.row.Total.Amount += BilledAmount(price.Amount, share.Periods, opts.Rounding)
+		}
+	}
+
+	report := newSpendReport(startPeriod, endPeriod, opts)
+
+	for _, key := range order {
+		grp := groups[key]
+		grp.row.Subscriptions = len(grp.subs)
+		grp.row.Total.Currency = grp.row.Currency
+		report.Data = append(report.Data, grp.row)
+	}
+
+	slices.SortStableFunc(report.Data, func(a, b SpendRow) int {
+		return compareSpendRows(opts.Sort, &a, &b)
+	})
+
+	if opts.Limit > 0 && len(report.Data) > opts.Limit {
+		report.Data = report.Data[:opts.Limit]
+	}
+
+	return report, nil
+}
+
+// MemoryExchangeRateModel is ExchangeRateRepository which keeps rates in memory
+type MemoryExchangeRateModel struct {
+	mu    sync.RWMutex
+	rates map[string]map[time.Time]float64
+}
+
+func NewMemoryExchangeRateModel() *MemoryExchangeRateModel {
+	return &MemoryExchangeRateModel{
+		rates: make(map[string]map[time.Time]float64),
+	}
+}
+
+func (m *MemoryExchangeRateModel) List(currency string) ([]ExchangeRate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rates := []ExchangeRate{}
+	for cur, byDay := range m.rates {
+		if currency != "" && cur != currency {
+			continue
+		}
+		for day, rate := range byDay {
+			rates = append(rates, ExchangeRate{Currency: cur, ValidFrom: day.Format(dayLayout), Rate: rate})
+		}
+	}
+
+	slices.SortFunc(rates, func(a, b ExchangeRate) int {
+		if c := cmp.Compare(a.Currency, b.Currency); c != 0 {
+			return c
+		}
+		da, _ := ParseDate(a.ValidFrom, false)
+		db, _ := ParseDate(b.ValidFrom, false)
+		return da.Compare(db)
+	})
+
+	return rates, nil
+}
+
+func (m *MemoryExchangeRateModel) Set(rate *ExchangeRate) error {
+	validFrom, err := ParseDate(rate.ValidFrom, false)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.rates[rate.Currency] == nil {
+		m.rates[rate.Currency] = make(map[time.Time]float64)
+	}
+	m.rates[rate.Currency][validFrom] = rate.Rate
+	rate.ValidFrom = validFrom.Format(dayLayout)
+
+	return nil
+}
+
+func (m *MemoryExchangeRateModel) Delete(currency string, validFrom time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.rates[currency][validFrom]; !ok {
+		return ErrRecordNotFound
+	}
+	delete(m.rates[currency], validFrom)
+
+	return nil
+}
@@ -0,0 +1,400 @@
+package database
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// seedMemory returns memory model with subscriptions:
+// 1 Yandex Plus 400.00 of user 1 from 07-2025,
+// 2 Netflix 100.00 of user 2 from 01-2025 till 05-2025,
+// 3 Spotify 250.00 of user 1 from 03-2025 till 12-2025
+func seedMemory(t *testing.T) *MemorySubscriptionModel {
+	t.Helper()
+
+	m := NewMemorySubscriptionModel()
+	subs := []Subscription{
+		{ServiceName: "Yandex Plus", Price: 40000, UserId: 1, StartDate: "07-2025"},
+		{ServiceName: "Netflix", Price: 10000, UserId: 2, StartDate: "01-2025", EndDate: "05-2025"},
+		{ServiceName: "Spotify", Price: 25000, UserId: 1, StartDate: "03-2025", EndDate: "12-2025"},
+	}
+	for i := range subs {
+		if err := m.Insert(&subs[i]); err != nil {
+			t.Fatalf("Insert(%s): %v", subs[i].ServiceName, err)
+		}
+	}
+
+	return m
+}
+
+func pageIds(page *SubscriptionPage) []int {
+	ids := []int{}
+	for _, sub := range page.Data {
+		ids = append(ids, sub.Id)
+	}
+	return ids
+}
+
+func TestMemoryInsert(t *testing.T) {
+	tests := []struct {
+		name    string
+		sub     Subscription
+		wantErr bool
+	}{
+		{
+			name: "open subscription",
+			sub:  Subscription{ServiceName: "Kinopoisk", Price: 29900, UserId: 3, StartDate: "01-2026"},
+		},
+		{
+			name: "end date given by day",
+			sub:  Subscription{ServiceName: "Kinopoisk", Price: 29900, UserId: 3, StartDate: "01-2026", EndDate: "15-03-2026"},
+		},
+		{
+			name:    "invalid start date",
+			sub:     Subscription{ServiceName: "Kinopoisk", Price: 29900, UserId: 3, StartDate: "2026-01"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := seedMemory(t)
+
+			sub := tt.sub
+			err := m.Insert(&sub)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Insert() = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Insert() = %v", err)
+			}
+			if sub.Id != 4 || sub.Version != 1 {
+				t.Errorf("Insert() id, version = %d, %d, want 4, 1", sub.Id, sub.Version)
+			}
+			if sub.ServiceId == 0 {
+				t.Errorf("Insert() didn't resolve service of %q", sub.ServiceName)
+			}
+
+			got, err := m.Get(sub.Id)
+			if err != nil || got == nil {
+				t.Fatalf("Get(%d) = %v, %v", sub.Id, got, err)
+			}
+			if got.ServiceName != tt.sub.ServiceName || got.Price != tt.sub.Price || got.UserId != tt.sub.UserId {
+				t.Errorf("Get(%d) = %+v, want %+v", sub.Id, got, tt.sub)
+			}
+		})
+	}
+}
+
+func TestMemoryGet(t *testing.T) {
+	tests := []struct {
+		name        string
+		id          int
+		wantService string
+	}{
+		{name: "existing", id: 2, wantService: "Netflix"},
+		{name: "missing", id: 42},
+		{name: "zero id", id: 0},
+	}
+
+	m := seedMemory(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.Get(tt.id)
+			if err != nil {
+				t.Fatalf("Get(%d) = %v", tt.id, err)
+			}
+			if tt.wantService == "" {
+				if got != nil {
+					t.Errorf("Get(%d) = %+v, want nil", tt.id, got)
+				}
+				return
+			}
+			if got == nil || got.ServiceName != tt.wantService {
+				t.Errorf("Get(%d) = %+v, want %s", tt.id, got, tt.wantService)
+			}
+		})
+	}
+}
+
+func TestMemoryUpdate(t *testing.T) {
+	tests := []struct {
+		name        string
+		id          int
+		version     int
+		wantErr     error
+		wantVersion int
+	}{
+		{name: "matching version", id: 1, version: 1, wantVersion: 2},
+		{name: "any version", id: 1, version: 0, wantVersion: 2},
+		{name: "stale version", id: 1, version: 5, wantErr: ErrEditConflict},
+		{name: "missing", id: 42, version: 1, wantErr: ErrRecordNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := seedMemory(t)
+
+			sub := Subscription{Id: tt.id, Version: tt.version, ServiceName: "Yandex Plus", Price: 45000, UserId: 1, StartDate: "07-2025"}
+			err := m.Update(&sub)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Update() = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			got, _ := m.Get(tt.id)
+			if got.Version != tt.wantVersion || got.Price != 45000 {
+				t.Errorf("Get(%d) version, price = %d, %d, want %d, 45000", tt.id, got.Version, got.Price, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestMemoryDelete(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      int
+		version int
+		wantErr error
+	}{
+		{name: "matching version", id: 2, version: 1},
+		{name: "any version", id: 2, version: 0},
+		{name: "stale version", id: 2, version: 3, wantErr: ErrEditConflict},
+		{name: "missing", id: 42, version: 0, wantErr: ErrRecordNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := seedMemory(t)
+
+			err := m.Delete(tt.id, tt.version)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Delete() = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if got, _ := m.Get(tt.id); got != nil {
+				t.Errorf("Get(%d) after delete = %+v, want nil", tt.id, got)
+			}
+
+			page, err := m.GetList(nil, ListOptions{})
+			if err != nil {
+				t.Fatalf("GetList() = %v", err)
+			}
+			if ids := pageIds(page); slices.Contains(ids, tt.id) {
+				t.Errorf("GetList() = %v, deleted %d is listed", ids, tt.id)
+			}
+
+			page, err = m.GetList(nil, ListOptions{IncludeDeleted: true})
+			if err != nil {
+				t.Fatalf("GetList(IncludeDeleted) = %v", err)
+			}
+			if ids := pageIds(page); !slices.Contains(ids, tt.id) {
+				t.Errorf("GetList(IncludeDeleted) = %v, deleted %d isn't listed", ids, tt.id)
+			}
+
+			if err := m.Delete(tt.id, 0); !errors.Is(err, ErrRecordNotFound) {
+				t.Errorf("second Delete() = %v, want %v", err, ErrRecordNotFound)
+			}
+		})
+	}
+}
+
+func TestMemoryGetList(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  *Filter
+		opts    ListOptions
+		want    []int
+		wantErr error
+	}{
+		{name: "no filter", want: []int{1, 2, 3}},
+		{name: "user", filter: NewFilter().Eq("user_id", 1), want: []int{1, 3}},
+		{name: "users", filter: NewFilter().In("user_id", 2, 3), want: []int{2}},
+		{name: "price range", filter: NewFilter().Gte("price", 10000).Lt("price", 40000), want: []int{2, 3}},
+		{name: "search", filter: NewFilter().Contains("service_name", "FLIX"), want: []int{2}},
+		{name: "open", filter: NewFilter().IsNull("end_date"), want: []int{1}},
+		{name: "started since", filter: NewFilter().Gte("start_date", day("01-03-2025")), want: []int{1, 3}},
+		{
+			name:   "or",
+			filter: NewFilter().Or(NewFilter().Eq("user_id", 2), NewFilter().Eq("service_name", "Spotify")),
+			want:   []int{2, 3},
+		},
+		{name: "sort by price", opts: ListOptions{Sort: []SortField{{Field: "price"}}}, want: []int{2, 3, 1}},
+		{name: "sort by price desc", opts: ListOptions{Sort: []SortField{{Field: "price", Desc: true}}}, want: []int{1, 3, 2}},
+		{name: "open end goes last", opts: ListOptions{Sort: []SortField{{Field: "end_date"}}}, want: []int{2, 3, 1}},
+		{name: "limit", opts: ListOptions{Limit: 2}, want: []int{1, 2}},
+		{name: "offset", opts: ListOptions{Offset: 1}, want: []int{2, 3}},
+		{name: "unknown filter field", filter: NewFilter().Eq("currency", "RUB"), wantErr: ErrInvalidFilter},
+		{name: "unknown sort field", opts: ListOptions{Sort: []SortField{{Field: "currency"}}}, wantErr: ErrInvalidSort},
+		{name: "invalid cursor", opts: ListOptions{Cursor: "garbage"}, wantErr: ErrInvalidCursor},
+	}
+
+	m := seedMemory(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := m.GetList(tt.filter, tt.opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetList() = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if got := pageIds(page); !slices.Equal(got, tt.want) {
+				t.Errorf("GetList() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryGetListCursor(t *testing.T) {
+	tests := []struct {
+		name  string
+		sort  []SortField
+		limit int
+		want  [][]int
+	}{
+		{name: "by id", limit: 2, want: [][]int{{1, 2}, {3}}},
+		{name: "by price desc", sort: []SortField{{Field: "price", Desc: true}}, limit: 1, want: [][]int{{1}, {3}, {2}}},
+		{name: "by user and start", sort: []SortField{{Field: "user_id"}, {Field: "start_date"}}, limit: 2, want: [][]int{{3, 1}, {2}}},
+		{name: "single page", limit: 3, want: [][]int{{1, 2, 3}}},
+	}
+
+	m := seedMemory(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := ListOptions{Sort: tt.sort, Limit: tt.limit, WithTotal: true}
+
+			var got [][]int
+			for {
+				page, err := m.GetList(nil, opts)
+				if err != nil {
+					t.Fatalf("GetList(cursor %q) = %v", opts.Cursor, err)
+				}
+				if page.Total == nil || *page.Total != 3 {
+					t.Errorf("GetList() total = %v, want 3", page.Total)
+				}
+				got = append(got, pageIds(page))
+
+				if page.NextCursor == "" {
+					break
+				}
+				if len(got) > len(tt.want) {
+					t.Fatalf("GetList() returned more than %d pages", len(tt.want))
+				}
+				opts.Cursor = page.NextCursor
+			}
+
+			if !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("pages = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryGetListCursorOfOtherSort(t *testing.T) {
+	m := seedMemory(t)
+
+	page, err := m.GetList(nil, ListOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("GetList() = %v", err)
+	}
+
+	_, err = m.GetList(nil, ListOptions{Limit: 1, Cursor: page.NextCursor, Sort: []SortField{{Field: "price"}}})
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("GetList() with cursor of other sort = %v, want %v", err, ErrInvalidCursor)
+	}
+}
+
+func TestMemoryGetPrice(t *testing.T) {
+	tests := []struct {
+		name         string
+		start, end   string
+		filter       *Filter
+		opts         PriceOptions
+		wantTotal    int64
+		wantIds      []int
+		wantMonths   int
+		wantTimeline map[string]int64
+	}{
+		{
+			// Yandex Plus 400 + Spotify 250 in July and August
+			name:  "two months",
+			start: "01-07-2025", end: "31-08-2025",
+			wantTotal: 130000, wantIds: []int{1, 3}, wantMonths: 2,
+			wantTimeline: map[string]int64{"07-2025": 65000, "08-2025": 65000},
+		},
+		{
+			// Netflix is billed for May, its end month
+			name:  "end month is billed",
+			start: "01-05-2025", end: "31-05-2025",
+			wantTotal: 35000, wantIds: []int{2, 3}, wantMonths: 1,
+		},
+		{
+			name:  "filtered by user",
+			start: "01-01-2025", end: "31-12-2025",
+			filter:    NewFilter().Eq("user_id", 2),
+			wantTotal: 50000, wantIds: []int{2}, wantMonths: 12,
+		},
+		{
+			// 16 of 31 days of Spotify and Yandex Plus in July
+			name:  "daily proration",
+			start: "16-07-2025", end: "31-07-2025",
+			opts:      PriceOptions{Proration: ProrationDaily},
+			wantTotal: 33548, wantIds: []int{1, 3}, wantMonths: 1,
+		},
+		{
+			name:  "nothing active",
+			start: "01-01-2024", end: "31-12-2024",
+			wantTotal: 0, wantIds: []int{}, wantMonths: 12,
+		},
+	}
+
+	m := seedMemory(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := m.GetPrice(day(tt.start), day(tt.end), tt.filter, tt.opts)
+			if err != nil {
+				t.Fatalf("GetPrice() = %v", err)
+			}
+
+			if report.Total.Amount != tt.wantTotal {
+				t.Errorf("GetPrice() total = %d, want %d", report.Total.Amount, tt.wantTotal)
+			}
+
+			ids := []int{}
+			var sum int64
+			for _, p := range report.Subscriptions {
+				ids = append(ids, p.Id)
+				sum += p.Subtotal.Amount
+			}
+			if !slices.Equal(ids, tt.wantIds) {
+				t.Errorf("GetPrice() subscriptions = %v, want %v", ids, tt.wantIds)
+			}
+			if sum != report.Total.Amount {
+				t.Errorf("GetPrice() subtotals add up to %d, total is %d", sum, report.Total.Amount)
+			}
+
+			if len(report.Timeline) != tt.wantMonths {
+				t.Errorf("GetPrice() timeline has %d months, want %d", len(report.Timeline), tt.wantMonths)
+			}
+			for _, month := range report.Timeline {
+				if want, ok := tt.wantTimeline[month.Month]; ok && month.Total.Amount != want {
+					t.Errorf("GetPrice() %s total = %d, want %d", month.Month, month.Total.Amount, want)
+				}
+			}
+		})
+	}
+}
@@ -1,13 +1,103 @@
 package database
 
-import "github.com/jackc/pgx/v5"
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type SubscriptionRepository interface {
+	Insert(sub *Subscription) error
+	InsertMany(subs []*Subscription) error
+	Get(id int) (*Subscription, error)
+	GetAt(id int, at time.Time) (*Subscription, error)
+	Update(sub *Subscription) error
+	UpdateFields(sub *Subscription, fields []string) error
+	Delete(id int, version int) error
+	GetList(filter *Filter, opts ListOptions) (*SubscriptionPage, error)
+	Batch(ctx context.Context, ops []BatchOperation, atomic bool) ([]BatchResult, error)
+	GetPrice(startPeriod, endPeriod time.Time, filter *Filter, opts PriceOptions) (*PriceReport, error)
+	GetSpend(startPeriod, endPeriod time.Time, filter *Filter, opts SpendOptions) (*SpendReport, error)
+	PriceHistory(id int) ([]PriceChange, error)
+	SchedulePriceChange(id int, effectiveFrom time.Time, price Money) (*PriceChange, error)
+	CancelPriceChange(id int, effectiveFrom time.Time) error
+	ApplyPriceChanges(day time.Time) (int, error)
+	Pause(id int, from time.Time, until *time.Time) (*Subscription, error)
+	Resume(id int, at time.Time) (*Subscription, error)
+	Cancel(id int, cancellation Cancellation) (*Subscription, error)
+	Reactivate(id int) (*Subscription, error)
+	GetChurn(startPeriod, endPeriod time.Time, filter *Filter, groupBy []string) (*ChurnReport, error)
+	Restore(id int) (*Subscription, error)
+	Purge(id int) error
+	PurgeDeleted(before time.Time) (int, error)
+	History(id int, opts ListOptions) (*AuditPage, error)
+	// As returns repository which records changes as made by actor
+	As(actor Actor) SubscriptionRepository
+}
+
+type ServiceRepository interface {
+	List(category string) ([]Service, error)
+	Get(id int) (*Service, error)
+	Lookup(name string) (*Service, error)
+	Insert(s *Service) error
+	Update(s *Service) error
+	Delete(id int) error
+	Merge(id int, merged []int) (*Service, error)
+	// As returns repository which records changes of subscriptions made by catalog changes as made by actor
+	As(actor Actor) ServiceRepository
+}
+
+type PlanRepository interface {
+	List(serviceId int) ([]Plan, error)
+	Get(id int) (*Plan, error)
+	Insert(p *Plan) error
+	Update(p *Plan, policy PricePolicy) (int, error)
+	Delete(id int) error
+	// As returns repository which records changes of subscriptions made by plan price changes as made by actor
+	As(actor Actor) PlanRepository
+}
+
+type IdempotencyRepository interface {
+	Reserve(key, requestHash string, ttl time.Duration) (*IdempotencyRecord, error)
+	Complete(key string, statusCode int, headers map[string]string, body []byte) error
+	Release(key string) error
+	DeleteExpired() error
+}
+
+type ExchangeRateRepository interface {
+	List(currency string) ([]ExchangeRate, error)
+	Set(rate *ExchangeRate) error
+	Delete(currency string, validFrom time.Time) error
+}
 
 type Models struct {
-	Subscriptions SubscriptionModel
+	Subscriptions SubscriptionRepository
+	Services      ServiceRepository
+	Plans         PlanRepository
+	Idempotency   IdempotencyRepository
+	ExchangeRates ExchangeRateRepository
+}
+
+func NewModels(db *pgxpool.Pool) Models {
+	return Models{
+		Subscriptions: &SubscriptionModel{DB: db},
+		Services:      &ServiceModel{DB: db},
+		Plans:         &PlanModel{DB: db},
+		Idempotency:   &IdempotencyModel{DB: db},
+		ExchangeRates: &ExchangeRateModel{DB: db},
+	}
 }
 
-func NewModels(db *pgx.Conn) Models {
+// NewMemoryModels returns models which keep all data in process memory
+func NewMemoryModels() Models {
+	subscriptions := NewMemorySubscriptionModel()
+
 	return Models{
-		Subscriptions: SubscriptionModel{DB: db},
+		Subscriptions: subscriptions,
+		Services:      &MemoryServiceModel{memorySubscriptions: subscriptions.memorySubscriptions},
+		Plans:         &MemoryPlanModel{memorySubscriptions: subscriptions.memorySubscriptions},
+		Idempotency:   NewMemoryIdempotencyModel(),
+		ExchangeRates: NewMemoryExchangeRateModel(),
 	}
 }
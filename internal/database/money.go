@@ -0,0 +1,225 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidAmount   = errors.New("invalid amount")
+	ErrInvalidRounding = errors.New("invalid rounding")
+)
+
+// currencyExponents lists ISO 4217 currencies whose minor unit is not 1/100 of major unit
+var currencyExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// CurrencyExponent returns number of decimal places of currency minor unit, empty currency is BaseCurrency
+func CurrencyExponent(currency string) int {
+	if exp, ok := currencyExponents[currency]; ok {
+		return exp
+	}
+	return 2
+}
+
+// Money is exact amount in minor units of currency, e.g. kopecks for RUB.
+// It is written to JSON as {"amount": "399.99", "currency": "RUB"}.
+type Money struct {
+	Amount   int64  `json:"amount" swaggertype:"string" example:"399.99"`
+	Currency string `json:"currency" example:"RUB"`
+}
+
+func (m Money) String() string {
+	return FormatAmount(m.Amount, m.Currency)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.String(), m.Currency})
+}
+
+// UnmarshalJSON reads amount written as decimal string or number in units of currency, empty currency is BaseCurrency
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Amount   json.RawMessage `json:"amount"`
+		Currency string          `json:"currency"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	m.Currency = currencyName(strings.ToUpper(aux.Currency))
+	if len(aux.Amount) == 0 {
+		return fmt.Errorf("%w: amount is required", ErrInvalidAmount)
+	}
+
+	amount, err := parseJSONAmount(aux.Amount, m.Currency)
+	if err != nil {
+		return err
+	}
+	m.Amount = amount
+
+	return nil
+}
+
+// ParseAmount parses decimal string like "399.99" to minor units of currency.
+// Amount with more decimal places than currency has is rejected instead of being rounded.
+func ParseAmount(s, currency string) (int64, error) {
+	exp := CurrencyExponent(currency)
+
+	digits := strings.TrimPrefix(s, "-")
+	intPart, fracPart, hasDot := strings.Cut(digits, ".")
+	if intPart == "" || (hasDot && fracPart == "") || strings.ContainsAny(intPart+fracPart, "+-") {
+		return 0, fmt.Errorf("%w %q, decimal number expected", ErrInvalidAmount, s)
+	}
+	if len(fracPart) > exp {
+		fracPart = strings.TrimRight(fracPart, "0")
+		if len(fracPart) > exp {
+			return 0, fmt.Errorf("%w %q, %s has %d decimal places", ErrInvalidAmount, s, currencyName(currency), exp)
+		}
+	}
+
+	amount, err := strconv.ParseInt(intPart+fracPart+strings.Repeat("0", exp-len(fracPart)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w %q, decimal number expected", ErrInvalidAmount, s)
+	}
+	if len(s) > len(digits) {
+		amount = -amount
+	}
+
+	return amount, nil
+}
+
+// ParsePrice is ParseAmount which rejects zero and negative amounts
+func ParsePrice(s, currency string) (int64, error) {
+	amount, err := ParseAmount(s, currency)
+	if err == nil && amount <= 0 {
+		return 0, fmt.Errorf("%w %q, price must be positive", ErrInvalidAmount, s)
+	}
+	return amount, err
+}
+
+// FormatAmount formats minor units of currency as decimal string with all decimal places of currency
+func FormatAmount(amount int64, currency string) string {
+	exp := CurrencyExponent(currency)
+	if exp == 0 {
+		return strconv.FormatInt(amount, 10)
+	}
+
+	sign := ""
+	digits := strconv.FormatUint(uint64(amount), 10)
+	if amount < 0 {
+		sign = "-"
+		digits = strconv.FormatUint(uint64(-amount), 10)
+	}
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+// parseJSONAmount accepts amount written either as decimal string or as JSON number
+func parseJSONAmount(data json.RawMessage, currency string) (int64, error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n json.Number
+		if err := json.Unmarshal(data, &n); err != nil {
+			return 0, fmt.Errorf("%w %s, decimal string expected", ErrInvalidAmount, data)
+		}
+		s = n.String()
+	}
+
+	return ParseAmount(s, currency)
+}
+
+func currencyName(currency string) string {
+	if currency == "" {
+		return BaseCurrency
+	}
+	return currency
+}
+
+// Rounding is rounding mode applied when exact amount is converted to minor units
+type Rounding string
+
+const (
+	// RoundHalfUp rounds half away from zero
+	RoundHalfUp Rounding = "half_up"
+	// RoundHalfEven rounds half to even minor unit, also known as banker's rounding
+	RoundHalfEven Rounding = "half_even"
+	// RoundDown truncates towards zero
+	RoundDown Rounding = "down"
+	// RoundUp rounds away from zero
+	RoundUp Rounding = "up"
+)
+
+// ParseRounding returns rounding mode by name, empty name means RoundHalfUp
+func ParseRounding(s string) (Rounding, error) {
+	switch r := Rounding(s); r {
+	case "":
+		return RoundHalfUp, nil
+	case RoundHalfUp, RoundHalfEven, RoundDown, RoundUp:
+		return r, nil
+	}
+
+	return "", fmt.Errorf("%w %q, expected half_up, half_even, down or up", ErrInvalidRounding, s)
+}
+
+// round rounds exact amount to integer
+func (r Rounding) round(x *big.Rat) int64 {
+	q, rem := new(big.Int).QuoRem(x.Num(), x.Denom(), new(big.Int))
+	if rem.Sign() == 0 {
+		return q.Int64()
+	}
+
+	away := false
+	switch r {
+	case RoundUp:
+		away = true
+	case RoundDown:
+		away = false
+	default:
+		half := new(big.Int).Abs(rem)
+		half.Lsh(half, 1)
+		switch c := half.Cmp(x.Denom()); {
+		case c > 0:
+			away = true
+		case c == 0:
+			away = r != RoundHalfEven || q.Bit(0) == 1
+		}
+	}
+
+	if away {
+		q.Add(q, big.NewInt(int64(x.Sign())))
+	}
+
+	return q.Int64()
+}
+
+// sql returns SQL expression rounding numeric expression the same way as round
+func (r Rounding) sql(expr string) string {
+	switch r {
+	case RoundDown:
+		return fmt.Sprintf("TRUNC(%s)", expr)
+	case RoundUp:
+		return fmt.Sprintf("(SIGN(%[1]s) * CEIL(ABS(%[1]s)))", expr)
+	case RoundHalfEven:
+		return fmt.Sprintf("(CASE WHEN ABS(%[1]s - TRUNC(%[1]s)) = 0.5 THEN 2 * ROUND(%[1]s / 2) ELSE ROUND(%[1]s) END)", expr)
+	}
+	return fmt.Sprintf("ROUND(%s)", expr)
+}
+
+// BilledAmount returns price of billed periods in minor units, exact product is rounded once
+func BilledAmount(price int64, periods *big.Rat, rounding Rounding) int64 {
+	return rounding.round(new(big.Rat).Mul(big.NewRat(price, 1), periods))
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestBilledAmount(t *testing.T) {
+	tests := []struct {
+		name     string
+		price    int64
+		periods  *big.Rat
+		rounding Rounding
+		want     int64
+	}{
+		{"whole month", 39999, big.NewRat(1, 1), RoundHalfUp, 39999},
+		{"large amount 17 of 31 days", 100000000, big.NewRat(17, 31), RoundHalfUp, 54838710},
+		{"large amount 17 of 31 days rounded down", 100000000, big.NewRat(17, 31), RoundDown, 54838709},
+		{"large amount 1 of 31 days", 100000000, big.NewRat(1, 31), RoundHalfUp, 3225806},
+		{"large amount 28 of 29 days", 999999999999, big.NewRat(28, 29), RoundHalfUp, 965517241378},
+		{"half is rounded up", 15, big.NewRat(1, 30), RoundHalfUp, 1},
+		{"half is rounded to even", 15, big.NewRat(1, 30), RoundHalfEven, 0},
+		{"half is rounded up by ceil", 15, big.NewRat(1, 30), RoundUp, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BilledAmount(tt.price, tt.periods, tt.rounding); got != tt.want {
+				t.Errorf("BilledAmount(%d, %s, %s) = %d, want %d", tt.price, tt.periods.RatString(), tt.rounding, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPriceReportDailyLargeAmount(t *testing.T) {
+	start, end := day("01-01-2026"), day("28-02-2026")
+	r := newPriceReport(start, end, PriceOptions{Proration: ProrationDaily})
+
+	sub := &Subscription{Id: 1, ServiceName: "Big", UserId: 1, Price: 100000000, Currency: "RUB"}
+	subEnd := day("10-02-2026")
+	if err := r.add(sub, day("15-01-2026"), &subEnd, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// 17 of 31 days in January and 10 of 28 days in February
+	if got, want := r.Timeline[0].Total.Amount, int64(54838710); got != want {
+		t.Errorf("January total = %d, want %d", got, want)
+	}
+	if got, want := r.Timeline[1].Total.Amount, int64(35714286); got != want {
+		t.Errorf("February total = %d, want %d", got, want)
+	}
+	if got, want := r.Total.Amount, int64(54838710+35714286); got != want {
+		t.Errorf("total = %d, want %d", got, want)
+	}
+	if got, want := r.Subscriptions[0].Periods, 0.9055; got != want {
+		t.Errorf("periods = %g, want %g", got, want)
+	}
+}
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		s        string
+		currency string
+		want     int64
+		wantErr  bool
+	}{
+		{"399.99", "RUB", 39999, false},
+		{"1000000", "RUB", 100000000, false},
+		{"500", "JPY", 500, false},
+		{"0", "RUB", 0, true},
+		{"0.00", "RUB", 0, true},
+		{"-5", "RUB", 0, true},
+		{"1.001", "RUB", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s+"_"+tt.currency, func(t *testing.T) {
+			got, err := ParsePrice(tt.s, tt.currency)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidAmount) {
+					t.Errorf("ParsePrice(%q) error = %v, want ErrInvalidAmount", tt.s, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParsePrice(%q) = %d, %v, want %d", tt.s, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubscriptionRejectsNonPositivePrice(t *testing.T) {
+	for _, body := range []string{`{"price":"-5"}`, `{"price":0}`, `{"price":"0.00"}`} {
+		var sub Subscription
+		if err := sub.UnmarshalJSON([]byte(body)); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("UnmarshalJSON(%s) error = %v, want ErrInvalidAmount", body, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 1000
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListOptions controls ordering and pagination of subscription list.
+// Cursor and Offset may be combined, offset is applied after cursor position.
+// Cursor is valid only with the same Sort it was issued for.
+type ListOptions struct {
+	Limit     int
+	Offset    int
+	Cursor    string
+	WithTotal bool
+	Sort      []SortField
+	// IncludeDeleted lists deleted subscriptions as well
+	IncludeDeleted bool
+	// AsOf lists subscriptions as they were at given time, they are reconstructed from audit log
+	AsOf *time.Time
+}
+
+type SubscriptionPage struct {
+	Data       []*Subscription `json:"data"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	Total      *int            `json:"total,omitempty"`
+}
+
+// cursor is position in list, encoded as opaque string for clients.
+// Values holds sort field values of last row on page.
+type cursor struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+}
+
+func encodeCursor(sorts []SortField, sub *Subscription) (string, error) {
+	c := cursor{Sort: sortKey(sorts)}
+	for _, s := range sorts {
+		v, err := sortValue(sub, s.Field)
+		if err != nil {
+			return "", err
+		}
+		c.Values = append(c.Values, formatSortValue(v))
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor returns sort values stored in cursor
+func decodeCursor(s string, sorts []SortField) ([]any, error) {
+	var c cursor
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != sortKey(sorts) || len(c.Values) != len(sorts) {
+		return nil, ErrInvalidCursor
+	}
+
+	values := make([]any, len(sorts))
+	for i, s := range sorts {
+		values[i], err = parseSortValue(s.Field, c.Values[i])
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+
+	return values, nil
+}
+
+func (o ListOptions) limit() int {
+	if o.Limit <= 0 {
+		return DefaultPageLimit
+	}
+	if o.Limit > MaxPageLimit {
+		return MaxPageLimit
+	}
+	return o.Limit
+}
+
+// newPage trims subscriptions fetched with one extra row to limit and sets next cursor when more rows exist
+func newPage(subs []*Subscription, limit int, sorts []SortField) (*SubscriptionPage, error) {
+	page := &SubscriptionPage{Data: subs}
+
+	if len(subs) > limit {
+		page.Data = subs[:limit]
+
+		next, err := encodeCursor(sorts, page.Data[limit-1])
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = next
+	}
+
+	return page, nil
+}
@@ -0,0 +1,309 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/big"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrPauseConflict is returned when new pause overlaps existing one
+	ErrPauseConflict = errors.New("subscription is already paused in this period")
+	// ErrNotPaused is returned when resumed subscription is not paused
+	ErrNotPaused = errors.New("subscription is not paused")
+	// ErrPauseOutOfRange is returned when pause doesn't start within subscription dates
+	ErrPauseOutOfRange = errors.New("pause must start within subscription dates")
+)
+
+// SubscriptionStatus is state of subscription at current day, it is derived from dates and pauses and never stored
+type SubscriptionStatus string
+
+const (
+	StatusPending SubscriptionStatus = "pending"
+	StatusActive  SubscriptionStatus = "active"
+	StatusPaused  SubscriptionStatus = "paused"
+	StatusEnded   SubscriptionStatus = "ended"
+	// StatusCancelled is status of subscription which has ended because it was cancelled
+	StatusCancelled SubscriptionStatus = "cancelled"
+)
+
+// Pause is interval subscription isn't billed in, both dates are inclusive and Until is empty until subscription is resumed
+type Pause struct {
+	From  string `json:"from"`
+	Until string `json:"until,omitempty"`
+
+	from  time.Time
+	until *time.Time
+}
+
+func newPause(from time.Time, until *time.Time) Pause {
+	p := Pause{From: from.Format(dayLayout), from: from, until: until}
+	if until != nil {
+		p.Until = until.Format(dayLayout)
+	}
+	return p
+}
+
+// covers reports whether subscription is paused at day
+func (p Pause) covers(day time.Time) bool {
+	return !day.Before(p.from) && (p.until == nil || !day.After(*p.until))
+}
+
+// overlaps reports whether pause has common days with interval, nil until means open interval
+func (p Pause) overlaps(from time.Time, until *time.Time) bool {
+	return (p.until == nil || !from.After(*p.until)) && (until == nil || !p.from.After(*until))
+}
+
+// setStatus fills status of subscription at day
+func (sub *Subscription) setStatus(day time.Time) {
+	start, end, err := ParseDates(sub.StartDate, sub.EndDate)
+	if err != nil {
+		return
+	}
+
+	switch {
+	case start.After(day):
+		sub.Status = StatusPending
+	case end != nil && end.Before(day) && sub.Cancellation != nil:
+		sub.Status = StatusCancelled
+	case end != nil && end.Before(day):
+		sub.Status = StatusEnded
+	default:
+		sub.Status = StatusActive
+		for _, p := range sub.Pauses {
+			if p.covers(day) {
+				sub.Status = StatusPaused
+			}
+		}
+	}
+}
+
+// breakdown is BillingPeriod.Breakdown with paused days excluded. Monthly billing is prorated within active days,
+// so month touched by active days is still billed in full with monthly proration. Charges of other billing periods
+// keep their dates and are skipped when they fall into pause.
+func (sub *Subscription) breakdown(proration Proration, subStart, subEnd, periodStart, periodEnd time.Time) []MonthShare {
+	if len(sub.Pauses) == 0 {
+		return sub.BillingPeriod.Breakdown(proration, subStart, subEnd, periodStart, periodEnd)
+	}
+
+	var shares []MonthShare
+
+	if !sub.BillingPeriod.isMonthly() {
+		for _, share := range sub.BillingPeriod.Breakdown(proration, subStart, subEnd, periodStart, periodEnd) {
+			if !sub.pausedAt(share.Day) {
+				shares = append(shares, share)
+			}
+		}
+		return shares
+	}
+
+	from := truncateDay(subStart)
+	for _, p := range sub.Pauses {
+		if p.from.After(from) {
+			shares = mergeShares(proration, shares, proration.Breakdown(from, minTime(p.from.AddDate(0, 0, -1), subEnd), periodStart, periodEnd))
+		}
+		if p.until == nil {
+			return shares
+		}
+		if next := p.until.AddDate(0, 0, 1); next.After(from) {
+			from = next
+		}
+	}
+
+	return mergeShares(proration, shares, proration.Breakdown(from, subEnd, periodStart, periodEnd))
+}
+
+func (sub *Subscription) pausedAt(day time.Time) bool {
+	for _, p := range sub.Pauses {
+		if p.covers(day) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeShares appends shares of next active interval, month split by pause is billed once
+func mergeShares(proration Proration, shares, next []MonthShare) []MonthShare {
+	for _, share := range next {
+		last := len(shares) - 1
+		if last < 0 || !shares[last].Month.Equal(share.Month) {
+			shares = append(shares, share)
+			continue
+		}
+		if proration == ProrationDaily || proration == Proration30360 {
+			shares[last].Periods = new(big.Rat).Add(shares[last].Periods, share.Periods)
+		}
+	}
+	return shares
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
+// loadPauses returns pauses of subscriptions sorted by start
+func loadPauses(ctx context.Context, q querier, ids []int) (map[int][]Pause, error) {
+	query := "SELECT subscription_id, pause_from, pause_until FROM subscription_pauses WHERE subscription_id = ANY($1) ORDER BY subscription_id, pause_from"
+
+	rows, err := q.Query(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	pauses := make(map[int][]Pause)
+	for rows.Next() {
+		var id int
+		var from time.Time
+		var until *time.Time
+
+		if err := rows.Scan(&id, &from, &until); err != nil {
+			return nil, err
+		}
+		pauses[id] = append(pauses[id], newPause(from, until))
+	}
+
+	return pauses, rows.Err()
+}
+
+// checkPause validates new pause against subscription dates and its other pauses
+func checkPause(sub *Subscription, from time.Time, until *time.Time) error {
+	start, end, err := ParseDates(sub.StartDate, sub.EndDate)
+	if err != nil {
+		return err
+	}
+	if from.Before(start) || (end != nil && from.After(*end)) {
+		return ErrPauseOutOfRange
+	}
+
+	for _, p := range sub.Pauses {
+		if p.overlaps(from, until) {
+			return ErrPauseConflict
+		}
+	}
+
+	return nil
+}
+
+// resumedPause returns pause which is closed by resuming at day, ErrNotPaused when subscription isn't paused at day
+// and has no open pause starting after it
+func resumedPause(sub *Subscription, day time.Time) (Pause, error) {
+	for _, p := range sub.Pauses {
+		if p.covers(day) || (p.until == nil && p.from.After(day)) {
+			return p, nil
+		}
+	}
+	return Pause{}, ErrNotPaused
+}
+
+// Pause suspends subscription from given day until given day or until it is resumed, version of subscription is incremented
+func (m *SubscriptionModel) Pause(id int, from time.Time, until *time.Time) (*Subscription, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.withTx(ctx, func(tx pgx.Tx) error {
+		return changeSubscription(ctx, tx, m.actor, AuditPause, id, func(sub *Subscription) error {
+			if err := checkPause(sub, from, until); err != nil {
+				return err
+			}
+
+			query := "INSERT INTO subscription_pauses (subscription_id, pause_from, pause_until) VALUES ($1, $2, $3)"
+			if _, err := tx.Exec(ctx, query, id, from, until); err != nil {
+				return err
+			}
+
+			_, err := tx.Exec(ctx, "UPDATE subscription SET version = version + 1 WHERE id = $1", id)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, pauseError("Pause", err)
+	}
+
+	return m.Get(id)
+}
+
+// Resume ends pause of subscription on the day before given one, pause which hasn't started yet is deleted
+func (m *SubscriptionModel) Resume(id int, at time.Time) (*Subscription, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.withTx(ctx, func(tx pgx.Tx) error {
+		return changeSubscription(ctx, tx, m.actor, AuditResume, id, func(sub *Subscription) error {
+			p, err := resumedPause(sub, at)
+			if err != nil {
+				return err
+			}
+
+			if at.After(p.from) {
+				_, err = tx.Exec(ctx, "UPDATE subscription_pauses SET pause_until = $3 WHERE subscription_id = $1 AND pause_from = $2", id, p.from, at.AddDate(0, 0, -1))
+			} else {
+				_, err = tx.Exec(ctx, "DELETE FROM subscription_pauses WHERE subscription_id = $1 AND pause_from = $2", id, p.from)
+			}
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec(ctx, "UPDATE subscription SET version = version + 1 WHERE id = $1", id)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, pauseError("Resume", err)
+	}
+
+	return m.Get(id)
+}
+
+// lockSubscription reads subscription with its pauses and cancellation and locks it until end of transaction,
+// deleted subscription is returned as well
+func lockSubscription(ctx context.Context, q querier, id int) (*Subscription, error) {
+	query := "SELECT " + subscriptionSelect + " FROM subscription WHERE id = $1 FOR UPDATE"
+
+	sub, err := scanSubscription(q.QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	if err := loadState(ctx, q, sub); err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+// loadState fills pauses and cancellation of subscription
+func loadState(ctx context.Context, q querier, sub *Subscription) error {
+	pauses, err := loadPauses(ctx, q, []int{sub.Id})
+	if err != nil {
+		return err
+	}
+	sub.Pauses = pauses[sub.Id]
+
+	cancellations, err := loadCancellations(ctx, q, []int{sub.Id})
+	if err != nil {
+		return err
+	}
+	sub.Cancellation = cancellations[sub.Id]
+
+	return nil
+}
+
+// pauseError logs unexpected errors of pause and resume
+func pauseError(method string, err error) error {
+	if !errors.Is(err, ErrRecordNotFound) && !errors.Is(err, ErrPauseConflict) && !errors.Is(err, ErrNotPaused) && !errors.Is(err, ErrPauseOutOfRange) {
+		slog.Error("ERROR in Subscription "+method, "error", err)
+	}
+	return err
+}
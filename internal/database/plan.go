@@ -0,0 +1,395 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	// ErrPlanNotFound is returned when subscription references plan which doesn't exist
+	ErrPlanNotFound = errors.New("plan not found")
+	// ErrPlanMismatch is returned when subscription references plan of other service
+	ErrPlanMismatch = errors.New("plan belongs to other service")
+	// ErrPlanNameConflict is returned when service already has plan with the same name
+	ErrPlanNameConflict = errors.New("service already has plan with this name")
+	// ErrPlanInUse is returned when plan which has subscriptions is deleted
+	ErrPlanInUse = errors.New("plan has subscriptions")
+	// ErrInvalidPricePolicy is returned for unknown price policy
+	ErrInvalidPricePolicy = errors.New("invalid price policy")
+)
+
+// Plan is tier of service with its own price and billing period, e.g. Basic or Premium
+type Plan struct {
+	Id            int           `json:"id"`
+	ServiceId     int           `json:"service_id"`
+	Name          string        `json:"name" binding:"required,max=100"`
+	Price         Money         `json:"price"`
+	BillingPeriod BillingPeriod `json:"billing_period"`
+}
+
+// PricePolicy defines how price change of plan affects its existing subscriptions
+type PricePolicy string
+
+const (
+	// PriceKeep keeps price of existing subscriptions, new price is used by new subscriptions only
+	PriceKeep PricePolicy = "keep"
+	// PriceImmediate changes price of subscriptions from today
+	PriceImmediate PricePolicy = "immediate"
+	// PriceNextCycle schedules new price from next billing date of every subscription
+	PriceNextCycle PricePolicy = "next_cycle"
+)
+
+// ParsePricePolicy returns price policy by name, empty name means PriceKeep
+func ParsePricePolicy(s string) (PricePolicy, error) {
+	switch p := PricePolicy(s); p {
+	case "":
+		return PriceKeep, nil
+	case PriceKeep, PriceImmediate, PriceNextCycle:
+		return p, nil
+	}
+
+	return "", fmt.Errorf("%w %q, expected keep, immediate or next_cycle", ErrInvalidPricePolicy, s)
+}
+
+func (p *Plan) normalize() {
+	p.Price.Currency = currencyName(p.Price.Currency)
+	p.BillingPeriod = p.BillingPeriod.normalize()
+}
+
+// fill sets service and billing period of subscription on plan, price of plan is used when subscription has no price
+func (p *Plan) fill(sub *Subscription) error {
+	if sub.ServiceId != 0 && sub.ServiceId != p.ServiceId {
+		return ErrPlanMismatch
+	}
+
+	sub.ServiceId = p.ServiceId
+	sub.BillingPeriod = p.BillingPeriod
+	if sub.Price == 0 {
+		sub.Price, sub.Currency = p.Price.Amount, p.Price.Currency
+	}
+
+	return nil
+}
+
+// follows reports whether the latest price of subscription, including scheduled ones, is price of plan before change
+// and subscription isn't ended at day. Only such subscriptions get new price of plan, custom prices are kept.
+func (p *Plan) follows(sub *Subscription, history []PriceChange, day time.Time) bool {
+	history = historyOf(sub, history)
+	if sub.PlanId != p.Id || history[len(history)-1].Price != p.Price {
+		return false
+	}
+
+	_, end, err := ParseDates(sub.StartDate, sub.EndDate)
+
+	return err == nil && (end == nil || !end.Before(day))
+}
+
+// nextBillingDate returns first billing date of subscription after day, start date when it starts later
+func nextBillingDate(sub *Subscription, day time.Time) (time.Time, error) {
+	start, err := ParseDate(sub.StartDate, false)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if start.After(day) {
+		return start, nil
+	}
+
+	return sub.BillingPeriod.cycleEnd(start, day).AddDate(0, 0, 1), nil
+}
+
+// planFields adds fields which are set from plan when plan of subscription is changed
+func planFields(fields []string) []string {
+	if !slices.Contains(fields, "plan_id") {
+		return fields
+	}
+
+	for _, f := range []string{"service_name", "billing_period"} {
+		if !slices.Contains(fields, f) {
+			fields = append(fields, f)
+		}
+	}
+
+	return fields
+}
+
+// planArg returns plan of subscription as nullable column
+func (sub *Subscription) planArg() *int {
+	if sub.PlanId == 0 {
+		return nil
+	}
+	return &sub.PlanId
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503"
+}
+
+type PlanModel struct {
+	DB *pgxpool.Pool
+
+	actor Actor
+}
+
+// As returns model which records changes of subscriptions made by plan price changes as made by actor
+func (m *PlanModel) As(actor Actor) PlanRepository {
+	return &PlanModel{DB: m.DB, actor: actor}
+}
+
+const planSelect = "id, service_id, name, price, currency, billing_unit, billing_count"
+
+func scanPlan(row pgx.Row) (*Plan, error) {
+	var p Plan
+	if err := row.Scan(&p.Id, &p.ServiceId, &p.Name, &p.Price.Amount, &p.Price.Currency, &p.BillingPeriod.Unit, &p.BillingPeriod.Count); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// List returns plans of service sorted by price
+func (m *PlanModel) List(serviceId int) ([]Plan, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.Query(ctx, "SELECT "+planSelect+" FROM plans WHERE service_id = $1 ORDER BY price, id", serviceId)
+	if err != nil {
+		slog.Error("ERROR in Plan List", "error", err)
+		return nil, err
+	}
+
+	plans, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Plan, error) {
+		p, err := scanPlan(row)
+		if err != nil {
+			return Plan{}, err
+		}
+		return *p, nil
+	})
+	if err != nil {
+		slog.Error("ERROR in Plan List", "error", err)
+		return nil, err
+	}
+
+	return plans, nil
+}
+
+func (m *PlanModel) Get(id int) (*Plan, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	p, err := scanPlan(m.DB.QueryRow(ctx, "SELECT "+planSelect+" FROM plans WHERE id = $1", id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		slog.Error("ERROR in Plan Get", "error", err)
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Insert stores new plan, ErrServiceNotFound is returned when its service doesn't exist
+func (m *PlanModel) Insert(p *Plan) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	p.normalize()
+
+	query := `INSERT INTO plans (service_id, name, price, currency, billing_unit, billing_count) VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id`
+
+	err := m.DB.QueryRow(ctx, query, p.ServiceId, p.Name, p.Price.Amount, p.Price.Currency, p.BillingPeriod.Unit, p.BillingPeriod.Count).Scan(&p.Id)
+	switch {
+	case isForeignKeyViolation(err):
+		return ErrServiceNotFound
+	case isUniqueViolation(err):
+		return ErrPlanNameConflict
+	}
+
+	return planError("Insert", err)
+}
+
+// Update replaces name, price and billing period of plan. When price is changed, subscriptions which have
+// previous price of plan get new one according to policy, number of such subscriptions is returned.
+// Changed billing period is used by new subscriptions only.
+func (m *PlanModel) Update(p *Plan, policy PricePolicy) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	p.normalize()
+
+	var changed int
+	err := m.withTx(ctx, func(tx pgx.Tx) error {
+		old, err := scanPlan(tx.QueryRow(ctx, "SELECT "+planSelect+" FROM plans WHERE id = $1 FOR UPDATE", p.Id))
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return ErrRecordNotFound
+			}
+			return err
+		}
+		p.ServiceId = old.ServiceId
+
+		query := "UPDATE plans SET name = $2, price = $3, currency = $4, billing_unit = $5, billing_count = $6 WHERE id = $1"
+		if _, err := tx.Exec(ctx, query, p.Id, p.Name, p.Price.Amount, p.Price.Currency, p.BillingPeriod.Unit, p.BillingPeriod.Count); err != nil {
+			if isUniqueViolation(err) {
+				return ErrPlanNameConflict
+			}
+			return err
+		}
+
+		if policy == PriceKeep || old.Price == p.Price {
+			return nil
+		}
+
+		changed, err = propagatePlanPrice(ctx, tx, m.actor, old, p.Price, policy)
+		return err
+	})
+	if err != nil {
+		return 0, planError("Update", err)
+	}
+
+	return changed, nil
+}
+
+// propagatePlanPrice sets new price to subscriptions following plan, it returns number of changed subscriptions
+func propagatePlanPrice(ctx context.Context, q querier, actor Actor, old *Plan, price Money, policy PricePolicy) (int, error) {
+	today := Today()
+
+	query := "SELECT " + subscriptionSelect + " FROM subscription WHERE plan_id = $1 AND deleted_at IS NULL ORDER BY id FOR UPDATE"
+	rows, err := q.Query(ctx, query, old.Id)
+	if err != nil {
+		return 0, err
+	}
+	subs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Subscription, error) { return scanSubscription(row) })
+	if err != nil {
+		return 0, err
+	}
+
+	ids := make([]int, len(subs))
+	for i, sub := range subs {
+		ids[i] = sub.Id
+	}
+	history, err := loadPriceHistory(ctx, q, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	var changed int
+	for _, sub := range subs {
+		if !old.follows(sub, history[sub.Id], today) {
+			continue
+		}
+
+		// changes scheduled after new price would override it
+		if policy == PriceNextCycle {
+			effectiveFrom, err := nextBillingDate(sub, today)
+			if err != nil {
+				return 0, err
+			}
+			if err := dropPriceChanges(ctx, q, actor, sub.Id, effectiveFrom); err != nil {
+				return 0, err
+			}
+			if _, err := schedulePriceChange(ctx, q, actor, sub.Id, effectiveFrom, price); err != nil {
+				return 0, err
+			}
+		} else {
+			err := changeSubscription(ctx, q, actor, AuditUpdate, sub.Id, func(*Subscription) error {
+				if err := ensurePriceHistory(ctx, q, sub.Id); err != nil {
+					return err
+				}
+				if err := dropPriceChanges(ctx, q, actor, sub.Id, today); err != nil {
+					return err
+				}
+
+				query := "UPDATE subscription SET price = $2, currency = $3, version = version + 1 WHERE id = $1"
+				if _, err := q.Exec(ctx, query, sub.Id, price.Amount, price.Currency); err != nil {
+					return err
+				}
+
+				return recordPrice(ctx, q, sub.Id, today)
+			})
+			if err != nil {
+				return 0, err
+			}
+		}
+		changed++
+	}
+
+	return changed, nil
+}
+
+// Delete deletes plan without subscriptions
+func (m *PlanModel) Delete(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tag, err := m.DB.Exec(ctx, "DELETE FROM plans WHERE id = $1", id)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return ErrPlanInUse
+		}
+		return planError("Delete", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m *PlanModel) withTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return withTx(ctx, m.DB, fn)
+}
+
+// applyPlan fills subscription from its plan, service given by name must be service of the plan
+func applyPlan(ctx context.Context, q querier, sub *Subscription) error {
+	if sub.PlanId == 0 {
+		return nil
+	}
+
+	p, err := scanPlan(q.QueryRow(ctx, "SELECT "+planSelect+" FROM plans WHERE id = $1", sub.PlanId))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrPlanNotFound
+		}
+		return err
+	}
+
+	if sub.ServiceId == 0 && sub.ServiceName != "" {
+		s, err := lookupService(ctx, q, sub.ServiceName)
+		if err != nil {
+			return err
+		}
+		if s == nil {
+			return ErrPlanMismatch
+		}
+		sub.ServiceId = s.Id
+	}
+
+	return p.fill(sub)
+}
+
+// planError logs unexpected errors of plans
+func planError(method string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	for _, expected := range []error{ErrRecordNotFound, ErrPlanNameConflict, ErrPlanInUse, ErrServiceNotFound} {
+		if errors.Is(err, expected) {
+			return err
+		}
+	}
+
+	slog.Error("ERROR in Plan "+method, "error", err)
+	return err
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"math"
+	"math/big"
+	"time"
+)
+
+// PriceOptions controls how period price is calculated
+type PriceOptions struct {
+	Proration Proration
+	// Rounding is applied to amount of every subscription in every month, RoundHalfUp by default
+	Rounding Rounding
+	// Currency is currency of totals, BaseCurrency by default
+	Currency string
+	// Rates are used to convert prices in other currencies, rate valid at the first day of billing month is taken
+	Rates *RateTable
+}
+
+// SubscriptionPrice is price of single subscription for requested period
+type SubscriptionPrice struct {
+	Id          int    `json:"id"`
+	ServiceName string `json:"service_name"`
+	UserId      int    `json:"user_id"`
+	// Periods is number of billed periods, months for monthly billing, rounded to 4 decimal places for display only
+	Periods       float64       `json:"periods"`
+	BillingPeriod BillingPeriod `json:"billing_period"`
+	// UnitPrice is current price in subscription currency, months are billed with price valid at their first billed day.
+	// Subtotal is in report currency.
+	UnitPrice Money `json:"unit_price"`
+	Subtotal  Money `json:"subtotal"`
+}
+
+// MonthTotal is price of all subscriptions billed in calendar month
+type MonthTotal struct {
+	Month string `json:"month"`
+	Total Money  `json:"total"`
+}
+
+// PriceReport is result of period price calculation.
+// Amounts are in minor units and are rounded per subscription and month, so subtotals, timeline and total always add up.
+type PriceReport struct {
+	PeriodStart   string              `json:"period_start"`
+	PeriodEnd     string              `json:"period_end"`
+	Proration     Proration           `json:"proration"`
+	Rounding      Rounding            `json:"rounding"`
+	Currency      string              `json:"currency"`
+	Total         Money               `json:"total"`
+	Subscriptions []SubscriptionPrice `json:"subscriptions"`
+	Timeline      []MonthTotal        `json:"timeline"`
+
+	start    time.Time
+	end      time.Time
+	monthIdx map[time.Time]int
+	opts     PriceOptions
+}
+
+func newPriceReport(start, end time.Time, opts PriceOptions) *PriceReport {
+	if opts.Currency == "" {
+		opts.Currency = BaseCurrency
+	}
+	if opts.Rounding == "" {
+		opts.Rounding = RoundHalfUp
+	}
+
+	r := &PriceReport{
+		PeriodStart:   formatDate(start, false),
+		PeriodEnd:     formatDate(end, true),
+		Proration:     opts.Proration,
+		Rounding:      opts.Rounding,
+		Currency:      opts.Currency,
+		Total:         Money{Currency: opts.Currency},
+		Subscriptions: []SubscriptionPrice{},
+		Timeline:      []MonthTotal{},
+		start:         start,
+		end:           end,
+		monthIdx:      make(map[time.Time]int),
+		opts:          opts,
+	}
+
+	for month := start.AddDate(0, 0, 1-start.Day()); !month.After(end); month = month.AddDate(0, 1, 0) {
+		r.monthIdx[month] = len(r.Timeline)
+		r.Timeline = append(r.Timeline, MonthTotal{Month: month.Format(monthLayout), Total: Money{Currency: opts.Currency}})
+	}
+
+	return r
+}
+
+// add bills subscription with its price history except paused days, subEnd is nil for subscription without end date.
+// ErrNoExchangeRate is returned when subscription price can't be converted to report currency.
+func (r *PriceReport) add(sub *Subscription, subStart time.Time, subEnd *time.Time, history []PriceChange) error {
+	end := r.end
+	if subEnd != nil {
+		end = *subEnd
+	}
+
+	currency := sub.Currency
+	if currency == "" {
+		currency = BaseCurrency
+	}
+
+	price := SubscriptionPrice{
+		Id:            sub.Id,
+		ServiceName:   sub.ServiceName,
+		UserId:        sub.UserId,
+		UnitPrice:     Money{Amount: sub.Price, Currency: currency},
+		Subtotal:      Money{Currency: r.Currency},
+		BillingPeriod: sub.BillingPeriod.normalize(),
+	}
+
+	periods := new(big.Rat)
+	for _, share := range sub.breakdown(r.opts.Proration, subStart, end, r.start, r.end) {
+		unitPrice := priceAt(sub, history, share.Day)
+		exact := new(big.Rat).Mul(big.NewRat(unitPrice.Amount, 1), share.Periods)
+		amount, err := r.opts.Rates.Convert(exact, unitPrice.Currency, r.Currency, share.Month, r.opts.Rounding)
+		if err != nil {
+			return err
+		}
+
+		periods.Add(periods, share.Periods)
+		price.Subtotal.Amount += amount
+		r.Timeline[r.monthIdx[share.Month]].Total.Amount += amount
+	}
+	price.Periods, _ = periods.Float64()
+	price.Periods = math.Round(price.Periods*1e4) / 1e4
+
+	r.Total.Amount += price.Subtotal.Amount
+	r.Subscriptions = append(r.Subscriptions, price)
+
+	return nil
+}
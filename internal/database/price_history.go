@@ -0,0 +1,331 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// PriceChange is price of subscription effective from given day until next change
+type PriceChange struct {
+	EffectiveFrom string `json:"effective_from"`
+	Price         Money  `json:"price"`
+
+	effectiveFrom time.Time
+}
+
+func newPriceChange(effectiveFrom time.Time, price int64, currency string) PriceChange {
+	return PriceChange{
+		EffectiveFrom: effectiveFrom.Format(dayLayout),
+		Price:         Money{Amount: price, Currency: currency},
+		effectiveFrom: effectiveFrom,
+	}
+}
+
+// Today returns current UTC date, price set by update is effective from it
+func Today() time.Time {
+	return truncateDay(time.Now().UTC())
+}
+
+// priceAt returns price valid at day. History is sorted by effective date, the earliest price is used
+// before the first change and current subscription price when history is empty.
+func priceAt(sub *Subscription, history []PriceChange, day time.Time) Money {
+	if len(history) == 0 {
+		return Money{Amount: sub.Price, Currency: currencyName(sub.Currency)}
+	}
+
+	i, found := slices.BinarySearchFunc(history, day, func(c PriceChange, day time.Time) int { return c.effectiveFrom.Compare(day) })
+	if !found {
+		i--
+	}
+
+	return history[max(i, 0)].Price
+}
+
+// historyOf returns price history of subscription, history which was never changed consists of its current price
+func historyOf(sub *Subscription, history []PriceChange) []PriceChange {
+	if len(history) > 0 {
+		return history
+	}
+
+	start, _ := ParseDate(sub.StartDate, false)
+
+	return []PriceChange{newPriceChange(start, sub.Price, sub.Currency)}
+}
+
+// ensurePriceHistory stores current price of subscription effective from its start when it has no history yet,
+// so price valid before the first change is kept
+func ensurePriceHistory(ctx context.Context, q querier, id int) error {
+	query := `INSERT INTO subscription_price_history (subscription_id, effective_from, price, currency)
+			SELECT id, start_date, price, currency FROM subscription
+			WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM subscription_price_history WHERE subscription_id = $1)`
+
+	_, err := q.Exec(ctx, query, id)
+
+	return err
+}
+
+// recordPrice stores current price of subscription effective from day or its start when it starts later.
+// Nothing is stored when the same price is already valid at that day.
+func recordPrice(ctx context.Context, q querier, id int, day time.Time) error {
+	query := `INSERT INTO subscription_price_history (subscription_id, effective_from, price, currency)
+			SELECT id, GREATEST($2, start_date)::date, price, currency FROM subscription s
+			WHERE id = $1 AND NOT EXISTS (
+				SELECT 1 FROM (
+					SELECT price, currency FROM subscription_price_history
+					WHERE subscription_id = s.id
+					ORDER BY effective_from <= GREATEST($2, s.start_date) DESC,
+						CASE WHEN effective_from <= GREATEST($2, s.start_date) THEN effective_from END DESC, effective_from
+					LIMIT 1
+				) AS valid
+				WHERE valid.price = s.price AND valid.currency = s.currency
+			)
+			ON CONFLICT (subscription_id, effective_from) DO UPDATE SET price = EXCLUDED.price, currency = EXCLUDED.currency`
+
+	_, err := q.Exec(ctx, query, id, day)
+
+	return err
+}
+
+// loadPriceHistory returns price history of subscriptions sorted by effective date
+func loadPriceHistory(ctx context.Context, q querier, ids []int) (map[int][]PriceChange, error) {
+	query := `SELECT subscription_id, effective_from, price, currency FROM subscription_price_history
+			WHERE subscription_id = ANY($1) ORDER BY subscription_id, effective_from`
+
+	rows, err := q.Query(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	history := make(map[int][]PriceChange)
+	for rows.Next() {
+		var id int
+		var effectiveFrom time.Time
+		var price int64
+		var currency string
+
+		if err := rows.Scan(&id, &effectiveFrom, &price, &currency); err != nil {
+			return nil, err
+		}
+		history[id] = append(history[id], newPriceChange(effectiveFrom, price, currency))
+	}
+
+	return history, rows.Err()
+}
+
+// PriceHistory returns prices of subscription sorted by effective date, including scheduled ones
+func (m *SubscriptionModel) PriceHistory(id int) ([]PriceChange, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	sub, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if sub == nil {
+		return nil, ErrRecordNotFound
+	}
+
+	history, err := loadPriceHistory(ctx, m.DB, []int{id})
+	if err != nil {
+		slog.Error("ERROR in Subscription PriceHistory", "error", err)
+		return nil, err
+	}
+
+	return historyOf(sub, history[id]), nil
+}
+
+// SchedulePriceChange sets price of subscription effective from given day, change scheduled for the same day is replaced
+func (m *SubscriptionModel) SchedulePriceChange(id int, effectiveFrom time.Time, price Money) (*PriceChange, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var change *PriceChange
+	err := m.withTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		change, err = schedulePriceChange(ctx, tx, m.actor, id, effectiveFrom, price)
+		return err
+	})
+	if err != nil {
+		if err != ErrRecordNotFound {
+			slog.Error("ERROR in Subscription SchedulePriceChange", "error", err)
+		}
+		return nil, err
+	}
+
+	return change, nil
+}
+
+func schedulePriceChange(ctx context.Context, q querier, actor Actor, id int, effectiveFrom time.Time, price Money) (*PriceChange, error) {
+	if err := q.QueryRow(ctx, "SELECT id FROM subscription WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", id).Scan(&id); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	if err := ensurePriceHistory(ctx, q, id); err != nil {
+		return nil, err
+	}
+
+	replaced, err := priceChangeAt(ctx, q, id, effectiveFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `INSERT INTO subscription_price_history (subscription_id, effective_from, price, currency) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (subscription_id, effective_from) DO UPDATE SET price = EXCLUDED.price, currency = EXCLUDED.currency`
+
+	if _, err := q.Exec(ctx, query, id, effectiveFrom, price.Amount, price.Currency); err != nil {
+		return nil, err
+	}
+
+	change := newPriceChange(effectiveFrom, price.Amount, price.Currency)
+	if err := writeAudit(ctx, q, actor, AuditSchedulePrice, id, map[string]FieldChange{"price_change": valueChange(replaced, &change)}); err != nil {
+		return nil, err
+	}
+
+	return &change, nil
+}
+
+// priceChangeAt returns price change effective from day, nil when there is none
+func priceChangeAt(ctx context.Context, q querier, id int, day time.Time) (*PriceChange, error) {
+	var price int64
+	var currency string
+
+	err := q.QueryRow(ctx, "SELECT price, currency FROM subscription_price_history WHERE subscription_id = $1 AND effective_from = $2", id, day).Scan(&price, &currency)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	change := newPriceChange(day, price, currency)
+	return &change, nil
+}
+
+// CancelPriceChange deletes price change which is not effective yet
+func (m *SubscriptionModel) CancelPriceChange(id int, effectiveFrom time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM subscription_price_history AS h USING subscription AS s
+			WHERE h.subscription_id = $1 AND h.effective_from = $2 AND h.effective_from > $3 AND s.id = h.subscription_id AND s.deleted_at IS NULL
+			RETURNING h.price, h.currency`
+
+	err := m.withTx(ctx, func(tx pgx.Tx) error {
+		var price int64
+		var currency string
+
+		if err := tx.QueryRow(ctx, query, id, effectiveFrom, Today()).Scan(&price, &currency); err != nil {
+			if err == pgx.ErrNoRows {
+				return ErrRecordNotFound
+			}
+			return err
+		}
+
+		cancelled := newPriceChange(effectiveFrom, price, currency)
+
+		return writeAudit(ctx, tx, m.actor, AuditCancelPrice, id, map[string]FieldChange{"price_change": valueChange(&cancelled, nil)})
+	})
+	if err != nil && err != ErrRecordNotFound {
+		slog.Error("ERROR in Subscription CancelPriceChange", "error", err)
+	}
+
+	return err
+}
+
+// dropPriceChanges deletes price changes of subscription effective after day or after its start when it starts later,
+// deleted changes are recorded in audit log as cancelled
+func dropPriceChanges(ctx context.Context, q querier, actor Actor, id int, day time.Time) error {
+	query := `DELETE FROM subscription_price_history AS h USING subscription AS s
+			WHERE h.subscription_id = $1 AND s.id = h.subscription_id AND h.effective_from > GREATEST($2, s.start_date)
+			RETURNING h.effective_from, h.price, h.currency`
+
+	rows, err := q.Query(ctx, query, id, day)
+	if err != nil {
+		return err
+	}
+	dropped, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (PriceChange, error) {
+		var effectiveFrom time.Time
+		var price int64
+		var currency string
+		err := row.Scan(&effectiveFrom, &price, &currency)
+		return newPriceChange(effectiveFrom, price, currency), err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, c := range dropped {
+		if err := writeAudit(ctx, q, actor, AuditCancelPrice, id, map[string]FieldChange{"price_change": valueChange(&c, nil)}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// priceAudit returns changes of price and currency in the same format as other changes of subscription
+func priceAudit(oldPrice int64, oldCurrency string, price int64, currency string) map[string]FieldChange {
+	return auditChanges(&Subscription{Price: oldPrice, Currency: oldCurrency}, &Subscription{Price: price, Currency: currency})
+}
+
+// ApplyPriceChanges sets current price of subscriptions to price valid at day, it returns number of changed subscriptions
+func (m *SubscriptionModel) ApplyPriceChanges(day time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// old is joined to return values before update
+	query := `UPDATE subscription s SET price = h.price, currency = h.currency, version = s.version + 1
+			FROM (
+				SELECT DISTINCT ON (subscription_id) subscription_id, price, currency FROM subscription_price_history
+				WHERE effective_from <= $1
+				ORDER BY subscription_id, effective_from DESC
+			) AS h, subscription AS old
+			WHERE s.id = h.subscription_id AND old.id = s.id AND s.deleted_at IS NULL AND (s.price <> h.price OR s.currency <> h.currency)
+			RETURNING s.id, old.price, old.currency, h.price, h.currency`
+
+	var applied int
+	err := m.withTx(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, query, day)
+		if err != nil {
+			return err
+		}
+
+		type appliedPrice struct {
+			id                    int
+			oldPrice, price       int64
+			oldCurrency, currency string
+		}
+		changes, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (appliedPrice, error) {
+			var a appliedPrice
+			err := row.Scan(&a.id, &a.oldPrice, &a.oldCurrency, &a.price, &a.currency)
+			return a, err
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, a := range changes {
+			if err := writeAudit(ctx, tx, m.actor, AuditApplyPrice, a.id, priceAudit(a.oldPrice, a.oldCurrency, a.price, a.currency)); err != nil {
+				return err
+			}
+		}
+		applied = len(changes)
+
+		return nil
+	})
+	if err != nil {
+		slog.Error("ERROR in Subscription ApplyPriceChanges", "error", err)
+		return 0, err
+	}
+
+	return applied, nil
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+var ErrInvalidProration = errors.New("invalid proration")
+
+// Proration is method of counting billed months for part of subscription within period
+type Proration string
+
+const (
+	// ProrationMonthly bills every calendar month touched by subscription in full
+	ProrationMonthly Proration = "monthly"
+	// ProrationDaily bills partial month by share of its days
+	ProrationDaily Proration = "daily"
+	// Proration30360 counts days with 30/360 convention, as if every month has 30 days
+	Proration30360 Proration = "30/360"
+)
+
+// ParseProration returns proration by name, empty name means ProrationMonthly
+func ParseProration(s string) (Proration, error) {
+	switch p := Proration(s); p {
+	case "":
+		return ProrationMonthly, nil
+	case ProrationMonthly, ProrationDaily, Proration30360:
+		return p, nil
+	}
+
+	return "", fmt.Errorf("%w %q, expected monthly, daily or 30/360", ErrInvalidProration, s)
+}
+
+// MonthShare is number of billing periods charged for subscription in calendar month,
+// for monthly billing it is share of month. Day is the first billed day, price valid at it is charged.
+// Periods is exact fraction, amounts are rounded only when share is multiplied by price.
+type MonthShare struct {
+	Month   time.Time
+	Day     time.Time
+	Periods *big.Rat
+}
+
+// Breakdown splits part of subscription within period by calendar months, all dates are inclusive.
+// Share of month is days billed in it divided by days in month, 30 with 30/360 convention.
+func (p Proration) Breakdown(subStart, subEnd, periodStart, periodEnd time.Time) []MonthShare {
+	start := truncateDay(subStart)
+	if periodStart.After(start) {
+		start = truncateDay(periodStart)
+	}
+
+	end := truncateDay(subEnd)
+	if periodEnd.Before(end) {
+		end = truncateDay(periodEnd)
+	}
+
+	if end.Before(start) {
+		return nil
+	}
+
+	var shares []MonthShare
+
+	for month := start.AddDate(0, 0, 1-start.Day()); !month.After(end); month = month.AddDate(0, 1, 0) {
+		monthEnd := month.AddDate(0, 1, -1)
+
+		from, to := month, monthEnd
+		if start.After(from) {
+			from = start
+		}
+		if end.Before(to) {
+			to = end
+		}
+
+		months := big.NewRat(1, 1)
+		switch p {
+		case ProrationDaily:
+			months = big.NewRat(int64(to.Sub(from).Hours()/24)+1, int64(monthEnd.Day()))
+		case Proration30360:
+			months = big.NewRat(int64(days360(from, to.AddDate(0, 0, 1))), 30)
+		}
+
+		shares = append(shares, MonthShare{Month: month, Day: from, Periods: months})
+	}
+
+	return shares
+}
+
+// days360 returns number of days between start and exclusive end with 30/360 convention
+func days360(start, end time.Time) int {
+	y1, m1, d1 := start.Date()
+	y2, m2, d2 := end.Date()
+
+	if d1 == 31 {
+		d1 = 30
+	}
+	if d2 == 31 && d1 == 30 {
+		d2 = 30
+	}
+
+	return (y2-y1)*360 + (int(m2)-int(m1))*30 + d2 - d1
+}
@@ -0,0 +1,316 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse(dayLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// formatShares renders shares as "month day periods" lines with exact fractions
+func formatShares(shares []MonthShare) []string {
+	lines := []string{}
+	for _, s := range shares {
+		lines = append(lines, fmt.Sprintf("%s %s %s", s.Month.Format(monthLayout), s.Day.Format(dayLayout), s.Periods.RatString()))
+	}
+	return lines
+}
+
+func TestProrationBreakdown(t *testing.T) {
+	tests := []struct {
+		name                   string
+		proration              Proration
+		subStart, subEnd       string
+		periodStart, periodEnd string
+		want                   []string
+	}{
+		{
+			name:      "daily mid-month start and end",
+			proration: ProrationDaily,
+			subStart:  "15-01-2026", subEnd: "10-02-2026",
+			periodStart: "01-01-2026", periodEnd: "31-03-2026",
+			want: []string{"01-2026 15-01-2026 17/31", "02-2026 01-02-2026 5/14"},
+		},
+		{
+			name:      "30/360 mid-month start and end",
+			proration: Proration30360,
+			subStart:  "15-01-2026", subEnd: "10-02-2026",
+			periodStart: "01-01-2026", periodEnd: "31-03-2026",
+			want: []string{"01-2026 15-01-2026 8/15", "02-2026 01-02-2026 1/3"},
+		},
+		{
+			name:      "monthly mid-month start and end",
+			proration: ProrationMonthly,
+			subStart:  "15-01-2026", subEnd: "10-02-2026",
+			periodStart: "01-01-2026", periodEnd: "31-03-2026",
+			want: []string{"01-2026 15-01-2026 1", "02-2026 01-02-2026 1"},
+		},
+		{
+			name:      "subscription is clipped by period",
+			proration: ProrationDaily,
+			subStart:  "01-01-2026", subEnd: "31-12-2026",
+			periodStart: "20-03-2026", periodEnd: "05-04-2026",
+			want: []string{"03-2026 20-03-2026 12/31", "04-2026 01-04-2026 1/6"},
+		},
+		{
+			name:      "daily one day",
+			proration: ProrationDaily,
+			subStart:  "05-03-2026", subEnd: "05-03-2026",
+			periodStart: "01-03-2026", periodEnd: "31-03-2026",
+			want: []string{"03-2026 05-03-2026 1/31"},
+		},
+		{
+			name:      "30/360 one day",
+			proration: Proration30360,
+			subStart:  "05-03-2026", subEnd: "05-03-2026",
+			periodStart: "01-03-2026", periodEnd: "31-03-2026",
+			want: []string{"03-2026 05-03-2026 1/30"},
+		},
+		{
+			name:      "monthly one day",
+			proration: ProrationMonthly,
+			subStart:  "05-03-2026", subEnd: "05-03-2026",
+			periodStart: "01-03-2026", periodEnd: "31-03-2026",
+			want: []string{"03-2026 05-03-2026 1"},
+		},
+		{
+			name:      "daily whole February 28",
+			proration: ProrationDaily,
+			subStart:  "01-02-2026", subEnd: "28-02-2026",
+			periodStart: "01-01-2026", periodEnd: "31-12-2026",
+			want: []string{"02-2026 01-02-2026 1"},
+		},
+		{
+			name:      "daily whole February 29",
+			proration: ProrationDaily,
+			subStart:  "01-02-2024", subEnd: "29-02-2024",
+			periodStart: "01-01-2024", periodEnd: "31-12-2024",
+			want: []string{"02-2024 01-02-2024 1"},
+		},
+		{
+			name:      "daily leap February till 28",
+			proration: ProrationDaily,
+			subStart:  "01-02-2024", subEnd: "28-02-2024",
+			periodStart: "01-01-2024", periodEnd: "31-12-2024",
+			want: []string{"02-2024 01-02-2024 28/29"},
+		},
+		{
+			name:      "30/360 whole February 28",
+			proration: Proration30360,
+			subStart:  "01-02-2026", subEnd: "28-02-2026",
+			periodStart: "01-01-2026", periodEnd: "31-12-2026",
+			want: []string{"02-2026 01-02-2026 1"},
+		},
+		{
+			name:      "30/360 whole February 29",
+			proration: Proration30360,
+			subStart:  "01-02-2024", subEnd: "29-02-2024",
+			periodStart: "01-01-2024", periodEnd: "31-12-2024",
+			want: []string{"02-2024 01-02-2024 1"},
+		},
+		{
+			name:      "30/360 whole 31-day month",
+			proration: Proration30360,
+			subStart:  "01-01-2026", subEnd: "31-01-2026",
+			periodStart: "01-01-2026", periodEnd: "31-01-2026",
+			want: []string{"01-2026 01-01-2026 1"},
+		},
+		{
+			name:      "30/360 second half of 31-day month",
+			proration: Proration30360,
+			subStart:  "16-01-2026", subEnd: "31-03-2026",
+			periodStart: "01-01-2026", periodEnd: "31-03-2026",
+			want: []string{"01-2026 16-01-2026 1/2", "02-2026 01-02-2026 1", "03-2026 01-03-2026 1"},
+		},
+		{
+			name:      "30/360 last day of 31-day month",
+			proration: Proration30360,
+			subStart:  "31-01-2026", subEnd: "31-01-2026",
+			periodStart: "01-01-2026", periodEnd: "31-01-2026",
+			want: []string{"01-2026 31-01-2026 1/30"},
+		},
+		{
+			name:      "start after end",
+			proration: ProrationDaily,
+			subStart:  "10-02-2026", subEnd: "09-02-2026",
+			periodStart: "01-01-2026", periodEnd: "31-12-2026",
+			want: []string{},
+		},
+		{
+			name:      "subscription outside period",
+			proration: ProrationDaily,
+			subStart:  "01-01-2026", subEnd: "31-01-2026",
+			periodStart: "01-03-2026", periodEnd: "31-03-2026",
+			want: []string{},
+		},
+		{
+			name:      "open-ended daily",
+			proration: ProrationDaily,
+			subStart:  "10-11-2026", subEnd: "31-12-9999",
+			periodStart: "01-11-2026", periodEnd: "31-12-2026",
+			want: []string{"11-2026 10-11-2026 7/10", "12-2026 01-12-2026 1"},
+		},
+		{
+			name:      "open-ended 30/360",
+			proration: Proration30360,
+			subStart:  "10-11-2026", subEnd: "31-12-9999",
+			periodStart: "01-11-2026", periodEnd: "31-12-2026",
+			want: []string{"11-2026 10-11-2026 7/10", "12-2026 01-12-2026 1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatShares(tt.proration.Breakdown(day(tt.subStart), day(tt.subEnd), day(tt.periodStart), day(tt.periodEnd)))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Breakdown() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEndMonthIsBilled covers inclusive end date: end month was not billed when "05-2025" was stored as 1 May
+func TestEndMonthIsBilled(t *testing.T) {
+	start, end, err := ParseDates("01-2025", "05-2025")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := end.Format(dayLayout), "31-05-2025"; got != want {
+		t.Fatalf("end date = %s, want %s", got, want)
+	}
+
+	for _, p := range []Proration{ProrationMonthly, ProrationDaily} {
+		t.Run(string(p), func(t *testing.T) {
+			shares := formatShares(p.Breakdown(start, *end, day("01-04-2025"), day("31-12-2025")))
+			if want := []string{"04-2025 01-04-2025 1", "05-2025 01-05-2025 1"}; !slices.Equal(shares, want) {
+				t.Errorf("Breakdown() = %q, want %q", shares, want)
+			}
+		})
+	}
+}
+
+func TestDays360(t *testing.T) {
+	tests := []struct {
+		start, end string
+		want       int
+	}{
+		{"01-01-2026", "01-02-2026", 30},
+		{"15-01-2026", "16-01-2026", 1},
+		{"31-01-2026", "01-03-2026", 31},
+		{"30-01-2026", "31-01-2026", 0},
+		{"31-01-2026", "31-03-2026", 60},
+		{"28-02-2026", "01-03-2026", 3},
+		{"29-02-2024", "01-03-2024", 2},
+		{"15-12-2025", "15-01-2026", 30},
+		{"01-01-2026", "01-01-2027", 360},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.start+"_"+tt.end, func(t *testing.T) {
+			if got := days360(day(tt.start), day(tt.end)); got != tt.want {
+				t.Errorf("days360(%s, %s) = %d, want %d", tt.start, tt.end, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBillingPeriodBreakdown(t *testing.T) {
+	tests := []struct {
+		name                   string
+		billing                BillingPeriod
+		proration              Proration
+		subStart, subEnd       string
+		periodStart, periodEnd string
+		want                   []string
+	}{
+		{
+			name:      "empty period is prorated monthly billing",
+			proration: ProrationDaily,
+			subStart:  "15-01-2026", subEnd: "10-02-2026",
+			periodStart: "01-01-2026", periodEnd: "31-03-2026",
+			want: []string{"01-2026 15-01-2026 17/31", "02-2026 01-02-2026 5/14"},
+		},
+		{
+			name:      "weekly charges within month",
+			billing:   BillingPeriod{Unit: BillingWeek, Count: 1},
+			proration: ProrationDaily,
+			subStart:  "01-10-2026", subEnd: "31-10-2026",
+			periodStart: "01-10-2026", periodEnd: "31-10-2026",
+			want: []string{
+				"10-2026 01-10-2026 1", "10-2026 08-10-2026 1", "10-2026 15-10-2026 1",
+				"10-2026 22-10-2026 1", "10-2026 29-10-2026 1",
+			},
+		},
+		{
+			name:      "charges before period are skipped",
+			billing:   BillingPeriod{Unit: BillingDay, Count: 10},
+			proration: ProrationMonthly,
+			subStart:  "25-01-2026", subEnd: "28-02-2026",
+			periodStart: "01-02-2026", periodEnd: "28-02-2026",
+			want: []string{"02-2026 04-02-2026 1", "02-2026 14-02-2026 1", "02-2026 24-02-2026 1"},
+		},
+		{
+			name:      "one-day subscription is charged once",
+			billing:   BillingPeriod{Unit: BillingMonth, Count: 3},
+			proration: ProrationDaily,
+			subStart:  "05-03-2026", subEnd: "05-03-2026",
+			periodStart: "01-01-2026", periodEnd: "31-12-2026",
+			want: []string{"03-2026 05-03-2026 1"},
+		},
+		{
+			name:      "quarterly billing keeps month end",
+			billing:   BillingPeriod{Unit: BillingMonth, Count: 3},
+			proration: ProrationDaily,
+			subStart:  "30-11-2025", subEnd: "31-12-2026",
+			periodStart: "01-01-2026", periodEnd: "31-12-2026",
+			want: []string{"02-2026 28-02-2026 1", "05-2026 30-05-2026 1", "08-2026 30-08-2026 1", "11-2026 30-11-2026 1"},
+		},
+		{
+			name:      "yearly billing from February 29",
+			billing:   BillingPeriod{Unit: BillingYear, Count: 1},
+			proration: ProrationDaily,
+			subStart:  "29-02-2024", subEnd: "31-12-2028",
+			periodStart: "01-01-2024", periodEnd: "31-12-2028",
+			want: []string{
+				"02-2024 29-02-2024 1", "02-2025 28-02-2025 1", "02-2026 28-02-2026 1",
+				"02-2027 28-02-2027 1", "02-2028 29-02-2028 1",
+			},
+		},
+		{
+			name:      "start after end",
+			billing:   BillingPeriod{Unit: BillingWeek, Count: 1},
+			proration: ProrationDaily,
+			subStart:  "10-02-2026", subEnd: "09-02-2026",
+			periodStart: "01-01-2026", periodEnd: "31-12-2026",
+			want: []string{},
+		},
+		{
+			name:      "open-ended is billed till period end",
+			billing:   BillingPeriod{Unit: BillingWeek, Count: 2},
+			proration: ProrationDaily,
+			subStart:  "01-12-2026", subEnd: "31-12-9999",
+			periodStart: "01-12-2026", periodEnd: "31-01-2027",
+			want: []string{
+				"12-2026 01-12-2026 1", "12-2026 15-12-2026 1", "12-2026 29-12-2026 1",
+				"01-2027 12-01-2027 1", "01-2027 26-01-2027 1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shares := tt.billing.Breakdown(tt.proration, day(tt.subStart), day(tt.subEnd), day(tt.periodStart), day(tt.periodEnd))
+			if got := formatShares(shares); !slices.Equal(got, tt.want) {
+				t.Errorf("Breakdown() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
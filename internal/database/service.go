@@ -0,0 +1,471 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	// ErrServiceNameConflict is returned when name or alias of service is already used by other service
+	ErrServiceNameConflict = errors.New("service name or alias is already used by other service")
+	// ErrServiceInUse is returned when service which has subscriptions is deleted
+	ErrServiceInUse = errors.New("service has subscriptions, merge it into other service instead")
+	// ErrServiceNotFound is returned when subscription references service which doesn't exist
+	ErrServiceNotFound = errors.New("service not found")
+	// ErrPriceRequired is returned when subscription has no price and its service has no default price
+	ErrPriceRequired = errors.New("price is required, service has no default price")
+)
+
+// Service is entry of service catalog. Subscriptions reference it by id and keep its name,
+// names and aliases which differ only in case and spaces are the same service.
+type Service struct {
+	Id      int      `json:"id"`
+	Name    string   `json:"name" binding:"required,max=255"`
+	Aliases []string `json:"aliases" binding:"dive,required,max=255"`
+	// Category groups services, e.g. "video" or "music"
+	Category string `json:"category" binding:"max=100"`
+	// DefaultPrice is used when subscription referencing service by id has no price
+	DefaultPrice *Money `json:"default_price,omitempty"`
+	LogoURL      string `json:"logo_url" binding:"omitempty,url,max=2048"`
+}
+
+// ServiceKey returns form of service name which is used to look service up: lower case with single spaces
+func ServiceKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// normalize trims name and drops aliases which are the same as name or other alias
+func (s *Service) normalize() {
+	s.Name = strings.Join(strings.Fields(s.Name), " ")
+
+	seen := map[string]bool{ServiceKey(s.Name): true}
+	aliases := []string{}
+	for _, a := range s.Aliases {
+		a = strings.Join(strings.Fields(a), " ")
+		if key := ServiceKey(a); a != "" && !seen[key] {
+			seen[key] = true
+			aliases = append(aliases, a)
+		}
+	}
+	s.Aliases = aliases
+
+	if s.DefaultPrice != nil {
+		s.DefaultPrice.Currency = currencyName(s.DefaultPrice.Currency)
+	}
+}
+
+// keys returns lookup keys of service name and aliases
+func (s *Service) keys() []string {
+	keys := []string{ServiceKey(s.Name)}
+	for _, a := range s.Aliases {
+		keys = append(keys, ServiceKey(a))
+	}
+	return keys
+}
+
+// fill sets service of subscription and fills its price with default price of service when price isn't given
+func (s *Service) fill(sub *Subscription) error {
+	sub.ServiceId = s.Id
+	sub.ServiceName = s.Name
+
+	if sub.Price == 0 {
+		if s.DefaultPrice == nil {
+			return ErrPriceRequired
+		}
+		sub.Price, sub.Currency = s.DefaultPrice.Amount, s.DefaultPrice.Currency
+	}
+
+	return nil
+}
+
+// mergeNames adds name and aliases of merged service to aliases of service
+func (s *Service) mergeNames(merged *Service) {
+	s.Aliases = append(slices.Clone(s.Aliases), merged.Name)
+	s.Aliases = append(s.Aliases, merged.Aliases...)
+	s.normalize()
+}
+
+// isUniqueViolation reports whether error is violation of unique constraint
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+type ServiceModel struct {
+	DB *pgxpool.Pool
+
+	actor Actor
+}
+
+// As returns model which records changes of subscriptions made by catalog changes as made by actor
+func (m *ServiceModel) As(actor Actor) ServiceRepository {
+	return &ServiceModel{DB: m.DB, actor: actor}
+}
+
+const serviceSelect = "id, name, aliases, category, default_price, default_currency, logo_url"
+
+func scanService(row pgx.Row) (*Service, error) {
+	var s Service
+	var price *int64
+	var currency *string
+
+	if err := row.Scan(&s.Id, &s.Name, &s.Aliases, &s.Category, &price, &currency, &s.LogoURL); err != nil {
+		return nil, err
+	}
+	if price != nil && currency != nil {
+		s.DefaultPrice = &Money{Amount: *price, Currency: *currency}
+	}
+
+	return &s, nil
+}
+
+// defaultPriceArgs returns default price of service as nullable columns
+func (s *Service) defaultPriceArgs() (*int64, *string) {
+	if s.DefaultPrice == nil {
+		return nil, nil
+	}
+	return &s.DefaultPrice.Amount, &s.DefaultPrice.Currency
+}
+
+func (m *ServiceModel) withTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return withTx(ctx, m.DB, fn)
+}
+
+// List returns services sorted by name, category filters services when it isn't empty
+func (m *ServiceModel) List(category string) ([]Service, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := "SELECT " + serviceSelect + " FROM services WHERE ($1 = '' OR category = $1) ORDER BY name, id"
+
+	rows, err := m.DB.Query(ctx, query, category)
+	if err != nil {
+		slog.Error("ERROR in Service List", "error", err)
+		return nil, err
+	}
+
+	services, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Service, error) {
+		s, err := scanService(row)
+		if err != nil {
+			return Service{}, err
+		}
+		return *s, nil
+	})
+	if err != nil {
+		slog.Error("ERROR in Service List", "error", err)
+		return nil, err
+	}
+
+	return services, nil
+}
+
+func (m *ServiceModel) Get(id int) (*Service, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	s, err := scanService(m.DB.QueryRow(ctx, "SELECT "+serviceSelect+" FROM services WHERE id = $1", id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		slog.Error("ERROR in Service Get", "error", err)
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Lookup returns service with given name or alias, nil when there is none
+func (m *ServiceModel) Lookup(name string) (*Service, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	s, err := lookupService(ctx, m.DB, name)
+	if err != nil {
+		slog.Error("ERROR in Service Lookup", "error", err)
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func lookupService(ctx context.Context, q querier, name string) (*Service, error) {
+	query := "SELECT " + serviceSelect + " FROM services WHERE id = (SELECT service_id FROM service_names WHERE key = $1)"
+
+	s, err := scanService(q.QueryRow(ctx, query, ServiceKey(name)))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+
+	return s, err
+}
+
+// setServiceNames replaces lookup keys of service
+func setServiceNames(ctx context.Context, q querier, s *Service) error {
+	if _, err := q.Exec(ctx, "DELETE FROM service_names WHERE service_id = $1", s.Id); err != nil {
+		return err
+	}
+
+	_, err := q.Exec(ctx, "INSERT INTO service_names (key, service_id) SELECT unnest($1::text[]), $2", s.keys(), s.Id)
+	if isUniqueViolation(err) {
+		return ErrServiceNameConflict
+	}
+
+	return err
+}
+
+func insertService(ctx context.Context, q querier, s *Service) error {
+	s.normalize()
+	price, currency := s.defaultPriceArgs()
+
+	query := "INSERT INTO services (name, aliases, category, default_price, default_currency, logo_url) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id"
+
+	if err := q.QueryRow(ctx, query, s.Name, s.Aliases, s.Category, price, currency, s.LogoURL).Scan(&s.Id); err != nil {
+		return err
+	}
+
+	return setServiceNames(ctx, q, s)
+}
+
+func (m *ServiceModel) Insert(s *Service) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.withTx(ctx, func(tx pgx.Tx) error {
+		return insertService(ctx, tx, s)
+	})
+
+	return serviceError("Insert", err)
+}
+
+// Update replaces service, subscriptions of renamed service get its new name and their version is incremented
+func (m *ServiceModel) Update(s *Service) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	s.normalize()
+	price, currency := s.defaultPriceArgs()
+
+	err := m.withTx(ctx, func(tx pgx.Tx) error {
+		query := `UPDATE services SET name = $2, aliases = $3, category = $4, default_price = $5, default_currency = $6, logo_url = $7
+				WHERE id = $1`
+
+		tag, err := tx.Exec(ctx, query, s.Id, s.Name, s.Aliases, s.Category, price, currency, s.LogoURL)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrRecordNotFound
+		}
+
+		if err := setServiceNames(ctx, tx, s); err != nil {
+			return err
+		}
+
+		return moveSubscriptions(ctx, tx, m.actor, s.Id, s)
+	})
+
+	return serviceError("Update", err)
+}
+
+// moveSubscriptions makes subscriptions of service with id subscriptions of service s with its name, version of changed
+// subscriptions is incremented and change is recorded in audit log. Deleted subscriptions are moved as well.
+func moveSubscriptions(ctx context.Context, q querier, actor Actor, id int, s *Service) error {
+	query := `UPDATE subscription AS sub SET service_id = $2, service_name = $3, version = sub.version + 1
+			FROM subscription AS old
+			WHERE old.id = sub.id AND sub.service_id = $1 AND (sub.service_id <> $2 OR sub.service_name <> $3)
+			RETURNING sub.id, old.service_id, old.service_name`
+
+	rows, err := q.Query(ctx, query, id, s.Id, s.Name)
+	if err != nil {
+		return err
+	}
+
+	type moved struct {
+		id, serviceId int
+		serviceName   string
+	}
+	subs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (moved, error) {
+		var m moved
+		err := row.Scan(&m.id, &m.serviceId, &m.serviceName)
+		return m, err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range subs {
+		changes := auditChanges(&Subscription{ServiceId: sub.serviceId, ServiceName: sub.serviceName}, &Subscription{ServiceId: s.Id, ServiceName: s.Name})
+		if err := writeAudit(ctx, q, actor, AuditUpdate, sub.id, changes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete deletes service which has no subscriptions
+func (m *ServiceModel) Delete(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.withTx(ctx, func(tx pgx.Tx) error {
+		var used bool
+		if err := tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM subscription WHERE service_id = $1)", id).Scan(&used); err != nil {
+			return err
+		}
+		if used {
+			return ErrServiceInUse
+		}
+
+		tag, err := tx.Exec(ctx, "DELETE FROM services WHERE id = $1", id)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrRecordNotFound
+		}
+
+		return nil
+	})
+
+	return serviceError("Delete", err)
+}
+
+// Merge moves subscriptions and plans of merged services to service with id, names of merged services become its aliases
+// and merged services are deleted
+func (m *ServiceModel) Merge(id int, merged []int) (*Service, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var target *Service
+	err := m.withTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		target, err = lockService(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		for _, mergedId := range merged {
+			if mergedId == id {
+				continue
+			}
+
+			s, err := lockService(ctx, tx, mergedId)
+			if err != nil {
+				return err
+			}
+
+			if err := moveSubscriptions(ctx, tx, m.actor, s.Id, target); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, "UPDATE plans SET service_id = $2 WHERE service_id = $1", s.Id, target.Id); err != nil {
+				if isUniqueViolation(err) {
+					return ErrPlanNameConflict
+				}
+				return err
+			}
+			if _, err := tx.Exec(ctx, "DELETE FROM services WHERE id = $1", s.Id); err != nil {
+				return err
+			}
+
+			target.mergeNames(s)
+		}
+
+		if _, err := tx.Exec(ctx, "UPDATE services SET aliases = $2 WHERE id = $1", target.Id, target.Aliases); err != nil {
+			return err
+		}
+
+		return setServiceNames(ctx, tx, target)
+	})
+	if err != nil {
+		return nil, serviceError("Merge", err)
+	}
+
+	return target, nil
+}
+
+func lockService(ctx context.Context, q querier, id int) (*Service, error) {
+	s, err := scanService(q.QueryRow(ctx, "SELECT "+serviceSelect+" FROM services WHERE id = $1 FOR UPDATE", id))
+	if err == pgx.ErrNoRows {
+		return nil, ErrRecordNotFound
+	}
+
+	return s, err
+}
+
+// resolveService links subscription to service given by id or by name, service with unknown name is created.
+// Name of subscription is replaced with name of the service.
+func resolveService(ctx context.Context, q querier, sub *Subscription) error {
+	if err := applyPlan(ctx, q, sub); err != nil {
+		return err
+	}
+
+	s, err := findService(ctx, q, sub)
+	if err != nil {
+		return err
+	}
+
+	return s.fill(sub)
+}
+
+// serviceRef identifies service of subscription, it is used to resolve every service once
+func serviceRef(sub *Subscription) string {
+	if sub.ServiceId != 0 {
+		return "id:" + strconv.Itoa(sub.ServiceId)
+	}
+	return "name:" + ServiceKey(sub.ServiceName)
+}
+
+// findService returns service of subscription given by id or by name, service with unknown name is created
+func findService(ctx context.Context, q querier, sub *Subscription) (*Service, error) {
+	if sub.ServiceId != 0 {
+		s, err := scanService(q.QueryRow(ctx, "SELECT "+serviceSelect+" FROM services WHERE id = $1", sub.ServiceId))
+		if err == pgx.ErrNoRows {
+			return nil, ErrServiceNotFound
+		}
+		return s, err
+	}
+
+	// concurrent transactions creating service with the same name wait for each other
+	if _, err := q.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext('service:' || $1))", ServiceKey(sub.ServiceName)); err != nil {
+		return nil, err
+	}
+
+	s, err := lookupService(ctx, q, sub.ServiceName)
+	if err != nil || s != nil {
+		return s, err
+	}
+
+	s = &Service{Name: sub.ServiceName}
+	if err := insertService(ctx, q, s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// serviceError logs unexpected errors of service catalog
+func serviceError(method string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	for _, expected := range []error{ErrRecordNotFound, ErrServiceNameConflict, ErrServiceInUse, ErrPlanNameConflict} {
+		if errors.Is(err, expected) {
+			return err
+		}
+	}
+
+	slog.Error("ERROR in Service "+method, "error", err)
+	return err
+}
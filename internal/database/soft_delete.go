@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrNotDeleted is returned when subscription which isn't deleted is restored or purged
+var ErrNotDeleted = errors.New("subscription is not deleted")
+
+// billedEnd returns last billed day of subscription with given end date, deleted subscription is billed until the day it was deleted
+func (sub *Subscription) billedEnd(end *time.Time) *time.Time {
+	if sub.DeletedAt == nil {
+		return end
+	}
+
+	day := truncateDay(sub.DeletedAt.UTC())
+	if end != nil && end.Before(day) {
+		return end
+	}
+	return &day
+}
+
+// deletedError logs unexpected errors of restore and purge
+func deletedError(method string, err error) error {
+	if err != nil && !errors.Is(err, ErrRecordNotFound) && !errors.Is(err, ErrNotDeleted) {
+		slog.Error("ERROR in Subscription "+method, "error", err)
+	}
+	return err
+}
+
+// Restore brings back deleted subscription and increments its version
+func (m *SubscriptionModel) Restore(id int) (*Subscription, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.withTx(ctx, func(tx pgx.Tx) error {
+		return changeSubscription(ctx, tx, m.actor, AuditRestore, id, func(sub *Subscription) error {
+			if sub.DeletedAt == nil {
+				return ErrNotDeleted
+			}
+
+			_, err := tx.Exec(ctx, "UPDATE subscription SET deleted_at = NULL, version = version + 1 WHERE id = $1", id)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, deletedError("Restore", err)
+	}
+
+	return m.Get(id)
+}
+
+// Purge permanently deletes subscription which was deleted before, with its history. Its audit log is kept.
+func (m *SubscriptionModel) Purge(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.withTx(ctx, func(tx pgx.Tx) error {
+		return purgeSubscription(ctx, tx, m.actor, id)
+	})
+
+	return deletedError("Purge", err)
+}
+
+func purgeSubscription(ctx context.Context, q querier, actor Actor, id int) error {
+	return changeSubscription(ctx, q, actor, AuditPurge, id, func(sub *Subscription) error {
+		if sub.DeletedAt == nil {
+			return ErrNotDeleted
+		}
+
+		_, err := q.Exec(ctx, "DELETE FROM subscription WHERE id = $1", id)
+		return err
+	})
+}
+
+// PurgeDeleted permanently deletes subscriptions deleted before given time, it returns number of purged subscriptions
+func (m *SubscriptionModel) PurgeDeleted(before time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var purged int
+	err := m.withTx(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, "SELECT id FROM subscription WHERE deleted_at < $1 ORDER BY id FOR UPDATE", before)
+		if err != nil {
+			return err
+		}
+		ids, err := pgx.CollectRows(rows, pgx.RowTo[int])
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			if err := purgeSubscription(ctx, tx, m.actor, id); err != nil {
+				return err
+			}
+		}
+		purged = len(ids)
+
+		return nil
+	})
+	if err != nil {
+		slog.Error("ERROR in Subscription PurgeDeleted", "error", err)
+		return 0, err
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,224 @@
+package database
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSort = errors.New("invalid sort")
+
+// noEndDate is used in place of NULL end date when sorting, so open subscriptions go last
+var noEndDate = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// sortColumns maps sortable fields to SQL expressions
+var sortColumns = map[string]string{
+	"id":           "id",
+	"service_name": "service_name",
+	"price":        "price",
+	"user_id":      "user_id",
+	"start_date":   "start_date",
+	"end_date":     "COALESCE(end_date, '9999-12-31'::timestamp)",
+}
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ParseSort parses comma separated list of fields, where leading '-' means descending order,
+// e.g. "price,-start_date"
+func ParseSort(s string) ([]SortField, error) {
+	var sorts []SortField
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		sort := SortField{Field: part}
+		if strings.HasPrefix(part, "-") {
+			sort = SortField{Field: part[1:], Desc: true}
+		}
+
+		if _, ok := sortColumns[sort.Field]; !ok {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidSort, sort.Field)
+		}
+		if seen[sort.Field] {
+			return nil, fmt.Errorf("%w: duplicate field %q", ErrInvalidSort, sort.Field)
+		}
+		seen[sort.Field] = true
+
+		sorts = append(sorts, sort)
+	}
+
+	return sorts, nil
+}
+
+func (s SortField) String() string {
+	if s.Desc {
+		return "-" + s.Field
+	}
+	return s.Field
+}
+
+// normalizeSort validates sort and appends id as tiebreaker, so order is always total
+func normalizeSort(sorts []SortField) ([]SortField, error) {
+	normalized := make([]SortField, 0, len(sorts)+1)
+	hasId := false
+
+	for _, s := range sorts {
+		if _, ok := sortColumns[s.Field]; !ok {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidSort, s.Field)
+		}
+		normalized = append(normalized, s)
+		if s.Field == "id" {
+			hasId = true
+			break
+		}
+	}
+
+	if !hasId {
+		normalized = append(normalized, SortField{Field: "id"})
+	}
+
+	return normalized, nil
+}
+
+func sortKey(sorts []SortField) string {
+	parts := make([]string, len(sorts))
+	for i, s := range sorts {
+		parts[i] = s.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+func orderByClause(sorts []SortField) string {
+	parts := make([]string, len(sorts))
+	for i, s := range sorts {
+		dir := "ASC"
+		if s.Desc {
+			dir = "DESC"
+		}
+		parts[i] = fmt.Sprintf("%s %s", sortColumns[s.Field], dir)
+	}
+	return "ORDER BY " + strings.Join(parts, ", ")
+}
+
+// sortValue returns value of subscription field used for ordering
+func sortValue(sub *Subscription, field string) (any, error) {
+	v, err := fieldValue(sub, field)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return noEndDate, nil
+	}
+	return v, nil
+}
+
+func formatSortValue(v any) string {
+	switch v := v.(type) {
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+func parseSortValue(field, s string) (any, error) {
+	switch field {
+	case "id", "user_id":
+		return strconv.Atoi(s)
+	case "price":
+		return strconv.ParseInt(s, 10, 64)
+	case "start_date", "end_date":
+		return time.Parse(time.RFC3339Nano, s)
+	}
+	return s, nil
+}
+
+// compareBySort compares two rows by sort values taking direction into account
+func compareBySort(sorts []SortField, a, b []any) int {
+	for i, s := range sorts {
+		res, _ := compareValues(a[i], b[i])
+		if res == 0 {
+			continue
+		}
+		if s.Desc {
+			return -res
+		}
+		return res
+	}
+	return 0
+}
+
+// compareValues compares column value with filter value, ok is false when values are not comparable
+func compareValues(column, value any) (result int, ok bool) {
+	switch c := column.(type) {
+	case int:
+		switch v := value.(type) {
+		case int:
+			return cmp.Compare(c, v), true
+		case int64:
+			return cmp.Compare(int64(c), v), true
+		case string:
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return 0, false
+			}
+			return cmp.Compare(c, n), true
+		}
+	case int64:
+		switch v := value.(type) {
+		case int64:
+			return cmp.Compare(c, v), true
+		case int:
+			return cmp.Compare(c, int64(v)), true
+		case string:
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return 0, false
+			}
+			return cmp.Compare(c, n), true
+		}
+	case string:
+		return strings.Compare(c, fmt.Sprint(value)), true
+	case time.Time:
+		if v, isTime := value.(time.Time); isTime {
+			return c.Compare(v), true
+		}
+	}
+
+	return 0, false
+}
+
+// addKeyset adds condition selecting rows which follow cursor values in sort order
+func (b *queryBuilder) addKeyset(sorts []SortField, values []any) {
+	var groups []string
+
+	for i, s := range sorts {
+		var conds []string
+		for j := 0; j < i; j++ {
+			conds = append(conds, fmt.Sprintf("%s = %s", sortColumns[sorts[j].Field], b.arg(values[j])))
+		}
+
+		op := ">"
+		if s.Desc {
+			op = "<"
+		}
+		conds = append(conds, fmt.Sprintf("%s %s %s", sortColumns[s.Field], op, b.arg(values[i])))
+
+		groups = append(groups, "("+strings.Join(conds, " AND ")+")")
+	}
+
+	b.raw("(" + strings.Join(groups, " OR ") + ")")
+}
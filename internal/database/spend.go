@@ -0,0 +1,326 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// spendGroups are fields spend can be grouped by
+var spendGroups = []string{"user_id", "service_name", "month"}
+
+// SpendOptions controls grouping, ordering and size of spend report
+type SpendOptions struct {
+	GroupBy   []string
+	Sort      []SortField
+	Limit     int
+	Proration Proration
+	// Rounding is applied to amount of every subscription in every month, RoundHalfUp by default
+	Rounding Rounding
+}
+
+// SpendRow is spend of one group, fields which report isn't grouped by are omitted.
+// Spend is always grouped by currency, so totals in different currencies are never mixed.
+// Total is in minor units of the currency.
+type SpendRow struct {
+	UserId        int    `json:"user_id,omitempty"`
+	ServiceName   string `json:"service_name,omitempty"`
+	Month         string `json:"month,omitempty"`
+	Currency      string `json:"currency"`
+	Subscriptions int    `json:"subscriptions"`
+	Total         Money  `json:"total"`
+
+	month time.Time
+}
+
+type SpendReport struct {
+	PeriodStart string     `json:"period_start"`
+	PeriodEnd   string     `json:"period_end"`
+	Proration   Proration  `json:"proration"`
+	Rounding    Rounding   `json:"rounding"`
+	GroupBy     []string   `json:"group_by"`
+	Data        []SpendRow `json:"data"`
+}
+
+// ParseSpendGroups parses comma separated list of user_id, service_name and month
+func ParseSpendGroups(s string) ([]string, error) {
+	return parseGroups(s, spendGroups)
+}
+
+// parseGroups parses comma separated list of unique groups out of allowed ones
+func parseGroups(s string, allowed []string) ([]string, error) {
+	groups := []string{}
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !slices.Contains(allowed, part) {
+			last := len(allowed) - 1
+			return nil, fmt.Errorf("%w: unknown group %q, expected %s or %s", ErrInvalidFilter, part, strings.Join(allowed[:last], ", "), allowed[last])
+		}
+		if slices.Contains(groups, part) {
+			return nil, fmt.Errorf("%w: duplicate group %q", ErrInvalidFilter, part)
+		}
+		groups = append(groups, part)
+	}
+
+	return groups, nil
+}
+
+// ParseSpendSort parses sort of spend report, which may use total, subscriptions and grouped fields.
+// Report is sorted by total descending by default.
+func ParseSpendSort(s string, groups []string) ([]SortField, error) {
+	sorts := []SortField{}
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		sort := SortField{Field: part}
+		if strings.HasPrefix(part, "-") {
+			sort = SortField{Field: part[1:], Desc: true}
+		}
+
+		if sort.Field != "total" && sort.Field != "subscriptions" && sort.Field != "currency" && !slices.Contains(groups, sort.Field) {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidSort, sort.Field)
+		}
+		if slices.ContainsFunc(sorts, func(f SortField) bool { return f.Field == sort.Field }) {
+			return nil, fmt.Errorf("%w: duplicate field %q", ErrInvalidSort, sort.Field)
+		}
+
+		sorts = append(sorts, sort)
+	}
+
+	if len(sorts) == 0 {
+		sorts = append(sorts, SortField{Field: "total", Desc: true})
+	}
+
+	// groups are unique, so ordering by them as well makes result stable
+	for _, g := range append(slices.Clone(groups), "currency") {
+		if !slices.ContainsFunc(sorts, func(f SortField) bool { return f.Field == g }) {
+			sorts = append(sorts, SortField{Field: g})
+		}
+	}
+
+	return sorts, nil
+}
+
+func newSpendReport(start, end time.Time, opts SpendOptions) *SpendReport {
+	if opts.Rounding == "" {
+		opts.Rounding = RoundHalfUp
+	}
+
+	return &SpendReport{
+		PeriodStart: formatDate(start, false),
+		PeriodEnd:   formatDate(end, true),
+		Proration:   opts.Proration,
+		Rounding:    opts.Rounding,
+		GroupBy:     opts.GroupBy,
+		Data:        []SpendRow{},
+	}
+}
+
+// spendShareSQL returns SQL expressions of days billed from from_day till to_day and days in month, same as Proration.Breakdown.
+// Share is kept as fraction, so price is multiplied by exact number of days and divided once.
+func spendShareSQL(p Proration) (string, string) {
+	switch p {
+	case ProrationDaily:
+		return "(to_day - from_day + 1)", "extract(day from month_end)"
+	case Proration30360:
+		return `((extract(year from next_day) - extract(year from from_day)) * 360
+			+ (extract(month from next_day) - extract(month from from_day)) * 30
+			+ CASE WHEN extract(day from next_day) = 31 AND extract(day from from_day) >= 30 THEN 30 ELSE extract(day from next_day) END
+			- LEAST(extract(day from from_day), 30))`, "30"
+	}
+	return "1", "1"
+}
+
+// spendQuery computes spend of subscriptions matching {where} within period from {start} till {end}.
+// Monthly billing is prorated within every month, where {days} of {month_days} are billed and month split by pause
+// is billed once with {share_agg} of its parts. Other billing periods are charged in full on every billing date,
+// number of billing dates is limited by the shortest length of billing unit.
+// Result is {columns} of priced shares, caller adds GROUP BY and ORDER BY.
+const spendQuery = `WITH subs AS (
+	SELECT id, user_id, service_name, price, currency, start_date, {billed_end} AS end_date, billing_unit, billing_count FROM subscription {where}
+), active AS (
+	SELECT s.*, b.active_from,
+		LEAST(COALESCE(s.end_date, {end}), {end}, COALESCE(
+			(SELECT MIN(p.pause_from) - 1 FROM subscription_pauses AS p WHERE p.subscription_id = s.id AND p.pause_from >= b.active_from),
+			'infinity')) AS active_to
+	FROM subs AS s, LATERAL (
+		SELECT s.start_date::date AS active_from
+		UNION ALL
+		SELECT pause_until + 1 FROM subscription_pauses WHERE subscription_id = s.id AND pause_until IS NOT NULL
+	) AS b
+	WHERE NOT EXISTS (
+		SELECT 1 FROM subscription_pauses AS p
+		WHERE p.subscription_id = s.id AND b.active_from >= p.pause_from AND (p.pause_until IS NULL OR b.active_from <= p.pause_until)
+	)
+), months AS (
+	SELECT id, user_id, service_name, price, currency, month,
+		(month + interval '1 month' - interval '1 day')::date AS month_end,
+		GREATEST(active_from, {start}, month)::date AS from_day,
+		LEAST(active_to, month + interval '1 month' - interval '1 day')::date AS to_day
+	FROM active, generate_series(date_trunc('month', GREATEST(active_from, {start})), active_to, interval '1 month') AS month
+	WHERE billing_unit = 'month' AND billing_count = 1 AND GREATEST(active_from, {start}) <= active_to
+), charges AS (
+	SELECT id, user_id, service_name, price, currency, end_date, start_date + n * (billing_count || ' ' || billing_unit)::interval AS charge_date
+	FROM subs, generate_series(0, (LEAST(COALESCE(end_date, {end}), {end})::date - start_date::date)
+		/ (billing_count * CASE billing_unit WHEN 'day' THEN 1 WHEN 'week' THEN 7 WHEN 'month' THEN 28 ELSE 365 END)) AS n
+	WHERE NOT (billing_unit = 'month' AND billing_count = 1)
+), shares AS (
+	SELECT id, user_id, service_name, price, currency, month, MIN(from_day) AS day, {share_agg}({days})::numeric AS periods, MAX({month_days})::numeric AS month_days
+	FROM (SELECT *, to_day + 1 AS next_day FROM months) AS m
+	GROUP BY id, user_id, service_name, price, currency, month
+	UNION ALL
+	SELECT id, user_id, service_name, price, currency, date_trunc('month', charge_date) AS month, charge_date::date AS day, 1 AS periods, 1 AS month_days
+	FROM charges AS c
+	WHERE charge_date >= {start} AND charge_date <= LEAST(COALESCE(end_date, {end}), {end}) AND NOT EXISTS (
+		SELECT 1 FROM subscription_pauses AS p
+		WHERE p.subscription_id = c.id AND c.charge_date::date >= p.pause_from AND (p.pause_until IS NULL OR c.charge_date::date <= p.pause_until)
+	)
+), priced AS (
+	SELECT s.id, s.user_id, s.service_name, s.month, s.periods, s.month_days,
+		COALESCE(h.price, s.price) AS price, COALESCE(h.currency, s.currency) AS currency
+	FROM shares AS s LEFT JOIN LATERAL (
+		SELECT price, currency FROM subscription_price_history
+		WHERE subscription_id = s.id
+		ORDER BY effective_from <= s.day DESC, CASE WHEN effective_from <= s.day THEN effective_from END DESC, effective_from
+		LIMIT 1
+	) AS h ON true
+)
+SELECT {columns} FROM priced`
+
+// GetSpend groups cost of filtered subscriptions within period, months are expanded with generate_series.
+// Every month is billed with price valid at its first billed day, paused days are excluded and deleted subscriptions
+// are billed until deletion the same way as in GetPrice.
+func (m *SubscriptionModel) GetSpend(startPeriod, endPeriod time.Time, filter *Filter, opts SpendOptions) (*SpendReport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var qb queryBuilder
+	start := qb.arg(startPeriod)
+	end := qb.arg(endPeriod)
+	qb.raw(fmt.Sprintf("start_date <= %s", end))
+	qb.raw(fmt.Sprintf("(%s >= %s OR %s IS NULL)", billedEndSQL, start, billedEndSQL))
+	if err := qb.addFilter(filter); err != nil {
+		slog.Error("ERROR in Subscription GetSpend", "error", err)
+		return nil, err
+	}
+
+	groupBy := append(slices.Clone(opts.GroupBy), "currency")
+	columns := append(slices.Clone(groupBy), "COUNT(DISTINCT id) AS subscriptions", "COALESCE(SUM("+opts.Rounding.sql("price * periods / month_days")+"), 0)::bigint AS total")
+
+	days, monthDays := spendShareSQL(opts.Proration)
+	shareAgg := "MAX"
+	if opts.Proration == ProrationDaily || opts.Proration == Proration30360 {
+		shareAgg = "SUM"
+	}
+
+	query := strings.NewReplacer(
+		"{where}", qb.whereClause(),
+		"{start}", start,
+		"{end}", end,
+		"{billed_end}", billedEndSQL,
+		"{days}", days,
+		"{month_days}", monthDays,
+		"{share_agg}", shareAgg,
+		"{columns}", strings.Join(columns, ", "),
+	).Replace(spendQuery)
+
+	order := make([]string, len(opts.Sort))
+	for i, s := range opts.Sort {
+		order[i] = s.Field
+		if s.Desc {
+			order[i] += " DESC"
+		}
+	}
+	query += " GROUP BY " + strings.Join(groupBy, ", ") + " ORDER BY " + strings.Join(order, ", ")
+
+	if opts.Limit > 0 {
+		query += " LIMIT " + qb.arg(opts.Limit)
+	}
+
+	rows, err := m.DB.Query(ctx, query, qb.args...)
+	if err != nil {
+		slog.Error("ERROR in Subscription GetSpend", "error", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	report := newSpendReport(startPeriod, endPeriod, opts)
+
+	for rows.Next() {
+		var row SpendRow
+
+		dest := []any{}
+		for _, g := range opts.GroupBy {
+			switch g {
+			case "user_id":
+				dest = append(dest, &row.UserId)
+			case "service_name":
+				dest = append(dest, &row.ServiceName)
+			case "month":
+				dest = append(dest, &row.month)
+			}
+		}
+		dest = append(dest, &row.Currency, &row.Subscriptions, &row.Total.Amount)
+
+		if err := rows.Scan(dest...); err != nil {
+			slog.Error("ERROR in Subscription GetSpend", "error", err)
+			return nil, err
+		}
+
+		row.Total.Currency = row.Currency
+		if !row.month.IsZero() {
+			row.Month = row.month.Format(monthLayout)
+		}
+		report.Data = append(report.Data, row)
+	}
+
+	if err = rows.Err(); err != nil {
+		slog.Error("ERROR in Subscription GetSpend", "error", err)
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// compareSpendRows compares rows by sort fields taking direction into account
+func compareSpendRows(sorts []SortField, a, b *SpendRow) int {
+	for _, s := range sorts {
+		var res int
+		switch s.Field {
+		case "total":
+			res = cmp.Compare(a.Total.Amount, b.Total.Amount)
+		case "subscriptions":
+			res = cmp.Compare(a.Subscriptions, b.Subscriptions)
+		case "user_id":
+			res = cmp.Compare(a.UserId, b.UserId)
+		case "service_name":
+			res = cmp.Compare(a.ServiceName, b.ServiceName)
+		case "month":
+			res = a.month.Compare(b.month)
+		case "currency":
+			res = cmp.Compare(a.Currency, b.Currency)
+		}
+
+		if res == 0 {
+			continue
+		}
+		if s.Desc {
+			return -res
+		}
+		return res
+	}
+	return 0
+}
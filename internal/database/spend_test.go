@@ -0,0 +1,77 @@
+package database
+
+import "testing"
+
+// TestSpendMatchesPrice checks that spend report adds up to the same total as period price
+func TestSpendMatchesPrice(t *testing.T) {
+	m := seedMemory(t)
+
+	// weekly subscription, price change in the middle of period, pause and deleted subscription
+	weekly := Subscription{ServiceName: "Gym", Price: 99900, UserId: 2, StartDate: "10-02-2025", EndDate: "20-09-2025", BillingPeriod: BillingPeriod{Unit: BillingWeek, Count: 2}}
+	deleted := Subscription{ServiceName: "Kinopoisk", Price: 29900, UserId: 3, StartDate: "15-01-2025"}
+	for _, sub := range []*Subscription{&weekly, &deleted} {
+		if err := m.Insert(sub); err != nil {
+			t.Fatalf("Insert(%s) = %v", sub.ServiceName, err)
+		}
+	}
+	if _, err := m.SchedulePriceChange(3, day("15-08-2025"), Money{Amount: 27500, Currency: "RUB"}); err != nil {
+		t.Fatalf("SchedulePriceChange() = %v", err)
+	}
+	until := day("09-09-2025")
+	if _, err := m.Pause(1, day("20-08-2025"), &until); err != nil {
+		t.Fatalf("Pause() = %v", err)
+	}
+	if err := m.Delete(deleted.Id, 0); err != nil {
+		t.Fatalf("Delete() = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		start, end string
+		filter     *Filter
+		proration  Proration
+		rounding   Rounding
+		groupBy    []string
+	}{
+		{name: "monthly", start: "01-01-2025", end: "31-12-2025", proration: ProrationMonthly},
+		{name: "daily", start: "01-01-2025", end: "31-12-2025", proration: ProrationDaily},
+		{name: "30/360", start: "01-01-2025", end: "31-12-2025", proration: Proration30360},
+		{name: "daily partial period", start: "17-03-2025", end: "11-09-2025", proration: ProrationDaily},
+		{name: "daily rounded down", start: "17-03-2025", end: "11-09-2025", proration: ProrationDaily, rounding: RoundDown},
+		{name: "30/360 rounded up", start: "17-03-2025", end: "11-09-2025", proration: Proration30360, rounding: RoundUp},
+		{name: "grouped by month", start: "01-01-2025", end: "31-12-2025", proration: ProrationDaily, groupBy: []string{"month"}},
+		{name: "grouped by user and service", start: "01-06-2025", end: "30-11-2025", proration: ProrationMonthly, groupBy: []string{"user_id", "service_name"}},
+		{name: "filtered by user", start: "01-01-2025", end: "31-12-2025", proration: ProrationDaily, filter: NewFilter().Eq("user_id", 1)},
+		{name: "filtered by price", start: "01-01-2025", end: "31-12-2025", proration: Proration30360, filter: NewFilter().Gte("price", 25000)},
+		{name: "nothing active", start: "01-01-2024", end: "31-12-2024", proration: ProrationMonthly},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price, err := m.GetPrice(day(tt.start), day(tt.end), tt.filter, PriceOptions{Proration: tt.proration, Rounding: tt.rounding})
+			if err != nil {
+				t.Fatalf("GetPrice() = %v", err)
+			}
+
+			sorts, err := ParseSpendSort("", tt.groupBy)
+			if err != nil {
+				t.Fatalf("ParseSpendSort() = %v", err)
+			}
+			spend, err := m.GetSpend(day(tt.start), day(tt.end), tt.filter, SpendOptions{GroupBy: tt.groupBy, Sort: sorts, Proration: tt.proration, Rounding: tt.rounding})
+			if err != nil {
+				t.Fatalf("GetSpend() = %v", err)
+			}
+
+			var total int64
+			for _, row := range spend.Data {
+				if row.Currency != price.Currency {
+					t.Fatalf("GetSpend() row in %s, price report is in %s", row.Currency, price.Currency)
+				}
+				total += row.Total.Amount
+			}
+			if total != price.Total.Amount {
+				t.Errorf("GetSpend() total = %d, GetPrice() total = %d", total, price.Total.Amount)
+			}
+		})
+	}
+}
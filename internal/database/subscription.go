@@ -2,75 +2,313 @@ package database
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+var (
+	// ErrEditConflict is returned when subscription was modified since expected version was read
+	ErrEditConflict = errors.New("edit conflict")
+	// ErrRecordNotFound is returned when modified subscription doesn't exist
+	ErrRecordNotFound = errors.New("record not found")
+)
+
+// querier is implemented by both pgxpool.Pool and pgx.Tx
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func (m *SubscriptionModel) withTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return withTx(ctx, m.DB, fn)
+}
+
+// withTx runs fn in transaction which is committed when fn succeeds
+func withTx(ctx context.Context, db *pgxpool.Pool, fn func(tx pgx.Tx) error) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// readSnapshot runs fn in read only transaction, so every query of fn sees the same snapshot of data
+func (m *SubscriptionModel) readSnapshot(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	tx, err := m.DB.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	return fn(tx)
+}
+
+// subscriptionSelect is list of columns scanned by scanSubscription
+const subscriptionSelect = "id, service_name, price, currency, user_id, start_date, end_date, billing_unit, billing_count, version, deleted_at, service_id, plan_id"
+
+// billedEndSQL is last billed day of subscription, deleted subscription is billed until the day it was deleted
+const billedEndSQL = "LEAST(end_date, (deleted_at AT TIME ZONE 'UTC')::date)"
+
 type SubscriptionModel struct {
-	DB *pgx.Conn
+	DB *pgxpool.Pool
+
+	actor Actor
+}
+
+// As returns model which records changes in audit log as made by actor
+func (m *SubscriptionModel) As(actor Actor) SubscriptionRepository {
+	return &SubscriptionModel{DB: m.DB, actor: actor}
 }
 
 type Subscription struct {
-	Id          int    `json:"id"`
-	ServiceName string `json:"service_name" binding:"required"`
-	Price       int    `json:"price" binding:"required"`
-	UserId      int    `json:"user_id" binding:"required"`
-	StartDate   string `json:"start_date" binding:"required,datetime=02-2006"`
-	EndDate     string `json:"end_date" binding:"datetime=02-2006|len=0"`
-}
-
-func parseGetDate(start, end string) (parsedStart *string, parsedEnd *string, err error) {
-	endDate := "NULL"
-	if end != "" {
-		endTime, err := time.Parse("01-2006", end)
-		if err != nil {
-			slog.Error("ERROR in Subscription parseGetDate", "error", err)
-			return nil, nil, err
-		}
+	Id int `json:"id"`
+	// ServiceId references service catalog, service may be given by id or by name or alias instead.
+	// ServiceName is always replaced with name of the service, service with unknown name is created.
+	ServiceId   int    `json:"service_id" binding:"omitempty,min=1"`
+	ServiceName string `json:"service_name" binding:"required_without_all=ServiceId PlanId,max=255"`
+	// PlanId references plan of the service, subscription on plan gets its service and billing period
+	// and its price when price is omitted
+	PlanId int `json:"plan_id,omitempty" binding:"omitempty,min=1"`
+	// Price is in minor units of Currency, in JSON it is decimal string like "399.99", number is accepted as well.
+	// It may be omitted when service given by id has default price or plan is given.
+	Price int64 `json:"price" binding:"required_without_all=ServiceId PlanId" swaggertype:"string" example:"399.99"`
+	// Currency is ISO 4217 code of price, BaseCurrency by default
+	Currency  string `json:"currency" binding:"omitempty,iso4217"`
+	UserId    int    `json:"user_id" binding:"required"`
+	StartDate string `json:"start_date" binding:"required,datetime=02-2006|datetime=02-01-2006"`
+	EndDate   string `json:"end_date" binding:"datetime=02-2006|datetime=02-01-2006|len=0"`
+	// Price is charged once per BillingPeriod
+	BillingPeriod BillingPeriod `json:"billing_period"`
+	Version       int           `json:"version"`
+	// DeletedAt is set when subscription is deleted, deleted subscriptions are listed only on request
+	DeletedAt *time.Time `json:"deleted_at,omitempty" binding:"-"`
+	// ConvertedPrice is filled only in list responses, when other currency is requested, and is never stored
+	ConvertedPrice *Money `json:"converted_price,omitempty" binding:"-"`
+	// Status, Pauses and Cancellation are filled only when single subscription is read
+	Status       SubscriptionStatus `json:"status,omitempty" binding:"-"`
+	Pauses       []Pause            `json:"pauses,omitempty" binding:"-"`
+	Cancellation *Cancellation      `json:"cancellation,omitempty" binding:"-"`
+}
+
+// subscriptionJSON is Subscription with price as decimal string
+type subscriptionJSON struct {
+	*plainSubscription
+	Price json.RawMessage `json:"price,omitempty"`
+}
+
+type plainSubscription Subscription
+
+func (sub Subscription) MarshalJSON() ([]byte, error) {
+	price, _ := json.Marshal(FormatAmount(sub.Price, sub.Currency))
+
+	return json.Marshal(subscriptionJSON{plainSubscription: (*plainSubscription)(&sub), Price: price})
+}
+
+// UnmarshalJSON reads price in minor units of currency given in the same object, zero and negative prices are rejected
+func (sub *Subscription) UnmarshalJSON(data []byte) error {
+	aux := subscriptionJSON{plainSubscription: (*plainSubscription)(sub)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
 
-		endDate = fmt.Sprintf("'%s'", endTime.Format("2006-01-02"))
+	if len(aux.Price) == 0 || string(aux.Price) == "null" {
+		return nil
+	}
+
+	price, err := parseJSONAmount(aux.Price, sub.Currency)
+	if err != nil {
+		return err
+	}
+	if price <= 0 {
+		return fmt.Errorf("%w %s, price must be positive", ErrInvalidAmount, aux.Price)
 	}
+	sub.Price = price
+
+	return nil
+}
+
+func scanSubscription(row pgx.Row) (*Subscription, error) {
+	var sub Subscription
+	var startTime time.Time
+	var endTime *time.Time
+	var planId *int
 
-	startTime, err := time.Parse("01-2006", start)
+	err := row.Scan(&sub.Id, &sub.ServiceName, &sub.Price, &sub.Currency, &sub.UserId, &startTime, &endTime, &sub.BillingPeriod.Unit, &sub.BillingPeriod.Count, &sub.Version, &sub.DeletedAt, &sub.ServiceId, &planId)
 	if err != nil {
-		slog.Error("ERROR in Subscription parseGetDate", "error", err)
-		return nil, nil, err
+		return nil, err
+	}
+	if planId != nil {
+		sub.PlanId = *planId
+	}
+
+	sub.StartDate = formatDate(startTime, false)
+	if endTime != nil {
+		sub.EndDate = formatDate(*endTime, true)
+	}
+
+	return &sub, nil
+}
+
+// normalize brings dates and billing period to the form they are read from database, invalid dates are left as is.
+// Fields which are never stored are dropped.
+func (sub *Subscription) normalize() {
+	sub.ConvertedPrice, sub.Status, sub.Pauses, sub.Cancellation, sub.DeletedAt = nil, "", nil, nil, nil
+	sub.BillingPeriod = sub.BillingPeriod.normalize()
+	if sub.Currency == "" {
+		sub.Currency = BaseCurrency
 	}
-	startDate := fmt.Sprintf("'%s'", startTime.Format("2006-01-02"))
 
-	return &startDate, &endDate, nil
+	startDate, endDate, err := ParseDates(sub.StartDate, sub.EndDate)
+	if err != nil {
+		return
+	}
+	sub.StartDate = formatDate(startDate, false)
+	if endDate != nil {
+		sub.EndDate = formatDate(*endDate, true)
+	}
 }
 
 func (m *SubscriptionModel) Insert(sub *Subscription) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	startDate, endDate, err := parseGetDate(sub.StartDate, sub.EndDate)
+	return m.withTx(ctx, func(tx pgx.Tx) error {
+		return insertSubscription(ctx, tx, m.actor, sub)
+	})
+}
+
+func insertSubscription(ctx context.Context, q querier, actor Actor, sub *Subscription) error {
+	startDate, endDate, err := ParseDates(sub.StartDate, sub.EndDate)
 	if err != nil {
 		slog.Error("ERROR in Subscription Insert", "error", err)
 		return err
 	}
 
-	query := fmt.Sprintf("INSERT INTO subscription (service_name, price, user_id, start_date, end_date) VALUES ($1,$2,$3,%s,%s) RETURNING id", *startDate, *endDate)
+	if err := resolveService(ctx, q, sub); err != nil {
+		return err
+	}
+	sub.normalize()
+
+	query := "INSERT INTO subscription (service_name, service_id, plan_id, price, currency, user_id, start_date, end_date, billing_unit, billing_count) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) RETURNING id, version"
+
+	err = q.QueryRow(ctx, query, sub.ServiceName, sub.ServiceId, sub.planArg(), sub.Price, sub.Currency, sub.UserId, startDate, endDate, sub.BillingPeriod.Unit, sub.BillingPeriod.Count).Scan(&sub.Id, &sub.Version)
+	if err != nil {
+		return err
+	}
 
-	return m.DB.QueryRow(ctx, query, sub.ServiceName, sub.Price, sub.UserId).Scan(&sub.Id)
+	return writeAudit(ctx, q, actor, AuditCreate, sub.Id, auditChanges(nil, sub))
+}
+
+// InsertMany stores subscriptions with COPY and fills their ids and versions, every subscription is recorded in audit log
+func (m *SubscriptionModel) InsertMany(subs []*Subscription) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	dates := make([][2]any, len(subs))
+	for i, sub := range subs {
+		startDate, endDate, err := ParseDates(sub.StartDate, sub.EndDate)
+		if err != nil {
+			slog.Error("ERROR in Subscription InsertMany", "error", err)
+			return err
+		}
+		dates[i] = [2]any{startDate, endDate}
+	}
+
+	err := m.withTx(ctx, func(tx pgx.Tx) error {
+		services := make(map[string]*Service)
+		// ids are reserved before COPY, so every input row gets its id regardless of order in which rows are stored
+		idRows, err := tx.Query(ctx, "SELECT nextval(pg_get_serial_sequence('subscription', 'id')) FROM generate_series(1, $1)", len(subs))
+		if err != nil {
+			return err
+		}
+		ids, err := pgx.CollectRows(idRows, pgx.RowTo[int])
+		if err != nil {
+			return err
+		}
+
+		rows := make([][]any, 0, len(subs))
+		for i, sub := range subs {
+			if err := applyPlan(ctx, tx, sub); err != nil {
+				return err
+			}
+
+			ref := serviceRef(sub)
+			s, ok := services[ref]
+			if !ok {
+				var err error
+				if s, err = findService(ctx, tx, sub); err != nil {
+					return err
+				}
+				services[ref] = s
+			}
+			if err := s.fill(sub); err != nil {
+				return err
+			}
+
+			sub.normalize()
+			rows = append(rows, []any{ids[i], sub.ServiceName, sub.ServiceId, sub.planArg(), sub.Price, sub.Currency, sub.UserId, dates[i][0], dates[i][1], string(sub.BillingPeriod.Unit), sub.BillingPeriod.Count})
+		}
+
+		_, err = tx.CopyFrom(ctx,
+			pgx.Identifier{"subscription"},
+			[]string{"id", "service_name", "service_id", "plan_id", "price", "currency", "user_id", "start_date", "end_date", "billing_unit", "billing_count"},
+			pgx.CopyFromRows(rows),
+		)
+		if err != nil {
+			return err
+		}
+
+		audit := make([][]any, len(subs))
+		for i, sub := range subs {
+			sub.Id, sub.Version = ids[i], 1
+
+			changes, err := json.Marshal(auditChanges(nil, sub))
+			if err != nil {
+				return err
+			}
+			audit[i] = []any{sub.Id, string(AuditCreate), m.actor.name(), m.actor.RequestId, changes}
+		}
+
+		_, err = tx.CopyFrom(ctx,
+			pgx.Identifier{"subscription_audit"},
+			[]string{"subscription_id", "action", "actor", "request_id", "changes"},
+			pgx.CopyFromRows(audit),
+		)
+		return err
+	})
+	if err != nil {
+		slog.Error("ERROR in Subscription InsertMany", "error", err)
+		return err
+	}
+
+	return nil
 }
 
 func (m *SubscriptionModel) Get(id int) (*Subscription, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := "SELECT * FROM subscription WHERE id = $1"
-
-	var sub Subscription
-	var startTime time.Time
-	var endTime any
+	query := fmt.Sprintf("SELECT %s FROM subscription WHERE id = $1 AND deleted_at IS NULL", subscriptionSelect)
 
-	err := m.DB.QueryRow(ctx, query, id).Scan(&sub.Id, &sub.ServiceName, &sub.Price, &sub.UserId, &startTime, &endTime)
+	sub, err := scanSubscription(m.DB.QueryRow(ctx, query, id))
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil
@@ -79,70 +317,298 @@ func (m *SubscriptionModel) Get(id int) (*Subscription, error) {
 		return nil, err
 	}
 
-	sub.StartDate = startTime.Format("01-2006")
+	if err := loadState(ctx, m.DB, sub); err != nil {
+		slog.Error("ERROR in Subscription Get", "error", err)
+		return nil, err
+	}
+	sub.setStatus(Today())
+
+	return sub, nil
+}
+
+// editError explains why conditional modification of subscription affected no rows
+func editError(ctx context.Context, q querier, id int) error {
+	var exists bool
+	if err := q.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM subscription WHERE id = $1 AND deleted_at IS NULL)", id).Scan(&exists); err != nil {
+		return err
+	}
 
-	t, ok := endTime.(time.Time)
-	if ok {
-		sub.EndDate = t.Format("01-2006")
+	if !exists {
+		return ErrRecordNotFound
 	}
 
-	return &sub, nil
+	return ErrEditConflict
 }
 
+// logEditError logs errors of subscription modification except not found subscription and version conflict
+func logEditError(method string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	for _, expected := range []error{ErrRecordNotFound, ErrEditConflict, ErrServiceNotFound, ErrPriceRequired, ErrPlanNotFound, ErrPlanMismatch, ErrCancelledEndDate} {
+		if errors.Is(err, expected) {
+			return err
+		}
+	}
+
+	slog.Error("ERROR in Subscription "+method, "error", err)
+	return err
+}
+
+// Update replaces subscription and increments its version.
+// When sub.Version is not zero, stored version must match it, otherwise ErrEditConflict is returned.
+// End date of cancelled subscription can't be changed, ErrCancelledEndDate is returned.
 func (m *SubscriptionModel) Update(sub *Subscription) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	startDate, endDate, err := parseGetDate(sub.StartDate, sub.EndDate)
+	return m.withTx(ctx, func(tx pgx.Tx) error {
+		return updateSubscription(ctx, tx, m.actor, sub)
+	})
+}
+
+func updateSubscription(ctx context.Context, q querier, actor Actor, sub *Subscription) error {
+	startDate, endDate, err := ParseDates(sub.StartDate, sub.EndDate)
 	if err != nil {
 		slog.Error("ERROR in Subscription Update", "error", err)
 		return err
 	}
 
-	query := fmt.Sprintf("UPDATE subscription SET service_name = $1, price = $2, user_id = $3, start_date = %s, end_date = %s WHERE id = $4", *startDate, *endDate)
+	err = changeSubscription(ctx, q, actor, AuditUpdate, sub.Id, func(stored *Subscription) error {
+		if err := checkEndDate(stored, sub); err != nil {
+			return err
+		}
+		if err := resolveService(ctx, q, sub); err != nil {
+			return err
+		}
+		sub.normalize()
 
-	_, err = m.DB.Exec(ctx, query, sub.ServiceName, sub.Price, sub.UserId, sub.Id)
-	if err != nil {
-		slog.Error("ERROR in Subscription Update", "error", err)
-		return err
+		if err := ensurePriceHistory(ctx, q, sub.Id); err != nil {
+			return err
+		}
+
+		query := `UPDATE subscription
+				SET service_name = $1, service_id = $2, plan_id = $3, price = $4, currency = $5, user_id = $6, start_date = $7, end_date = $8, billing_unit = $9, billing_count = $10, version = version + 1
+				WHERE id = $11 AND deleted_at IS NULL AND ($12 = 0 OR version = $12)
+				RETURNING version`
+
+		err := q.QueryRow(ctx, query, sub.ServiceName, sub.ServiceId, sub.planArg(), sub.Price, sub.Currency, sub.UserId, startDate, endDate, sub.BillingPeriod.Unit, sub.BillingPeriod.Count, sub.Id, sub.Version).Scan(&sub.Version)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return editError(ctx, q, sub.Id)
+			}
+			return err
+		}
+
+		// price is changed from today, so past months keep being billed with previous price
+		return recordPrice(ctx, q, sub.Id, Today())
+	})
+
+	return logEditError("Update", err)
+}
+
+// ChangedFields returns columns which differ between subscriptions
+func ChangedFields(old, updated *Subscription) []string {
+	var fields []string
+
+	if old.ServiceName != updated.ServiceName || old.ServiceId != updated.ServiceId {
+		fields = append(fields, "service_name")
+	}
+	if old.PlanId != updated.PlanId {
+		fields = append(fields, "plan_id")
+	}
+	if old.Price != updated.Price {
+		fields = append(fields, "price")
+	}
+	if old.Currency != updated.Currency {
+		fields = append(fields, "currency")
+	}
+	if old.UserId != updated.UserId {
+		fields = append(fields, "user_id")
+	}
+	if old.StartDate != updated.StartDate {
+		fields = append(fields, "start_date")
+	}
+	if old.EndDate != updated.EndDate {
+		fields = append(fields, "end_date")
+	}
+	if old.BillingPeriod.normalize() != updated.BillingPeriod.normalize() {
+		fields = append(fields, "billing_period")
 	}
 
-	return nil
+	return fields
 }
 
-func (m *SubscriptionModel) Delete(id int) error {
+// UpdateFields updates only listed columns of subscription, version is checked the same way as in Update
+func (m *SubscriptionModel) UpdateFields(sub *Subscription, fields []string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	fields = planFields(fields)
+
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := "DELETE FROM subscription WHERE id = $1"
-
-	_, err := m.DB.Exec(ctx, query, id)
+	startDate, endDate, err := ParseDates(sub.StartDate, sub.EndDate)
 	if err != nil {
-		slog.Error("ERROR in Subscription Delete", "error", err)
+		slog.Error("ERROR in Subscription UpdateFields", "error", err)
 		return err
 	}
 
-	return nil
+	err = m.withTx(ctx, func(tx pgx.Tx) error {
+		return changeSubscription(ctx, tx, m.actor, AuditUpdate, sub.Id, func(stored *Subscription) error {
+			if slices.Contains(fields, "end_date") {
+				if err := checkEndDate(stored, sub); err != nil {
+					return err
+				}
+			}
+			if slices.Contains(fields, "service_name") {
+				if err := resolveService(ctx, tx, sub); err != nil {
+					return err
+				}
+			}
+			sub.normalize()
+
+			query, args, err := updateFieldsQuery(sub, fields, startDate, endDate)
+			if err != nil {
+				return err
+			}
+
+			if err := ensurePriceHistory(ctx, tx, sub.Id); err != nil {
+				return err
+			}
+
+			if err := tx.QueryRow(ctx, query, args...).Scan(&sub.Version); err != nil {
+				if err == pgx.ErrNoRows {
+					return editError(ctx, tx, sub.Id)
+				}
+				return err
+			}
+
+			return recordPrice(ctx, tx, sub.Id, Today())
+		})
+	})
+
+	return logEditError("UpdateFields", err)
+}
+
+// updateFieldsQuery builds UPDATE of listed columns, service_name updates service_id as well
+func updateFieldsQuery(sub *Subscription, fields []string, startDate time.Time, endDate *time.Time) (string, []any, error) {
+	values := map[string]any{
+		"price":      sub.Price,
+		"currency":   sub.Currency,
+		"user_id":    sub.UserId,
+		"start_date": startDate,
+		"end_date":   endDate,
+	}
+
+	var qb queryBuilder
+	sets := make([]string, 0, len(fields))
+	for _, f := range fields {
+		switch f {
+		case "billing_period":
+			sets = append(sets, fmt.Sprintf("billing_unit = %s, billing_count = %s", qb.arg(sub.BillingPeriod.Unit), qb.arg(sub.BillingPeriod.Count)))
+			continue
+		case "service_name":
+			sets = append(sets, fmt.Sprintf("service_name = %s, service_id = %s", qb.arg(sub.ServiceName), qb.arg(sub.ServiceId)))
+			continue
+		case "plan_id":
+			sets = append(sets, "plan_id = "+qb.arg(sub.planArg()))
+			continue
+		}
+
+		v, ok := values[f]
+		if !ok {
+			return "", nil, fmt.Errorf("unknown field %q", f)
+		}
+		sets = append(sets, fmt.Sprintf("%s = %s", f, qb.arg(v)))
+	}
+
+	sets = append(sets, "version = version + 1")
+	version := qb.arg(sub.Version)
+
+	query := fmt.Sprintf("UPDATE subscription SET %s WHERE id = %s AND deleted_at IS NULL AND (%s = 0 OR version = %s) RETURNING version",
+		strings.Join(sets, ", "), qb.arg(sub.Id), version, version)
+
+	return query, qb.args, nil
 }
 
-func (m *SubscriptionModel) GetList(filter map[string]string) ([]*Subscription, error) {
+// Delete marks subscription as deleted and increments its version, when version is not zero stored version must match it.
+// Deleted subscription is kept for reports until it is purged.
+func (m *SubscriptionModel) Delete(id int, version int) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	var filterSB strings.Builder
-	for k, v := range filter {
-		var str string
-		if filterSB.Len() == 0 {
-			str = fmt.Sprintf("WHERE %s = '%s'", k, v)
-		} else {
-			str = fmt.Sprintf("AND %s = '%s'", k, v)
+	return m.withTx(ctx, func(tx pgx.Tx) error {
+		return deleteSubscription(ctx, tx, m.actor, id, version)
+	})
+}
+
+func deleteSubscription(ctx context.Context, q querier, actor Actor, id int, version int) error {
+	err := changeSubscription(ctx, q, actor, AuditDelete, id, func(*Subscription) error {
+		query := "UPDATE subscription SET deleted_at = now(), version = version + 1 WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2)"
+
+		tag, err := q.Exec(ctx, query, id, version)
+		if err != nil {
+			return err
+		}
+
+		if tag.RowsAffected() == 0 {
+			return editError(ctx, q, id)
 		}
-		filterSB.WriteString(str)
+
+		return nil
+	})
+
+	return logEditError("Delete", err)
+}
+
+func (m *SubscriptionModel) GetList(filter *Filter, opts ListOptions) (*SubscriptionPage, error) {
+	if opts.AsOf != nil {
+		return m.getListAt(*opts.AsOf, filter, opts)
 	}
 
-	query := fmt.Sprintf("SELECT * FROM subscription %s", filterSB.String())
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
 
-	rows, err := m.DB.Query(ctx, query)
+	sorts, err := normalizeSort(opts.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	var qb queryBuilder
+	if !opts.IncludeDeleted {
+		qb.raw("deleted_at IS NULL")
+	}
+	if err := qb.addFilter(filter); err != nil {
+		slog.Error("ERROR in Subscription GetList", "error", err)
+		return nil, err
+	}
+
+	var total *int
+	if opts.WithTotal {
+		var count int
+		countQuery := fmt.Sprintf("SELECT count(*) FROM subscription %s", qb.whereClause())
+		if err := m.DB.QueryRow(ctx, countQuery, qb.args...).Scan(&count); err != nil {
+			slog.Error("ERROR in Subscription GetList", "error", err)
+			return nil, err
+		}
+		total = &count
+	}
+
+	if opts.Cursor != "" {
+		after, err := decodeCursor(opts.Cursor, sorts)
+		if err != nil {
+			return nil, err
+		}
+		qb.addKeyset(sorts, after)
+	}
+
+	limit := opts.limit()
+	query := fmt.Sprintf("SELECT %s FROM subscription %s %s LIMIT %s OFFSET %s", subscriptionSelect, qb.whereClause(), orderByClause(sorts), qb.arg(limit+1), qb.arg(opts.Offset))
+
+	rows, err := m.DB.Query(ctx, query, qb.args...)
 	if err != nil {
 		slog.Error("ERROR in Subscription GetList", "error", err)
 		return nil, err
@@ -153,24 +619,13 @@ func (m *SubscriptionModel) GetList(filter map[string]string) ([]*Subscription,
 	subs := []*Subscription{}
 
 	for rows.Next() {
-		var sub Subscription
-
-		var startTime time.Time
-		var endTime any
-
-		err := rows.Scan(&sub.Id, &sub.ServiceName, &sub.Price, &sub.UserId, &startTime, &endTime)
+		sub, err := scanSubscription(rows)
 		if err != nil {
 			slog.Error("ERROR in Subscription GetList", "error", err)
 			return nil, err
 		}
-		sub.StartDate = startTime.Format("01-2006")
 
-		t, ok := endTime.(time.Time)
-		if ok {
-			sub.EndDate = t.Format("01-2006")
-		}
-
-		subs = append(subs, &sub)
+		subs = append(subs, sub)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -178,74 +633,85 @@ func (m *SubscriptionModel) GetList(filter map[string]string) ([]*Subscription,
 		return nil, err
 	}
 
-	return subs, nil
+	page, err := newPage(subs, limit, sorts)
+	if err != nil {
+		return nil, err
+	}
+	page.Total = total
+
+	return page, nil
 }
 
-func (m *SubscriptionModel) GetPrice(startPeriodInput, endPeriodInput time.Time, filter map[string]string) (totalPrice int, prices map[int]string, err error) {
+// GetPrice calculates price of filtered subscriptions active within period, period dates are inclusive
+func (m *SubscriptionModel) GetPrice(startPeriodInput, endPeriodInput time.Time, filter *Filter, opts PriceOptions) (*PriceReport, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	prices = make(map[int]string)
-	var filterSB strings.Builder
-	for k, v := range filter {
-		str := fmt.Sprintf(" AND %s = '%s'", k, v)
-		filterSB.WriteString(str)
+	var qb queryBuilder
+	qb.raw(fmt.Sprintf("start_date <= %s", qb.arg(endPeriodInput)))
+	qb.raw(fmt.Sprintf("(%s >= %s OR %s IS NULL)", billedEndSQL, qb.arg(startPeriodInput), billedEndSQL))
+	if err := qb.addFilter(filter); err != nil {
+		slog.Error("ERROR in Subscription GetPrice", "error", err)
+		return nil, err
 	}
 
-	query := fmt.Sprintf(`SELECT *
-	 		FROM subscription
-			WHERE start_date <= '%s'
-			AND (end_date >= '%s'
-			OR end_date IS NULL)
-			 %s`, endPeriodInput.Format("2006/01/02"),
-		startPeriodInput.Format("2006/01/02"),
-		filterSB.String())
-
-	// fmt.Println(query)
+	// deleted subscriptions are kept in reports for the time they were active
+	query := fmt.Sprintf("SELECT id, service_name, price, currency, user_id, start_date, %s, billing_unit, billing_count FROM subscription %s ORDER BY id", billedEndSQL, qb.whereClause())
 
-	rows, err := m.DB.Query(ctx, query)
+	rows, err := m.DB.Query(ctx, query, qb.args...)
 	if err != nil {
 		slog.Error("ERROR in Subscription GetPrice", "error", err)
-		return totalPrice, prices, err
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	type billed struct {
+		sub      Subscription
+		startSub time.Time
+		endSub   *time.Time
 	}
+	var subs []billed
+	var ids []int
 
 	for rows.Next() {
-		var sub Subscription
+		var b billed
 
-		var startSub time.Time
-		var endAny any
-		endSub := endPeriodInput
-		err := rows.Scan(&sub.Id, &sub.ServiceName, &sub.Price, &sub.UserId, &startSub, &endAny)
+		err := rows.Scan(&b.sub.Id, &b.sub.ServiceName, &b.sub.Price, &b.sub.Currency, &b.sub.UserId, &b.startSub, &b.endSub, &b.sub.BillingPeriod.Unit, &b.sub.BillingPeriod.Count)
 		if err != nil {
 			slog.Error("ERROR in Subscription GetPrice", "error", err)
-			return totalPrice, prices, err
+			return nil, err
 		}
 
-		t, ok := endAny.(time.Time)
-		if ok {
-			endSub = t
-		}
+		subs = append(subs, b)
+		ids = append(ids, b.sub.Id)
+	}
 
-		startPeriod := startPeriodInput
-		if startSub.After(startPeriod) {
-			startPeriod = startSub
-		}
+	if err = rows.Err(); err != nil {
+		slog.Error("ERROR in Subscription GetPrice", "error", err)
+		return nil, err
+	}
 
-		endPeriod := endPeriodInput
-		if endSub.Before(endPeriod) {
-			endPeriod = endSub
-		}
+	history, err := loadPriceHistory(ctx, m.DB, ids)
+	if err != nil {
+		slog.Error("ERROR in Subscription GetPrice", "error", err)
+		return nil, err
+	}
 
-		y1, m1, _ := endPeriod.Date()
-		y2, m2, _ := startPeriod.Date()
+	pauses, err := loadPauses(ctx, m.DB, ids)
+	if err != nil {
+		slog.Error("ERROR in Subscription GetPrice", "error", err)
+		return nil, err
+	}
 
-		months := (y1-y2)*12 + int(m1) - int(m2)
+	report := newPriceReport(startPeriodInput, endPeriodInput, opts)
 
-		prices[sub.Id] = fmt.Sprintf("service_name: %s, months: %d, price: %d, user_id: %d, total_price: %d", sub.ServiceName, months, sub.Price, sub.UserId, months*sub.Price)
-		totalPrice += months * sub.Price
+	for _, b := range subs {
+		b.sub.Pauses = pauses[b.sub.Id]
+		if err := report.add(&b.sub, b.startSub, b.endSub, history[b.sub.Id]); err != nil {
+			return nil, err
+		}
 	}
 
-	defer rows.Close()
-
-	return totalPrice, prices, nil
+	return report, nil
 }
@@ -3,6 +3,7 @@ package env
 import (
 	"os"
 	"strconv"
+	"time"
 )
 
 func GetEnvString(key, defaultValue string) string {
@@ -22,3 +23,13 @@ func GetEnvInt(key string, defaultValue int) int {
 
 	return defaultValue
 }
+
+func GetEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if durationValue, err := time.ParseDuration(value); err == nil {
+			return durationValue
+		}
+	}
+
+	return defaultValue
+}
@@ -0,0 +1,161 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"gin-subscription/internal/database"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+	FormatXLSX   Format = "xlsx"
+)
+
+var contentTypes = map[Format]string{
+	FormatCSV:    "text/csv",
+	FormatNDJSON: "application/x-ndjson",
+	FormatXLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// ContentTypes returns MIME types of supported formats in the order of preference
+func ContentTypes() []string {
+	return []string{contentTypes[FormatCSV], contentTypes[FormatNDJSON], contentTypes[FormatXLSX]}
+}
+
+// ParseFormat accepts format name or content type
+func ParseFormat(s string) (Format, bool) {
+	for f, ct := range contentTypes {
+		if s == string(f) || s == ct {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+func (f Format) ContentType() string {
+	return contentTypes[f]
+}
+
+// PageSize is number of subscriptions read from storage at once while file is written
+const PageSize = database.MaxPageLimit
+
+// columns of exported file, importer reads file with the same names, so exported file can be imported back
+var columns = []string{"id", "service_id", "service_name", "plan_id", "price", "currency", "user_id", "start_date", "end_date", "billing_unit", "billing_count", "version", "deleted_at"}
+
+// decimal is number formatted as string, so price is written without losing minor units
+type decimal string
+
+// record returns subscription values in columns order, numbers are kept as int or decimal
+func record(sub *database.Subscription) []any {
+	deletedAt := ""
+	if sub.DeletedAt != nil {
+		deletedAt = sub.DeletedAt.UTC().Format(time.RFC3339)
+	}
+
+	// subscription without plan has empty plan_id
+	var planId any = ""
+	if sub.PlanId != 0 {
+		planId = sub.PlanId
+	}
+
+	values := []any{sub.Id, sub.ServiceId, sub.ServiceName, planId, decimal(database.FormatAmount(sub.Price, sub.Currency)), sub.Currency, sub.UserId, sub.StartDate, sub.EndDate, string(sub.BillingPeriod.Unit), sub.BillingPeriod.Count, sub.Version, deletedAt}
+	for i, v := range values {
+		if s, ok := v.(string); ok {
+			values[i] = EscapeFormula(s)
+		}
+	}
+
+	return values
+}
+
+// formulaPrefixes are first characters which make spreadsheet treat cell as formula
+const formulaPrefixes = "=+-@\t\r"
+
+// EscapeFormula prefixes text which spreadsheet would evaluate as formula with ', so it is shown as text.
+// Importer strips the prefix, so exported file is imported back unchanged.
+func EscapeFormula(s string) string {
+	if s != "" && strings.ContainsRune(formulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// UnescapeFormula reverts EscapeFormula
+func UnescapeFormula(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
+
+// Writer writes subscriptions one by one, Close must be called to finish the file
+type Writer interface {
+	Write(sub *database.Subscription) error
+	Close() error
+}
+
+func NewWriter(w io.Writer, format Format) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatNDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	case FormatXLSX:
+		return newXLSXWriter(w)
+	}
+
+	return nil, fmt.Errorf("unsupported export format %q", format)
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w)}
+	if err := cw.w.Write(columns); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (cw *csvWriter) Write(sub *database.Subscription) error {
+	values := record(sub)
+	fields := make([]string, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case int:
+			fields[i] = strconv.Itoa(v)
+		case decimal:
+			fields[i] = string(v)
+		case string:
+			fields[i] = v
+		}
+	}
+	return cw.w.Write(fields)
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (nw *ndjsonWriter) Write(sub *database.Subscription) error {
+	return nw.enc.Encode(sub)
+}
+
+func (nw *ndjsonWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,94 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"gin-subscription/internal/database"
+)
+
+func TestEscapeFormula(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{in: "Netflix", want: "Netflix"},
+		{in: "", want: ""},
+		{in: "=HYPERLINK(\"http://example.com\")", want: "'=HYPERLINK(\"http://example.com\")"},
+		{in: "+1", want: "'+1"},
+		{in: "-2+3", want: "'-2+3"},
+		{in: "@SUM(A1)", want: "'@SUM(A1)"},
+		{in: "\t=1", want: "'\t=1"},
+		{in: "\r=1", want: "'\r=1"},
+		{in: "a=1", want: "a=1"},
+		{in: "'quoted", want: "'quoted"},
+	}
+
+	for _, tt := range tests {
+		if got := EscapeFormula(tt.in); got != tt.want {
+			t.Errorf("EscapeFormula(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if got := UnescapeFormula(EscapeFormula(tt.in)); got != tt.in {
+			t.Errorf("UnescapeFormula(EscapeFormula(%q)) = %q", tt.in, got)
+		}
+	}
+}
+
+func TestWriterEscapesFormulas(t *testing.T) {
+	sub := &database.Subscription{Id: 1, ServiceId: 2, ServiceName: "=cmd|' /C calc'!A0", Price: 40000, Currency: "RUB", UserId: 3, StartDate: "07-2025", Version: 1}
+
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{format: FormatCSV, want: `,'=cmd|' /C calc'!A0,`},
+		{format: FormatXLSX, want: `<t>&#39;=cmd|&#39; /C calc&#39;!A0</t>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, tt.format)
+			if err != nil {
+				t.Fatalf("NewWriter() = %v", err)
+			}
+			if err := w.Write(sub); err != nil {
+				t.Fatalf("Write() = %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close() = %v", err)
+			}
+
+			out := buf.String()
+			if tt.format == FormatXLSX {
+				out = readSheet(t, buf.Bytes())
+			}
+			if !strings.Contains(out, tt.want) {
+				t.Errorf("file = %s, want it to contain %s", out, tt.want)
+			}
+		})
+	}
+}
+
+// readSheet returns XML of the only sheet of workbook
+func readSheet(t *testing.T, data []byte) string {
+	t.Helper()
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("zip.NewReader() = %v", err)
+	}
+	f, err := zr.Open("xl/worksheets/sheet1.xml")
+	if err != nil {
+		t.Fatalf("Open(sheet1.xml) = %v", err)
+	}
+	defer f.Close()
+
+	sheet, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("ReadAll(sheet1.xml) = %v", err)
+	}
+	return string(sheet)
+}
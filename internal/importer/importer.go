@@ -0,0 +1,310 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gin-subscription/internal/database"
+	"gin-subscription/internal/export"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+)
+
+const DefaultBatchSize = 1000
+
+// maxRejected limits number of rejected lines listed in report, all of them are still counted
+const maxRejected = 1000
+
+// csvColumns are columns read from CSV, exported file has the same ones and id, version and deleted_at, which are ignored.
+// Header must have user_id, start_date and at least one of service columns.
+var csvColumns = []string{"user_id", "start_date", "service_name", "service_id", "plan_id", "price", "currency", "end_date", "billing_unit", "billing_count"}
+
+type RejectedLine struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
+
+type Report struct {
+	Accepted int            `json:"accepted"`
+	Rejected int            `json:"rejected"`
+	Errors   []RejectedLine `json:"errors"`
+	// AbortedAt is line import stopped at because input couldn't be read, lines before it are imported.
+	// Error is the reason, both are empty when whole input is read.
+	AbortedAt int    `json:"aborted_at,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// AbortError is returned when input can't be read further, lines before Line are already imported
+type AbortError struct {
+	Line int
+	Err  error
+}
+
+func (e *AbortError) Error() string {
+	return fmt.Sprintf("aborted at line %d: %v", e.Line, e.Err)
+}
+
+func (e *AbortError) Unwrap() error {
+	return e.Err
+}
+
+func (r *Report) reject(line int, reason string) {
+	r.Rejected++
+	if len(r.Errors) < maxRejected {
+		r.Errors = append(r.Errors, RejectedLine{Line: line, Reason: reason})
+	}
+}
+
+// ParseFormat accepts format name, file extension or content type
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(s, ".")) {
+	case "csv", "text/csv":
+		return FormatCSV, nil
+	case "ndjson", "jsonl", "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return FormatNDJSON, nil
+	}
+
+	return "", fmt.Errorf("unsupported import format %q, expected csv or ndjson", s)
+}
+
+type row struct {
+	line int
+	sub  *database.Subscription
+}
+
+// Importer streams subscriptions from reader, validates them with the same rules as API and inserts them in batches
+type Importer struct {
+	Subscriptions database.SubscriptionRepository
+	BatchSize     int
+}
+
+// Import inserts valid lines and reports rejected ones. When input breaks after some lines are imported,
+// *AbortError is returned together with report of imported lines.
+func (im *Importer) Import(r io.Reader, format Format) (*Report, error) {
+	report := &Report{Errors: []RejectedLine{}}
+
+	batchSize := im.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	batch := make([]row, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		// batch is inserted from copies, failed insert may have filled service of rows from rolled back transaction
+		subs := make([]*database.Subscription, len(batch))
+		for i, r := range batch {
+			sub := *r.sub
+			subs[i] = &sub
+		}
+
+		if err := im.Subscriptions.InsertMany(subs); err != nil {
+			slog.Error("ERROR in Importer flush, inserting rows one by one", "error", err)
+			for _, r := range batch {
+				if err := im.Subscriptions.Insert(r.sub); err != nil {
+					report.reject(r.line, err.Error())
+					continue
+				}
+				report.Accepted++
+			}
+		} else {
+			report.Accepted += len(batch)
+		}
+
+		batch = batch[:0]
+	}
+
+	emit := func(line int, sub *database.Subscription, err error) {
+		if err == nil {
+			err = validate(sub)
+		}
+		if err != nil {
+			report.reject(line, err.Error())
+			return
+		}
+
+		batch = append(batch, row{line: line, sub: sub})
+		if len(batch) == batchSize {
+			flush()
+		}
+	}
+
+	var err error
+	switch format {
+	case FormatCSV:
+		err = readCSV(r, emit)
+	case FormatNDJSON:
+		err = readNDJSON(r, emit)
+	default:
+		err = fmt.Errorf("unsupported import format %q", format)
+	}
+
+	var abort *AbortError
+	if errors.As(err, &abort) {
+		flush()
+		report.AbortedAt, report.Error = abort.Line, abort.Error()
+		return report, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	flush()
+
+	return report, nil
+}
+
+func validate(sub *database.Subscription) error {
+	if err := binding.Validator.ValidateStruct(sub); err != nil {
+		return err
+	}
+
+	_, _, err := database.ParseDates(sub.StartDate, sub.EndDate)
+
+	return err
+}
+
+// readCSV reads CSV with header row, columns may go in any order, see csvColumns.
+// Price is decimal in units of currency, e.g. 399.99
+func readCSV(r io.Reader, emit func(line int, sub *database.Subscription, err error)) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	index := make(map[string]int)
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range csvColumns[:2] {
+		if _, ok := index[name]; !ok {
+			return fmt.Errorf("csv header has no %q column", name)
+		}
+	}
+	if !slices.ContainsFunc(csvColumns[2:5], func(name string) bool { _, ok := index[name]; return ok }) {
+		return errors.New("csv header has no service_name, service_id or plan_id column")
+	}
+
+	line := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			line = parseErr.Line
+			emit(parseErr.Line, nil, parseErr.Err)
+			continue
+		}
+		if err != nil {
+			return &AbortError{Line: line + 1, Err: err}
+		}
+
+		line, _ = reader.FieldPos(0)
+
+		field := func(name string) string {
+			i, ok := index[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			// text escaped by export to not be evaluated as formula is read back as it was
+			return export.UnescapeFormula(strings.TrimSpace(record[i]))
+		}
+
+		sub := &database.Subscription{
+			ServiceName: field("service_name"),
+			StartDate:   field("start_date"),
+			EndDate:     field("end_date"),
+			Currency:    field("currency"),
+		}
+		sub.BillingPeriod.Unit = database.BillingUnit(field("billing_unit"))
+
+		// price may be omitted when service or plan has it
+		if price := field("price"); price != "" {
+			if sub.Price, err = database.ParsePrice(price, sub.Currency); err != nil {
+				emit(line, nil, err)
+				continue
+			}
+		}
+		if id := field("service_id"); id != "" {
+			if sub.ServiceId, err = strconv.Atoi(id); err != nil {
+				emit(line, nil, fmt.Errorf("invalid service_id %q, integer expected", id))
+				continue
+			}
+		}
+		if id := field("plan_id"); id != "" {
+			if sub.PlanId, err = strconv.Atoi(id); err != nil {
+				emit(line, nil, fmt.Errorf("invalid plan_id %q, integer expected", id))
+				continue
+			}
+		}
+		if sub.UserId, err = strconv.Atoi(field("user_id")); err != nil {
+			emit(line, nil, fmt.Errorf("invalid user_id %q, integer expected", field("user_id")))
+			continue
+		}
+		if count := field("billing_count"); count != "" {
+			if sub.BillingPeriod.Count, err = strconv.Atoi(count); err != nil {
+				emit(line, nil, fmt.Errorf("invalid billing_count %q, integer expected", count))
+				continue
+			}
+		}
+
+		emit(line, sub, nil)
+	}
+}
+
+// readNDJSON reads one JSON subscription per line, empty lines are skipped
+func readNDJSON(r io.Reader, emit func(line int, sub *database.Subscription, err error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var sub database.Subscription
+		if err := json.Unmarshal(data, &sub); err != nil {
+			emit(line, nil, err)
+			continue
+		}
+
+		emit(line, &sub, nil)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return &AbortError{Line: line + 1, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package importer
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"gin-subscription/internal/database"
+)
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name         string
+		format       Format
+		input        string
+		batchSize    int
+		wantAccepted int
+		wantRejected []int
+		wantServices []string
+	}{
+		{
+			name:   "csv",
+			format: FormatCSV,
+			input: "service_name,price,user_id,start_date,end_date\n" +
+				"Yandex Plus,400,1,07-2025,\n" +
+				"Netflix,100.50,2,01-2025,05-2025\n",
+			wantAccepted: 2,
+			wantRejected: []int{},
+			wantServices: []string{"Yandex Plus", "Netflix"},
+		},
+		{
+			name:   "csv with columns in other order",
+			format: FormatCSV,
+			input: "user_id,start_date,price,service_name\n" +
+				"1,07-2025,400,Yandex Plus\n",
+			wantAccepted: 1,
+			wantRejected: []int{},
+			wantServices: []string{"Yandex Plus"},
+		},
+		{
+			name:   "csv with invalid rows",
+			format: FormatCSV,
+			input: "service_name,price,user_id,start_date\n" +
+				"Yandex Plus,400,1,07-2025\n" +
+				"Netflix,abc,2,01-2025\n" +
+				"Netflix,100,two,01-2025\n" +
+				"Netflix,100,2,2025-01\n" +
+				",100,2,01-2025\n" +
+				"Spotify,250,1,03-2025\n",
+			wantAccepted: 2,
+			wantRejected: []int{3, 4, 5, 6},
+			wantServices: []string{"Yandex Plus", "Spotify"},
+		},
+		{
+			name:   "csv with text escaped by export",
+			format: FormatCSV,
+			input: "service_name,price,user_id,start_date\n" +
+				"'=Netflix,100,2,01-2025\n" +
+				"'Netflix,100,2,01-2025\n",
+			wantAccepted: 2,
+			wantRejected: []int{},
+			wantServices: []string{"=Netflix", "'Netflix"},
+		},
+		{
+			name:   "ndjson",
+			format: FormatNDJSON,
+			input: `{"service_name": "Yandex Plus", "price": "400", "user_id": 1, "start_date": "07-2025"}` + "\n" +
+				"\n" +
+				`{"service_name": "Netflix", "price": 0, "user_id": 2, "start_date": "01-2025"}` + "\n" +
+				`{"service_name": "Netflix", "price": "100", "user_id": 2` + "\n" +
+				`{"service_name": "Spotify", "price": "250", "user_id": 1, "start_date": "03-2025", "end_date": "12-2025"}` + "\n",
+			wantAccepted: 2,
+			wantRejected: []int{3, 4},
+			wantServices: []string{"Yandex Plus", "Spotify"},
+		},
+		{
+			// unknown service fails the whole batch, its rows are inserted one by one
+			name:   "failed batch is inserted row by row",
+			format: FormatCSV,
+			input: "service_name,service_id,price,user_id,start_date\n" +
+				"Yandex Plus,,400,1,07-2025\n" +
+				",999,100,2,01-2025\n" +
+				"Spotify,,250,1,03-2025\n",
+			batchSize:    2,
+			wantAccepted: 2,
+			wantRejected: []int{3},
+			wantServices: []string{"Yandex Plus", "Spotify"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subs := database.NewMemorySubscriptionModel()
+			im := &Importer{Subscriptions: subs, BatchSize: tt.batchSize}
+
+			report, err := im.Import(strings.NewReader(tt.input), tt.format)
+			if err != nil {
+				t.Fatalf("Import() = %v", err)
+			}
+
+			if report.Accepted != tt.wantAccepted || report.Rejected != len(tt.wantRejected) {
+				t.Errorf("Import() accepted, rejected = %d, %d, want %d, %d", report.Accepted, report.Rejected, tt.wantAccepted, len(tt.wantRejected))
+			}
+			lines := []int{}
+			for _, e := range report.Errors {
+				lines = append(lines, e.Line)
+			}
+			if !slices.Equal(lines, tt.wantRejected) {
+				t.Errorf("Import() rejected lines = %v, want %v", lines, tt.wantRejected)
+			}
+			if report.AbortedAt != 0 || report.Error != "" {
+				t.Errorf("Import() aborted at %d: %s", report.AbortedAt, report.Error)
+			}
+
+			page, err := subs.GetList(nil, database.ListOptions{})
+			if err != nil {
+				t.Fatalf("GetList() = %v", err)
+			}
+			services := []string{}
+			for _, sub := range page.Data {
+				services = append(services, sub.ServiceName)
+			}
+			if !slices.Equal(services, tt.wantServices) {
+				t.Errorf("imported services = %v, want %v", services, tt.wantServices)
+			}
+		})
+	}
+}
+
+func TestImportAbort(t *testing.T) {
+	readErr := errors.New("connection reset")
+
+	tests := []struct {
+		name          string
+		format        Format
+		input         string
+		wantAccepted  int
+		wantAbortedAt int
+	}{
+		{
+			name:   "csv",
+			format: FormatCSV,
+			input: "service_name,price,user_id,start_date\n" +
+				"Yandex Plus,400,1,07-2025\n" +
+				"Netflix,100,2,01-2025\n",
+			wantAccepted:  2,
+			wantAbortedAt: 4,
+		},
+		{
+			name:   "ndjson",
+			format: FormatNDJSON,
+			input: `{"service_name": "Yandex Plus", "price": "400", "user_id": 1, "start_date": "07-2025"}` + "\n" +
+				`{"service_name": "Netflix", "price": 0, "user_id": 2, "start_date": "01-2025"}` + "\n",
+			wantAccepted:  1,
+			wantAbortedAt: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subs := database.NewMemorySubscriptionModel()
+			im := &Importer{Subscriptions: subs}
+
+			r := io.MultiReader(strings.NewReader(tt.input), iotest.ErrReader(readErr))
+			report, err := im.Import(r, tt.format)
+
+			var abort *AbortError
+			if !errors.As(err, &abort) || !errors.Is(err, readErr) {
+				t.Fatalf("Import() = %v, want *AbortError wrapping %v", err, readErr)
+			}
+			if report == nil {
+				t.Fatalf("Import() report = nil, want report of imported lines")
+			}
+			if report.Accepted != tt.wantAccepted || report.AbortedAt != tt.wantAbortedAt || report.Error == "" {
+				t.Errorf("Import() accepted %d, aborted at %d with %q, want %d, %d", report.Accepted, report.AbortedAt, report.Error, tt.wantAccepted, tt.wantAbortedAt)
+			}
+
+			page, err := subs.GetList(nil, database.ListOptions{WithTotal: true})
+			if err != nil {
+				t.Fatalf("GetList() = %v", err)
+			}
+			if *page.Total != tt.wantAccepted {
+				t.Errorf("imported %d subscriptions, want %d", *page.Total, tt.wantAccepted)
+			}
+		})
+	}
+}
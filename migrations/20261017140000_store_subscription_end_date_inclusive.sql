@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- end date is stored as last billed day, month end dates were stored as first day of month before
+UPDATE subscription SET end_date = date_trunc('month', end_date) + interval '1 month' - interval '1 day' WHERE end_date IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE subscription SET end_date = date_trunc('month', end_date) WHERE end_date IS NOT NULL;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE subscription
+    ADD COLUMN IF NOT EXISTS billing_unit VARCHAR(8) NOT NULL DEFAULT 'month' CHECK (billing_unit IN ('day', 'week', 'month', 'year')),
+    ADD COLUMN IF NOT EXISTS billing_count INTEGER NOT NULL DEFAULT 1 CHECK (billing_count > 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE subscription DROP COLUMN IF EXISTS billing_unit, DROP COLUMN IF EXISTS billing_count;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS subscription_pauses (
+    subscription_id INTEGER NOT NULL REFERENCES subscription (id) ON DELETE CASCADE,
+    pause_from DATE NOT NULL,
+    pause_until DATE NULL CHECK (pause_until >= pause_from),
+    PRIMARY KEY (subscription_id, pause_from)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS subscription_pauses;
+-- +goose StatementEnd
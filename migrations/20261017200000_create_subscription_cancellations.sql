@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS subscription_cancellations (
+    subscription_id INTEGER PRIMARY KEY REFERENCES subscription (id) ON DELETE CASCADE,
+    mode TEXT NOT NULL CHECK (mode IN ('immediate', 'end_of_cycle')),
+    reason TEXT NOT NULL CHECK (reason IN ('too_expensive', 'not_using', 'switched_service', 'missing_features', 'technical_issues', 'customer_service', 'other')),
+    comment TEXT NOT NULL DEFAULT '',
+    cancelled_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    effective_date DATE NOT NULL,
+    previous_end_date DATE NULL
+);
+
+CREATE INDEX IF NOT EXISTS subscription_cancellations_effective_date_idx ON subscription_cancellations (effective_date);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS subscription_cancellations;
+-- +goose StatementEnd
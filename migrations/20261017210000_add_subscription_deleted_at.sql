@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE subscription ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ NULL;
+
+CREATE INDEX IF NOT EXISTS subscription_deleted_at_idx ON subscription (deleted_at) WHERE deleted_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM subscription WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS subscription_deleted_at_idx;
+ALTER TABLE subscription DROP COLUMN IF EXISTS deleted_at;
+-- +goose StatementEnd
@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS subscription_audit (
+    id BIGSERIAL PRIMARY KEY,
+    -- no foreign key, log of purged subscription is kept
+    subscription_id INTEGER NOT NULL,
+    action TEXT NOT NULL,
+    actor TEXT NOT NULL,
+    request_id TEXT NOT NULL DEFAULT '',
+    changes JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS subscription_audit_subscription_id_idx ON subscription_audit (subscription_id, id);
+
+CREATE OR REPLACE FUNCTION subscription_audit_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'subscription_audit is append only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER subscription_audit_append_only
+    BEFORE UPDATE OR DELETE ON subscription_audit
+    FOR EACH ROW EXECUTE FUNCTION subscription_audit_append_only();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS subscription_audit;
+DROP FUNCTION IF EXISTS subscription_audit_append_only();
+-- +goose StatementEnd
@@ -0,0 +1,77 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS services (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    aliases TEXT[] NOT NULL DEFAULT '{}',
+    category VARCHAR(100) NOT NULL DEFAULT '',
+    default_price BIGINT NULL,
+    default_currency CHAR(3) NULL,
+    logo_url TEXT NOT NULL DEFAULT '',
+    CHECK ((default_price IS NULL) = (default_currency IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS services_category_idx ON services (category);
+
+-- lookup keys of names and aliases: lower case with single spaces
+CREATE TABLE IF NOT EXISTS service_names (
+    key TEXT PRIMARY KEY,
+    service_id INTEGER NOT NULL REFERENCES services (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS service_names_service_id_idx ON service_names (service_id);
+
+-- existing names which differ only in case and spaces become one service, the most used spelling is its name
+CREATE TEMPORARY TABLE service_spellings ON COMMIT DROP AS
+SELECT DISTINCT ON (key) key, name
+FROM (
+    SELECT lower(btrim(regexp_replace(service_name, '\s+', ' ', 'g'))) AS key,
+           btrim(regexp_replace(service_name, '\s+', ' ', 'g')) AS name,
+           count(*) AS used
+    FROM subscription
+    GROUP BY 1, 2
+) AS spellings
+ORDER BY key, used DESC, name;
+
+WITH inserted AS (
+    INSERT INTO services (name)
+    SELECT name FROM service_spellings ORDER BY name
+    RETURNING id, name
+)
+INSERT INTO service_names (key, service_id)
+SELECT lower(name), id FROM inserted;
+
+ALTER TABLE subscription ADD COLUMN IF NOT EXISTS service_id INTEGER NULL REFERENCES services (id);
+
+-- renamed subscriptions get new version and audit entry, so history and as_of reads see the rename
+WITH linked AS (
+    UPDATE subscription AS sub
+    SET service_id = s.id,
+        service_name = s.name,
+        version = sub.version + CASE WHEN old.service_name <> s.name THEN 1 ELSE 0 END
+    FROM service_names AS n
+    JOIN services AS s ON s.id = n.service_id,
+    subscription AS old
+    WHERE n.key = lower(btrim(regexp_replace(old.service_name, '\s+', ' ', 'g'))) AND old.id = sub.id
+    RETURNING sub.id, s.id AS service_id, old.service_name AS old_name, s.name AS new_name
+)
+INSERT INTO subscription_audit (subscription_id, action, actor, changes)
+SELECT id, 'update', 'migration', jsonb_build_object(
+    'service_name', jsonb_build_object('before', old_name, 'after', new_name),
+    'service_id', jsonb_build_object('before', NULL, 'after', service_id))
+FROM linked
+WHERE old_name <> new_name
+ORDER BY id;
+
+ALTER TABLE subscription ALTER COLUMN service_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS subscription_service_id_idx ON subscription (service_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS subscription_service_id_idx;
+ALTER TABLE subscription DROP COLUMN IF EXISTS service_id;
+DROP TABLE IF EXISTS service_names;
+DROP TABLE IF EXISTS services;
+-- +goose StatementEnd
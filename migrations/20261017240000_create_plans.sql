@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS plans (
+    id SERIAL PRIMARY KEY,
+    service_id INTEGER NOT NULL REFERENCES services (id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    price BIGINT NOT NULL CHECK (price > 0),
+    currency CHAR(3) NOT NULL,
+    billing_unit VARCHAR(8) NOT NULL DEFAULT 'month' CHECK (billing_unit IN ('day', 'week', 'month', 'year')),
+    billing_count INTEGER NOT NULL DEFAULT 1 CHECK (billing_count > 0)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS plans_service_id_name_idx ON plans (service_id, lower(name));
+
+ALTER TABLE subscription ADD COLUMN IF NOT EXISTS plan_id INTEGER NULL REFERENCES plans (id);
+
+CREATE INDEX IF NOT EXISTS subscription_plan_id_idx ON subscription (plan_id) WHERE plan_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS subscription_plan_id_idx;
+ALTER TABLE subscription DROP COLUMN IF EXISTS plan_id;
+DROP TABLE IF EXISTS plans;
+-- +goose StatementEnd